@@ -1,5 +1,7 @@
 package main
 
+import "crypto/rand"
+
 // Effectue un XOR bit à bit entre le block et la clé
 func addRoundKey(b, k []byte) {
 	for i := range b {
@@ -132,7 +134,7 @@ func AESEncrypt(data, key []byte) []byte {
 	// Génère toutes les clés
 	roundKeys := keyExpansions(key, nr)
 
-	data = addPadding(data, keySize*8)
+	data = addPadding(rand.Reader, data, keySize*8)
 	cipher := make([]byte, 0, len(data))
 
 	for i := 0; i < len(data)/keySize; i++ {
@@ -163,7 +165,8 @@ func AESEncrypt(data, key []byte) []byte {
 }
 
 // AESDecrypt déchiffre avec l'agorithme AES un tableau
-// de byte avec une clé k de taille 128, 192 ou 256 bits
+// de byte avec une clé k de taille 128, 192 ou 256 bits. nil est renvoyé
+// si le padding du message déchiffré est invalide.
 func AESDecrypt(cipher, key []byte) []byte {
 	var (
 		block      []byte
@@ -207,7 +210,10 @@ func AESDecrypt(cipher, key []byte) []byte {
 		m = append(m, block...)
 	}
 
-	m = removePadding(m)
+	m, err := removePadding(m)
+	if err != nil {
+		return nil
+	}
 
 	return m
 }
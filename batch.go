@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"sort"
+)
+
+// Taille en octets des exposants aléatoires de la vérification par lots :
+// un lot contenant une signature invalide est accepté avec une
+// probabilité d'au plus 2^-128
+const batchExponentSize = 16
+
+// SignedMessage est un document et sa signature détachée (voir
+// ElgamalSignDetached), à vérifier avec ElgamalCheckBatch
+type SignedMessage struct {
+	Pub       *ElgamalPublicKey
+	Data      []byte
+	Signature []byte
+}
+
+// Signature d'un lot, une fois le message haché
+type batchItem struct {
+	index int
+	r, s  *big.Int
+	e     *big.Int // empreinte réduite (ElGamal) ou défi (Schnorr)
+}
+
+// Lot de signatures d'un même schéma faites avec la même clé. Les
+// exposants sont calculés modulo order : p-1 pour ElGamal, l'ordre du
+// sous-groupe pour Schnorr.
+type signatureBatch struct {
+	scheme         string
+	p, order, g, y *big.Int
+	items          []batchItem
+}
+
+// Renvoie le symbole de Legendre de x^e modulo p
+func legendrePow(x, e, p *big.Int) int {
+	j := big.Jacobi(x, p)
+	if j == -1 && e.Bit(0) == 0 {
+		return 1
+	}
+	return j
+}
+
+// ElgamalCheckBatch vérifie les signatures ElGamal et de Schnorr des
+// messages par lots et renvoie les indices, croissants, des signatures
+// invalides.
+//
+// Les signatures d'un lot (même schéma et même clé) sont vérifiées
+// ensemble par le test des petits exposants (Bellare, Garay et Rabin,
+// 1998) : chaque équation est élevée à une puissance aléatoire de 128
+// bits, lue depuis rand, et les équations sont multipliées entre elles.
+// Pour n signatures de Schnorr, il suffit alors de deux exponentiations
+// complètes et de n exponentiations courtes, au lieu de 3n ; pour n
+// signatures ElGamal, de n+2 exponentiations au lieu de 3n. Si un lot est
+// refusé, il est coupé en deux jusqu'à isoler les signatures invalides,
+// qui sont vérifiées une à une.
+//
+// Le test n'est sûr que dans un groupe d'ordre premier : R doit appartenir
+// au sous-groupe pour Schnorr et, pour ElGamal, le symbole de Legendre de
+// chaque équation est vérifié à part, ce qui suppose que p est un nombre
+// premier sûr. Chaque clé est donc validée une fois (voir Validate) et les
+// signatures d'une clé invalide sont vérifiées une à une, comme les
+// signatures DSA, dont l'équation est réduite modulo q, et les signatures
+// ElGamal des clés DSA.
+func ElgamalCheckBatch(rand io.Reader, msgs []SignedMessage) (invalid []int) {
+	var batches []*signatureBatch
+	index := make(map[string]*signatureBatch)
+
+	// Résultat de la validation de chaque clé, dont le groupe doit être
+	// celui d'un nombre premier sûr ou d'une clé DSA
+	validKeys := make(map[string]bool)
+	valid := func(pub *ElgamalPublicKey) bool {
+		id := string(serialize(pub.Fingerprint(), subgroupOrderBytes(pub)))
+		ok, seen := validKeys[id]
+		if !seen {
+			// Les clés de GnuPG passent Validate sans que p soit sûr :
+			// leurs signatures sont vérifiées une à une
+			_, err := pub.generatorOrder()
+			ok = err == nil && pub.Validate() == nil
+			validKeys[id] = ok
+		}
+		return ok
+	}
+
+	for i, msg := range msgs {
+		sig, err := parseSignature(msg.Pub, msg.Signature)
+		if sig == nil || err != nil {
+			invalid = append(invalid, i)
+			continue
+		}
+
+		b := &signatureBatch{scheme: sig.scheme}
+		var item *batchItem
+
+		switch {
+		case sig.scheme == schemeElgamal && msg.Pub.SubgroupOrder == nil && valid(msg.Pub):
+			b.p, b.order, b.g, b.y = new(big.Int).Add(msg.Pub.Q, big1), msg.Pub.Q, msg.Pub.G, msg.Pub.H
+			item = elgamalBatchItem(b, sig, msg.Data)
+		case (sig.scheme == schemeSchnorr || sig.scheme == schemeSchnorrV2) && valid(msg.Pub):
+			// Seul le calcul du défi diffère entre les deux versions
+			b.scheme = schemeSchnorr
+			b.p, b.order, b.g, b.y = schnorrGroup(msg.Pub)
+			item = schnorrBatchItem(b, msg.Pub, sig, msg.Data)
+		default:
+			if !check(msg.Pub, msg.Data, msg.Signature) {
+				invalid = append(invalid, i)
+			}
+			continue
+		}
+
+		if item == nil {
+			invalid = append(invalid, i)
+			continue
+		}
+		item.index = i
+
+		id := string(serialize([]byte(sig.scheme), msg.Pub.Fingerprint(), subgroupOrderBytes(msg.Pub)))
+		if index[id] == nil {
+			index[id] = b
+			batches = append(batches, b)
+		}
+		index[id].items = append(index[id].items, *item)
+	}
+
+	for _, b := range batches {
+		invalid = append(invalid, b.check(rand, b.items)...)
+	}
+	sort.Ints(invalid)
+	return invalid
+}
+
+// Renvoie l'ordre du sous-groupe de la clé, vide si elle n'en a pas
+func subgroupOrderBytes(pub *ElgamalPublicKey) []byte {
+	if pub.SubgroupOrder == nil {
+		return nil
+	}
+	return pub.SubgroupOrder.Bytes()
+}
+
+// Prépare la vérification d'une signature ElGamal (s1, s2) :
+// g^H(m) = y^s1 * s1^s2. Renvoie nil si la signature est invalide.
+func elgamalBatchItem(b *signatureBatch, sig *parsedSignature, data []byte) *batchItem {
+	// On doit avoir 0 < s1 < p et 0 <= s2 < p-1
+	if sig.r.Sign() <= 0 || sig.r.Cmp(b.p) >= 0 || sig.s.Cmp(b.order) >= 0 {
+		return nil
+	}
+	hm := hashToInt(hash(sig.h, data), b.order)
+
+	// Les symboles de Legendre des deux membres doivent être égaux : le
+	// lot n'est alors vérifié que dans le sous-groupe des carrés, d'ordre
+	// premier
+	if legendrePow(b.g, hm, b.p) != legendrePow(b.y, sig.r, b.p)*legendrePow(sig.r, sig.s, b.p) {
+		return nil
+	}
+	return &batchItem{r: sig.r, s: sig.s, e: hm}
+}
+
+// Prépare la vérification d'une signature de Schnorr (R, s) :
+// g^s = R * y^e. Renvoie nil si la signature est invalide.
+func schnorrBatchItem(b *signatureBatch, pub *ElgamalPublicKey, sig *parsedSignature, data []byte) *batchItem {
+	// On doit avoir 0 < R < p et 0 <= s < q
+	if sig.r.Sign() <= 0 || sig.r.Cmp(b.p) >= 0 || sig.s.Cmp(b.order) >= 0 {
+		return nil
+	}
+
+	// R doit appartenir au sous-groupe d'ordre q : pour une clé ElGamal,
+	// c'est le sous-groupe des carrés
+	if pub.SubgroupOrder == nil {
+		if big.Jacobi(sig.r, b.p) != 1 {
+			return nil
+		}
+	} else if new(big.Int).Exp(sig.r, b.order, b.p).Cmp(big1) != 0 {
+		return nil
+	}
+
+	// La lecture depuis un bytes.Reader ne peut pas échouer
+	input, _ := schnorrChallengeInput(sig.scheme, sig.h, bytes.NewReader(data))
+	e, _ := schnorrChallenge(sig.h, b.p, b.order, sig.r, b.y, input)
+	return &batchItem{r: sig.r, s: sig.s, e: e}
+}
+
+// Renvoie les indices des signatures invalides parmi items, en coupant le
+// lot en deux tant qu'il est refusé
+func (b *signatureBatch) check(rand io.Reader, items []batchItem) []int {
+	if len(items) == 0 || b.holds(rand, items) {
+		return nil
+	}
+	if len(items) == 1 {
+		return []int{items[0].index}
+	}
+
+	mid := len(items) / 2
+	return append(b.check(rand, items[:mid]), b.check(rand, items[mid:])...)
+}
+
+// Vérifie le produit des équations de items, élevées à des puissances
+// aléatoires. Une signature seule est vérifiée exactement.
+func (b *signatureBatch) holds(rand io.Reader, items []batchItem) bool {
+	var (
+		gExp = new(big.Int)
+		yExp = new(big.Int)
+		prod = big.NewInt(1)
+		a    = big.NewInt(1)
+		t, e = new(big.Int), new(big.Int)
+	)
+
+	for _, item := range items {
+		if len(items) > 1 {
+			a.SetBytes(readRandom(rand, batchExponentSize))
+		}
+
+		switch b.scheme {
+		case schemeElgamal:
+			// g^Σ(a*H(m)) = y^Σ(a*s1) * Π s1^(a*s2)
+			gExp.Add(gExp, t.Mul(a, item.e))
+			yExp.Add(yExp, t.Mul(a, item.r))
+			e.Mul(a, item.s)
+			prod.Mul(prod, t.Exp(item.r, e.Mod(e, b.order), b.p))
+		case schemeSchnorr:
+			// g^Σ(a*s) = y^Σ(a*e) * Π R^a
+			gExp.Add(gExp, t.Mul(a, item.s))
+			yExp.Add(yExp, t.Mul(a, item.e))
+			prod.Mul(prod, t.Exp(item.r, a, b.p))
+		}
+		prod.Mod(prod, b.p)
+	}
+
+	lhs := new(big.Int).Exp(b.g, gExp.Mod(gExp, b.order), b.p)
+	rhs := new(big.Int).Exp(b.y, yExp.Mod(yExp, b.order), b.p)
+	rhs.Mul(rhs, prod)
+	rhs.Mod(rhs, b.p)
+
+	return lhs.Cmp(rhs) == 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// Signe n documents aléatoires avec le schéma scheme
+func signedMessages(t *testing.T, priv *ElgamalPrivateKey, scheme string, n int) []SignedMessage {
+	msgs := make([]SignedMessage, n)
+	for i := range msgs {
+		data := randomBytes(100)
+		signature, err := ElgamalSignDetached(rand.Reader, priv, data, crypto.SHA256, scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs[i] = SignedMessage{Pub: &priv.ElgamalPublicKey, Data: data, Signature: signature}
+	}
+	return msgs
+}
+
+// Remplace s par s + delta dans la signature
+func shiftSignature(signature []byte, delta int64) []byte {
+	d := deserialize(signature)
+	s := new(big.Int).SetBytes(d[4])
+	d[4] = s.Add(s, big.NewInt(delta)).Bytes()
+	return serialize(d...)
+}
+
+func TestCheckBatch(t *testing.T) {
+	dsaKey := dsaVectorKey()
+
+	var msgs []SignedMessage
+	msgs = append(msgs, signedMessages(t, keys, schemeElgamal, 6)...)
+	msgs = append(msgs, signedMessages(t, keys, schemeSchnorr, 6)...)
+	msgs = append(msgs, signedMessages(t, dsaKey, schemeSchnorr, 4)...)
+	msgs = append(msgs, signedMessages(t, dsaKey, schemeDSA, 3)...)
+	msgs = append(msgs, signedMessages(t, dsaKey, schemeElgamal, 2)...)
+
+	if invalid := ElgamalCheckBatch(rand.Reader, msgs); invalid != nil {
+		t.Fatalf("Signatures valides refusées : %v", invalid)
+	}
+	if invalid := ElgamalCheckBatch(rand.Reader, nil); invalid != nil {
+		t.Errorf("Lot vide refusé : %v", invalid)
+	}
+
+	msgs[1].Data = []byte("document modifié")
+	msgs[4].Signature = shiftSignature(msgs[4].Signature, 1)
+	msgs[7].Data = []byte("document modifié")
+	msgs[13].Signature = shiftSignature(msgs[13].Signature, 1)
+	msgs[17].Data = []byte("document modifié")
+	msgs[19].Data = []byte("document modifié")
+	msgs[10].Pub = &dsaKey.ElgamalPublicKey
+	msgs[11].Signature = []byte("pas une signature")
+
+	want := []int{1, 4, 7, 10, 11, 13, 17, 19}
+	if invalid := ElgamalCheckBatch(rand.Reader, msgs); !reflect.DeepEqual(invalid, want) {
+		t.Errorf("Signatures refusées : %v, attendu %v", invalid, want)
+	}
+}
+
+func TestCheckBatchCancellation(t *testing.T) {
+	// Les erreurs des deux signatures se compensent dans le produit des
+	// équations : seuls les exposants aléatoires permettent de les détecter
+	msgs := signedMessages(t, keys, schemeSchnorr, 3)
+	msgs[0].Signature = shiftSignature(msgs[0].Signature, 1)
+	msgs[2].Signature = shiftSignature(msgs[2].Signature, -1)
+
+	if invalid := ElgamalCheckBatch(rand.Reader, msgs); !reflect.DeepEqual(invalid, []int{0, 2}) {
+		t.Errorf("Signatures refusées : %v, attendu [0 2]", invalid)
+	}
+}
+
+func TestCheckBatchSubgroup(t *testing.T) {
+	msgs := signedMessages(t, keys, schemeSchnorr, 2)
+
+	// -R n'appartient pas au sous-groupe des carrés
+	d := deserialize(msgs[0].Signature)
+	p := new(big.Int).Add(keys.Q, big1)
+	d[3] = new(big.Int).Sub(p, new(big.Int).SetBytes(d[3])).Bytes()
+	msgs[0].Signature = serialize(d...)
+
+	if invalid := ElgamalCheckBatch(rand.Reader, msgs); !reflect.DeepEqual(invalid, []int{0}) {
+		t.Errorf("Signatures refusées : %v, attendu [0]", invalid)
+	}
+}
+
+func TestCheckBatchInvalidKey(t *testing.T) {
+	// p premier non sûr, p ≡ 1 (mod 3) : le sous-groupe des carrés contient
+	// un élément w d'ordre 3
+	var p *big.Int
+	for {
+		n, _ := rand.Prime(rand.Reader, 250)
+		p = new(big.Int).Lsh(n, 6)
+		p.Mul(p, big.NewInt(3)).Add(p, big1)
+		if p.ProbablyPrime(20) {
+			break
+		}
+	}
+	pm1 := new(big.Int).Sub(p, big1)
+	w := new(big.Int).Exp(big2, new(big.Int).Div(pm1, big.NewInt(3)), p)
+	if w.Cmp(big1) == 0 {
+		t.Skip("2 est un cube modulo p")
+	}
+
+	x := randRange(rand.Reader, big1, new(big.Int).Sub(pm1, big1))
+	priv := &ElgamalPrivateKey{
+		ElgamalPublicKey: ElgamalPublicKey{Q: pm1, G: big.NewInt(5), H: new(big.Int).Exp(big.NewInt(5), x, p)},
+		X:                x,
+	}
+	if _, err := priv.generatorOrder(); err != errKeyNotSafePrime {
+		t.Fatal("La clé de test n'est pas reconnue comme une clé à p non sûr :", err)
+	}
+
+	// Signature (R*w, s) : fausse, mais l'erreur w disparaît du produit
+	// des équations une fois sur trois si la clé n'est pas validée
+	msgs := signedMessages(t, priv, schemeSchnorr, 2)
+	gp, q, g, y := schnorrGroup(&priv.ElgamalPublicKey)
+	k := randRange(rand.Reader, big1, new(big.Int).Sub(q, big1))
+	R := new(big.Int).Exp(g, k, gp)
+	R.Mul(R, w).Mod(R, gp)
+	e, _ := schnorrChallenge(crypto.SHA256, gp, q, R, y, bytes.NewReader(msgs[1].Data))
+	s := new(big.Int).Mul(e, x)
+	s.Add(s, k).Mod(s, q)
+	msgs[1].Signature = encodeSignature(schemeSchnorr, crypto.SHA256, &priv.ElgamalPublicKey, R, s)
+
+	for i := 0; i < 20; i++ {
+		if invalid := ElgamalCheckBatch(rand.Reader, msgs); !reflect.DeepEqual(invalid, []int{1}) {
+			t.Fatalf("Signatures refusées : %v, attendu [1]", invalid)
+		}
+	}
+}
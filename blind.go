@@ -0,0 +1,188 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Identifiants des fichiers échangés lors d'une signature aveugle RSA
+const (
+	rsaBlindRequestVersion   = "rsa-blind-request-v1"
+	rsaBlindResponseVersion  = "rsa-blind-response-v1"
+	rsaBlindSecretVersion    = "rsa-blind-secret-v1"
+	rsaBlindSignatureVersion = "rsa-blind-signature-v1"
+)
+
+// Algorithme de hachage des signatures aveugles (RSABSSA-SHA384-PSS,
+// RFC 9474), le sel ayant la taille de l'empreinte
+const rsaBlindHash = crypto.SHA384
+
+// Taille en octets de l'aléa ajouté devant le message (RFC 9474, 4.1)
+const rsaBlindPrefixSize = 32
+
+var (
+	errRSABlindRequest  = errors.New("gocrypto: demande de signature aveugle invalide")
+	errRSABlindResponse = errors.New("gocrypto: signature aveugle invalide")
+	errRSABlindSecret   = errors.New("gocrypto: secret de signature aveugle invalide")
+)
+
+// RSABlindingSecret est conservé par le demandeur entre RSABlind et
+// RSAUnblind : le message préparé (aléa | message) et l'inverse du facteur
+// de masquage
+type RSABlindingSecret struct {
+	Msg []byte
+	Inv *big.Int
+}
+
+// GenerateRSABlindKeys génère une paire de clés RSA de size bits réservée
+// aux signatures aveugles : elle est refusée par RSAEncrypt, RSADecrypt,
+// RSASign et RSACheck, et seule une telle clé est acceptée par RSABlind,
+// RSABlindSign, RSAUnblind et RSABlindCheck.
+func GenerateRSABlindKeys(rand io.Reader, size int) (*RSAPrivateKey, error) {
+	priv, err := GenerateRSAKeys(rand, size)
+	if err != nil {
+		return nil, err
+	}
+	priv.Blind = true
+	return priv, nil
+}
+
+// RSABlind prépare la demande de signature aveugle du message msg pour la
+// clé pub (RFC 9474, RSABSSA-SHA384-PSS-Randomized). Le message est
+// précédé d'un aléa, encodé avec EMSA-PSS puis masqué par r^e pour un r
+// aléatoire : le signataire ne voit ni le message ni, une fois la
+// signature démasquée, de quoi relier la signature à la demande.
+// La demande est envoyée au signataire, le secret est conservé pour
+// RSAUnblind.
+func RSABlind(rand io.Reader, pub *RSAPublicKey, msg []byte) (request []byte, secret *RSABlindingSecret, err error) {
+	if !pub.Blind {
+		return nil, nil, errRSANotBlindKey
+	}
+
+	prepared := append(readRandom(rand, rsaBlindPrefixSize), msg...)
+	salt := readRandom(rand, rsaBlindHash.Size())
+
+	// r aléatoire inversible modulo N
+	var r, inv *big.Int
+	for inv == nil {
+		r = randRange(rand, big2, new(big.Int).Sub(pub.N, big1))
+		inv = new(big.Int).ModInverse(r, pub.N)
+	}
+
+	return rsaBlind(pub, prepared, salt, r, inv)
+}
+
+// Masque le message préparé avec le sel salt et le facteur r, d'inverse
+// inv modulo N (RFC 9474, 4.2)
+func rsaBlind(pub *RSAPublicKey, prepared, salt []byte, r, inv *big.Int) (request []byte, secret *RSABlindingSecret, err error) {
+	em, err := emsaPSSEncode(rsaBlindHash, hash(rsaBlindHash, prepared), salt, pub.N.BitLen()-1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := new(big.Int).SetBytes(em)
+	if new(big.Int).GCD(nil, nil, m, pub.N).Cmp(big1) != 0 {
+		return nil, nil, errInvalidRSAKey
+	}
+
+	// z = m * r^e  (mod N)
+	z := new(big.Int).Exp(r, pub.E, pub.N)
+	z.Mul(z, m)
+	z.Mod(z, pub.N)
+
+	request = serialize([]byte(rsaBlindRequestVersion), fixedBytes(z, pub.size()))
+	return request, &RSABlindingSecret{Msg: prepared, Inv: inv}, nil
+}
+
+// RSABlindSign signe une demande produite par RSABlind sans rien apprendre
+// du message. La signature est une exponentiation RSA brute de la valeur
+// reçue : seule une clé de signature aveugle (voir GenerateRSABlindKeys)
+// est acceptée.
+func RSABlindSign(priv *RSAPrivateKey, request []byte) (response []byte, err error) {
+	if !priv.Blind {
+		return nil, errRSANotBlindKey
+	}
+
+	d := deserialize(request)
+	if len(d) != 2 || string(d[0]) != rsaBlindRequestVersion || len(d[1]) != priv.size() {
+		return nil, errRSABlindRequest
+	}
+
+	z := new(big.Int).SetBytes(d[1])
+	if z.Cmp(priv.N) >= 0 {
+		return nil, errRSABlindRequest
+	}
+
+	s := priv.decryptInt(z)
+
+	// Vérifie le résultat pour ne pas divulguer une signature fausse
+	// (qui permettrait de factoriser N en cas d'erreur dans le CRT)
+	if new(big.Int).Exp(s, priv.E, priv.N).Cmp(z) != 0 {
+		return nil, errInvalidRSAKey
+	}
+
+	return serialize([]byte(rsaBlindResponseVersion), fixedBytes(s, priv.size())), nil
+}
+
+// RSAUnblind démasque la réponse du signataire avec le secret produit par
+// RSABlind et renvoie la signature du message, vérifiée avant d'être
+// renvoyée : version | message préparé | signature RSASSA-PSS
+func RSAUnblind(pub *RSAPublicKey, secret *RSABlindingSecret, response []byte) (signature []byte, err error) {
+	if !pub.Blind {
+		return nil, errRSANotBlindKey
+	}
+
+	d := deserialize(response)
+	if len(d) != 2 || string(d[0]) != rsaBlindResponseVersion || len(d[1]) != pub.size() {
+		return nil, errRSABlindResponse
+	}
+
+	// s = z^d * r⁻¹ = m^d  (mod N)
+	s := new(big.Int).SetBytes(d[1])
+	s.Mul(s, secret.Inv)
+	s.Mod(s, pub.N)
+
+	sig := fixedBytes(s, pub.size())
+	if !RSAVerifyPSS(pub, rsaBlindHash, hash(rsaBlindHash, secret.Msg), sig) {
+		return nil, errRSABlindResponse
+	}
+
+	return serialize([]byte(rsaBlindSignatureVersion), secret.Msg, sig), nil
+}
+
+// RSABlindCheck vérifie une signature produite par RSAUnblind et renvoie
+// le message signé. La signature ne permet pas de retrouver la demande :
+// l'application doit elle-même refuser les signatures déjà présentées.
+func RSABlindCheck(pub *RSAPublicKey, signature []byte) (msg []byte, ok bool) {
+	d := deserialize(signature)
+	if !pub.Blind || len(d) != 3 || string(d[0]) != rsaBlindSignatureVersion || len(d[1]) < rsaBlindPrefixSize {
+		return nil, false
+	}
+
+	if !RSAVerifyPSS(pub, rsaBlindHash, hash(rsaBlindHash, d[1]), d[2]) {
+		return nil, false
+	}
+	return d[1][rsaBlindPrefixSize:], true
+}
+
+// GetBytes renvoie sous forme d'octets le secret : version | message
+// préparé | inverse du facteur de masquage
+func (secret *RSABlindingSecret) GetBytes() []byte {
+	return serialize([]byte(rsaBlindSecretVersion), secret.Msg, secret.Inv.Bytes())
+}
+
+// ParseRSABlindingSecret charge un secret enregistré avec GetBytes
+func ParseRSABlindingSecret(b []byte) (*RSABlindingSecret, error) {
+	d := deserialize(b)
+	if len(d) != 3 || string(d[0]) != rsaBlindSecretVersion || len(d[1]) < rsaBlindPrefixSize {
+		return nil, errRSABlindSecret
+	}
+
+	secret := &RSABlindingSecret{Msg: d[1], Inv: new(big.Int).SetBytes(d[2])}
+	if secret.Inv.Sign() <= 0 {
+		return nil, errRSABlindSecret
+	}
+	return secret, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"strconv"
+)
+
+// Information HKDF de la dérivation des clés AES par Diffie-Hellman,
+// complétée par la taille de la clé en bits
+const dhKeyInfo = "gocrypto dh aes-"
+
+var (
+	errDHGroup   = errors.New("gocrypto: la clé du correspondant n'utilise pas le même groupe")
+	errDHPeer    = errors.New("gocrypto: valeur publique du correspondant invalide")
+	errDHKeySize = errors.New("gocrypto: la clé AES doit faire 128, 192 ou 256 bits")
+)
+
+// Vérifie que la valeur publique h du correspondant ne se trouve pas dans
+// un petit sous-groupe : h doit être dans [2, p-2] et, pour une clé DSA,
+// appartenir au sous-groupe d'ordre SubgroupOrder. p étant sûr pour une
+// clé ElGamal, les seuls petits sous-groupes sont {1} et {1, p-1}.
+func checkDHPeer(peer *ElgamalPublicKey, p *big.Int) error {
+	if peer.H.Cmp(big2) < 0 || peer.H.Cmp(new(big.Int).Sub(peer.Q, big1)) > 0 {
+		return errDHPeer
+	}
+	if q := peer.SubgroupOrder; q != nil && new(big.Int).Exp(peer.H, q, p).Cmp(big1) != 0 {
+		return errDHPeer
+	}
+	return nil
+}
+
+// DHDeriveKey calcule le secret partagé s = h^x entre la clé privée priv et
+// la clé publique peer du correspondant, qui doivent utiliser le même
+// groupe, et en dérive une clé AES de size bits avec HKDF-SHA256. Les deux
+// valeurs publiques, triées, servent de sel : les deux correspondants
+// obtiennent la même clé, propre à leur couple de clés.
+// La clé obtenue est toujours la même pour deux clés données : pour une
+// clé différente à chaque échange, l'une des paires doit être éphémère.
+func DHDeriveKey(priv *ElgamalPrivateKey, peer *ElgamalPublicKey, size int) ([]byte, error) {
+	if size != 128 && size != 192 && size != 256 {
+		return nil, errDHKeySize
+	}
+	if !sameGroup(&priv.ElgamalPublicKey, peer) {
+		return nil, errDHGroup
+	}
+
+	p := new(big.Int).Add(priv.Q, big1)
+	if err := checkDHPeer(peer, p); err != nil {
+		return nil, err
+	}
+
+	s := new(big.Int).Exp(peer.H, priv.X, p)
+	if s.Cmp(big1) == 0 || s.Cmp(priv.Q) == 0 {
+		return nil, errDHPeer
+	}
+
+	pLen := (p.BitLen() + 7) / 8
+	a, b := priv.H, peer.H
+	if a.Cmp(b) > 0 {
+		a, b = b, a
+	}
+	salt := append(fixedBytes(a, pLen), fixedBytes(b, pLen)...)
+
+	key, err := hkdf.Key(sha256.New, fixedBytes(s, pLen), salt, dhKeyInfo+strconv.Itoa(size), size/8)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
+	}
+	return key, nil
+}
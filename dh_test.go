@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestDHDeriveKey(t *testing.T) {
+	a, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	b, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	c, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+
+	for _, size := range []int{128, 192, 256} {
+		ka, err := DHDeriveKey(a, &b.ElgamalPublicKey, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		kb, err := DHDeriveKey(b, &a.ElgamalPublicKey, size)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(ka, kb) || len(ka) != size/8 {
+			t.Errorf("Clés différentes ou de mauvaise taille pour %d bits", size)
+		}
+
+		// La clé est utilisable avec AES
+		data := []byte("message confidentiel")
+		if d := AESDecrypt(AESEncrypt(append([]byte{}, data...), ka), kb); !bytes.Equal(d, data) {
+			t.Errorf("Echec du chiffrement AES avec la clé de %d bits", size)
+		}
+	}
+
+	ka, _ := DHDeriveKey(a, &b.ElgamalPublicKey, 128)
+	kc, _ := DHDeriveKey(a, &c.ElgamalPublicKey, 128)
+	if bytes.Equal(ka, kc) {
+		t.Error("Même clé pour deux correspondants différents")
+	}
+
+	if _, err := DHDeriveKey(a, &b.ElgamalPublicKey, 100); err != errDHKeySize {
+		t.Errorf("Erreur inattendue pour une taille invalide : %v", err)
+	}
+
+	other, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe3072")
+	if _, err := DHDeriveKey(a, &other.ElgamalPublicKey, 128); err != errDHGroup {
+		t.Errorf("Erreur inattendue pour un autre groupe : %v", err)
+	}
+}
+
+func TestDHInvalidPeer(t *testing.T) {
+	a, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+
+	// Valeurs des petits sous-groupes
+	for _, h := range []*big.Int{big.NewInt(0), big1, a.Q, new(big.Int).Add(a.Q, big1)} {
+		peer := a.ElgamalPublicKey
+		peer.H = h
+		if _, err := DHDeriveKey(a, &peer, 128); err != errDHPeer {
+			t.Errorf("Valeur publique %v acceptée", h)
+		}
+	}
+
+	// Pour une clé DSA, h doit appartenir au sous-groupe d'ordre q
+	priv := dsaVectorKey()
+	peer := priv.ElgamalPublicKey
+	peer.H = new(big.Int).Sub(priv.Q, big1)
+	if _, err := DHDeriveKey(priv, &peer, 128); err != errDHPeer {
+		t.Error("Valeur publique hors du sous-groupe acceptée")
+	}
+}
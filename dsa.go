@@ -0,0 +1,287 @@
+package main
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Nom du schéma DSA enregistré dans les signatures
+const schemeDSA = "dsa"
+
+// Nombre de tours de Miller-Rabin pour p et q (FIPS 186-4, table C.1)
+const dsaPrimeRounds = 64
+
+// Taille de la graine utilisée pour générer p et q, en octets
+const dsaSeedSize = sha256.Size
+
+var (
+	errDSASizes   = errors.New("gocrypto: tailles (L, N) non autorisées par FIPS 186-4")
+	errDSASeed    = errors.New("gocrypto: graine incapable de produire p et q")
+	errNoDSAGroup = errors.New("gocrypto: la clé ne contient pas de sous-groupe d'ordre premier")
+)
+
+// DSAParameters représente les paramètres de domaine DSA : q est premier,
+// q divise p-1 et g engendre le sous-groupe d'ordre q de Zp*
+type DSAParameters struct {
+	P *big.Int
+	Q *big.Int
+	G *big.Int
+}
+
+// Renvoie la taille N de q associée à une taille L de p
+func dsaSubgroupSize(L int) (N int, err error) {
+	switch L {
+	case 1024:
+		return 160, nil
+	case 2048, 3072:
+		return 256, nil
+	default:
+		return 0, errDSASizes
+	}
+}
+
+// Vérifie que le couple (L, N) fait partie des tailles autorisées
+func dsaCheckSizes(L, N int) error {
+	switch {
+	case L == 1024 && N == 160,
+		L == 2048 && (N == 224 || N == 256),
+		L == 3072 && N == 256:
+		return nil
+	default:
+		return errDSASizes
+	}
+}
+
+// Renvoie vrai si n n'a aucun petit facteur et passe les tests de
+// Miller-Rabin
+func dsaIsPrime(n *big.Int) bool {
+	r := new(big.Int)
+	for _, q := range smallPrimes {
+		if r.Mod(n, r.SetUint64(q)).Sign() == 0 {
+			return false
+		}
+	}
+	return probablyPrime(n, dsaPrimeRounds)
+}
+
+// Génère p et q à partir de la graine seed selon FIPS 186-4 A.1.1.2
+// (nombres premiers probables, SHA-256). Renvoie également le compteur
+// permettant de vérifier la génération.
+func dsaPrimesFromSeed(L, N int, seed []byte) (p, q *big.Int, counter int, err error) {
+	const outlen = sha256.Size * 8
+
+	// Étapes 3 et 4
+	n := (L+outlen-1)/outlen - 1
+	b := L - 1 - n*outlen
+
+	// Étapes 6 et 7 : q = 2^(N-1) + U + 1 - (U mod 2)
+	U := new(big.Int).SetBytes(hash(crypto.SHA256, seed))
+	U.Mod(U, new(big.Int).Lsh(big1, uint(N-1)))
+
+	q = new(big.Int).Lsh(big1, uint(N-1))
+	q.Add(q, U)
+	q.Add(q, big1)
+	q.Sub(q, new(big.Int).And(U, big1))
+
+	// Étape 8
+	if !dsaIsPrime(q) {
+		return nil, nil, 0, errDSASeed
+	}
+
+	var (
+		seedInt   = new(big.Int).SetBytes(seed)
+		seedMod   = new(big.Int).Lsh(big1, uint(len(seed)*8))
+		pMin      = new(big.Int).Lsh(big1, uint(L-1))
+		bMod      = new(big.Int).Lsh(big1, uint(b))
+		twoQ      = new(big.Int).Lsh(q, 1)
+		V, W, X   = new(big.Int), new(big.Int), new(big.Int)
+		c, offset = new(big.Int), 1
+	)
+
+	// Étape 11
+	for counter = 0; counter < 4*L; counter++ {
+		W.SetInt64(0)
+
+		for j := 0; j <= n; j++ {
+			// V_j = Hash((seed + offset + j) mod 2^seedlen)
+			V.SetInt64(int64(offset + j))
+			V.Add(V, seedInt)
+			V.Mod(V, seedMod)
+			V.SetBytes(hash(crypto.SHA256, fixedBytes(V, len(seed))))
+
+			if j == n {
+				V.Mod(V, bMod)
+			}
+			W.Add(W, V.Lsh(V, uint(j*outlen)))
+		}
+
+		// X = W + 2^(L-1), p = X - (X mod 2q - 1)
+		X.Add(W, pMin)
+		c.Mod(X, twoQ)
+		p = new(big.Int).Sub(X, c.Sub(c, big1))
+
+		if p.Cmp(pMin) >= 0 && dsaIsPrime(p) {
+			return p, q, counter, nil
+		}
+
+		offset += n + 1
+	}
+
+	return nil, nil, 0, errDSASeed
+}
+
+// Calcule un générateur du sous-groupe d'ordre q selon FIPS 186-4 A.2.1 :
+// g = h^((p-1)/q) avec h = 2, 3, ... jusqu'à obtenir g != 1
+func dsaGenerator(p, q *big.Int) *big.Int {
+	e := new(big.Int).Sub(p, big1)
+	e.Div(e, q)
+
+	g := new(big.Int)
+	for h := big.NewInt(2); ; h.Add(h, big1) {
+		if g.Exp(h, e, p).Cmp(big1) != 0 {
+			return g
+		}
+	}
+}
+
+// GenerateDSAParameters génère des paramètres de domaine DSA avec p de
+// L bits et q de N bits
+func GenerateDSAParameters(rand io.Reader, L, N int) (*DSAParameters, error) {
+	if err := dsaCheckSizes(L, N); err != nil {
+		return nil, err
+	}
+
+	for {
+		p, q, _, err := dsaPrimesFromSeed(L, N, readRandom(rand, dsaSeedSize))
+		if err == errDSASeed {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &DSAParameters{P: p, Q: q, G: dsaGenerator(p, q)}, nil
+	}
+}
+
+// GenerateDSAKeys génère une paire de clés dans le groupe params. La clé
+// est une clé ElGamal dont le générateur engendre le sous-groupe d'ordre
+// params.Q, enregistré dans le champ SubgroupOrder.
+func GenerateDSAKeys(rand io.Reader, params *DSAParameters) *ElgamalPrivateKey {
+	// Calcul de x dans [1, q-1]
+	x := randRange(rand, big1, new(big.Int).Sub(params.Q, big1))
+
+	// Calcul de y = g^x
+	y := new(big.Int).Exp(params.G, x, params.P)
+
+	return &ElgamalPrivateKey{
+		ElgamalPublicKey: ElgamalPublicKey{
+			Q:             new(big.Int).Sub(params.P, big1),
+			G:             params.G,
+			H:             y,
+			SubgroupOrder: params.Q,
+		},
+		X: x,
+	}
+}
+
+// Convertit une empreinte en entier en ne gardant que ses N bits de
+// poids fort, où N est la taille de q (FIPS 186-4, 4.6)
+func dsaHashToInt(digest []byte, q *big.Int) *big.Int {
+	z := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - q.BitLen(); excess > 0 {
+		z.Rsh(z, uint(excess))
+	}
+	return z
+}
+
+// Signe l'empreinte digest (calculée avec h) avec DSA
+func dsaSign(rand io.Reader, priv *ElgamalPrivateKey, digest []byte, h crypto.Hash) (signature []byte, err error) {
+	q := priv.SubgroupOrder
+	if q == nil {
+		return nil, errNoDSAGroup
+	}
+
+	// Calcul du nombre de d'élément de Zp
+	p := new(big.Int).Add(priv.Q, big1)
+
+	z := dsaHashToInt(digest, q)
+
+	nonces := newNonceGenerator(rand, h, q, priv.X, digest)
+	for {
+		// k est dérivé de x et de l'empreinte (RFC 6979), entre 1 et (q-1)
+		if r, s := dsaSignNonce(priv, p, z, nonces.next()); r != nil {
+			return encodeSignature(schemeDSA, h, &priv.ElgamalPublicKey, r, s), nil
+		}
+	}
+}
+
+// Calcule la signature (r, s) de z avec le nonce k. Renvoie nil si r ou s
+// est nul : un autre nonce doit alors être utilisé.
+func dsaSignNonce(priv *ElgamalPrivateKey, p, z, k *big.Int) (r, s *big.Int) {
+	q := priv.SubgroupOrder
+
+	// r = (g^k mod p) mod q
+	r = new(big.Int).Exp(priv.G, k, p)
+	r.Mod(r, q)
+	if r.Sign() == 0 {
+		return nil, nil
+	}
+
+	// s = k⁻¹ (z + x*r)  (mod q)
+	s = new(big.Int).Mul(priv.X, r)
+	s.Add(s, z)
+	s.Mul(s, new(big.Int).ModInverse(k, q))
+	s.Mod(s, q)
+	if s.Sign() == 0 {
+		return nil, nil
+	}
+	return r, s
+}
+
+// Vérifie la signature DSA (r, s) de l'empreinte digest
+func dsaCheck(pub *ElgamalPublicKey, digest []byte, h crypto.Hash, r, s *big.Int) bool {
+	q := pub.SubgroupOrder
+	if q == nil {
+		return false
+	}
+
+	// On doit avoir 0 < r < q et 0 < s < q
+	if r.Sign() <= 0 || r.Cmp(q) >= 0 || s.Sign() <= 0 || s.Cmp(q) >= 0 {
+		return false
+	}
+
+	// Calcul du nombre de d'élément de Zp
+	p := new(big.Int).Add(pub.Q, big1)
+
+	z := dsaHashToInt(digest, q)
+
+	// w = s⁻¹, u1 = z*w, u2 = r*w  (mod q)
+	w := new(big.Int).ModInverse(s, q)
+	u1 := new(big.Int).Mul(z, w)
+	u1.Mod(u1, q)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, q)
+
+	// v = (g^u1 * y^u2 mod p) mod q
+	v := new(big.Int).Exp(pub.G, u1, p)
+	v.Mul(v, new(big.Int).Exp(pub.H, u2, p))
+	v.Mod(v, p)
+	v.Mod(v, q)
+
+	return v.Cmp(r) == 0
+}
+
+// DSASign signe le document "data" avec DSA (FIPS 186-4) et concataine la
+// signature au document. La clé doit contenir des paramètres DSA
+// (voir GenerateDSAKeys). rand est utilisé comme pour ElgamalSign.
+func DSASign(rand io.Reader, priv *ElgamalPrivateKey, data []byte, h crypto.Hash) (signedData []byte, err error) {
+	signature, err := dsaSign(rand, priv, hash(h, data), h)
+	if err != nil {
+		return nil, err
+	}
+	return serialize(data, signature), nil
+}
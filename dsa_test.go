@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/dsa"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Vecteur de test L=2048, N=256, SHA-256 produit par OpenSSL 3.0
+// (genpkey -genparam -pkeyopt type:fips186_4, puis dgst -sha256 -sign)
+var dsaVector = struct {
+	P, Q, G, Seed string
+	Counter       int
+	X, Y          string
+	Msg           string
+	R, S          string
+}{
+	P: "8CEF6B25152EAAB0266CFA5D088E0CE138550E5F5B9976A9D51A55A126477AB7" +
+		"98DF6D53E15D24ED280E7EDE82DAB5C1C4099DB23CB05213C24D0F2E387E4434" +
+		"5F2F847D8E47F3401BBC4E78AF0FD7D1F27E3F3A0EAA6C1D41470138816436AF" +
+		"93B3B473F5FB6951F17A71DCC83711FD4ABD160D5574233AC73A2BF65DE19362" +
+		"1E5FA98BC46A087764678D2DF1AE566FB178D7D94FB19D1DC1E63E9234C3AFD3" +
+		"A05A2F1B749A6ACF159D82ABF6FFF54E94A72E1714C587B9451A06FC690311B5" +
+		"0898E4972A05C019428DF2A4835D5EE982EBD2BFB82DF85674F58C9099ABDC5A" +
+		"5B05B6C8617DDBE86C0151E17EBEC9C351F55B849ABDEF9ECF6E1243A1A24837",
+	Q: "BF3FA0C0C86CC43791B06D281CFAD56D97A662948BAB3AC8FCA007E853F8133B",
+	G: "1FC201A8ABCEDD02CCD03B49FA9323989EC34A56B62219ECF0916333ABDE4FDC" +
+		"9D6ED20C144458C9C8A2FDAF60D58B5AAA4CCB8613403E1EEC720F2CCBF4AB84" +
+		"32DF5E01F823017B9EF8E675ABA3AC5489021704AFA7C887A8472B3294DC1805" +
+		"019BE7025B938EBC0A115DE916D209FD3CE48EE3B117AC33118E0E6CCE8CAFAA" +
+		"5DC569A44C72D7A94F67B8EEF2D632DB6828465320AFD22EEB604B472CCB26C6" +
+		"936C15056F31CC99FC8F9A0EE4EC7EC70393C5A0508491E1C0D4E858F58DCDDB" +
+		"CECE24B2A3CCB02A122391710BEC6C1AE628F7F3CF347559CC4ECDB4D1F846A6" +
+		"30D9E97E48C59066A14D167D2B8F8CCE02264BC5F45CB4463C7710365B24970",
+	Seed:    "48877DAA0B9A27B279FF48F4C8EFEAC44AD6898F70640B32F0424962BEDBD1E2",
+	Counter: 329,
+	X:       "6A8B4519D7F5F1FBFAF116D5CE55207D74D4FD710F71F507F96095B5531EC577",
+	Y: "6854BEF7B85165D46AF17CB213B53181DC874F1A25B97531EA59166783994289" +
+		"5971CD3148F82FE4DF8BC47C22CB1CAD62A6B3D286DBB913A072B16F3496CF1F" +
+		"57E1D41C965E199E622F106F0BF3B69573A7BC013F968CDE62D7B7D9F49478BA" +
+		"2D27F726F084FC790B6C1BD8BF6914C96966F5E32A9703536EE1C96073787BB1" +
+		"96A0A0CA48172B87861D826AD9698AFB98AD90C2E1CC71E30EDEB5E470C83611" +
+		"F50BE36A36092D3068C43549EC94BD9F843C7CA4036CA80D83C17D7EA4733ABC" +
+		"73B97619383AD3CA006047B97D27F44319F2BF94279DCB764B3C4561E3952A9F" +
+		"3FF40F77C570448F7660136D5ED4AF09F95A442960C847C859ED359C36F13EE7",
+	Msg: "Message de test pour DSA",
+	R:   "36FE36EACF8E0EF6A22D15A30772E366C431BA3805E06C1AAA484CBC89697967",
+	S:   "2110A962B0E3C9113282928D5B6043BDBBA4EBFC763ED636D2A6908EE86FBFD1",
+}
+
+func dsaVectorKey() *ElgamalPrivateKey {
+	p := mustHex(dsaVector.P)
+	return &ElgamalPrivateKey{
+		ElgamalPublicKey: ElgamalPublicKey{
+			Q:             new(big.Int).Sub(p, big1),
+			G:             mustHex(dsaVector.G),
+			H:             mustHex(dsaVector.Y),
+			SubgroupOrder: mustHex(dsaVector.Q),
+		},
+		X: mustHex(dsaVector.X),
+	}
+}
+
+func TestDSAParameterGenerationVector(t *testing.T) {
+	seed, _ := hex.DecodeString(dsaVector.Seed)
+
+	p, q, counter, err := dsaPrimesFromSeed(2048, 256, seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Cmp(mustHex(dsaVector.P)) != 0 || q.Cmp(mustHex(dsaVector.Q)) != 0 || counter != dsaVector.Counter {
+		t.Error("La génération de p et q ne correspond pas au vecteur de test")
+	}
+
+	if dsaGenerator(p, q).Cmp(mustHex(dsaVector.G)) != 0 {
+		t.Error("Le générateur ne correspond pas au vecteur de test")
+	}
+}
+
+func TestDSAVerifyVector(t *testing.T) {
+	pub := &dsaVectorKey().ElgamalPublicKey
+	r, s := mustHex(dsaVector.R), mustHex(dsaVector.S)
+
+	if !dsaCheck(pub, hash(crypto.SHA256, []byte(dsaVector.Msg)), crypto.SHA256, r, s) {
+		t.Error("La signature du vecteur de test n'est pas valide")
+	}
+
+	if dsaCheck(pub, hash(crypto.SHA256, []byte(dsaVector.Msg+".")), crypto.SHA256, r, s) {
+		t.Error("La signature du vecteur de test est valide pour un autre message")
+	}
+
+	if dsaCheck(pub, hash(crypto.SHA256, []byte(dsaVector.Msg)), crypto.SHA256, s, r) {
+		t.Error("Une signature invalide a été acceptée")
+	}
+}
+
+func TestDSASignature(t *testing.T) {
+	priv := dsaVectorKey()
+	data := randomBytes(1000)
+
+	signedData, err := DSASign(rand.Reader, priv, data, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !ElgamalCheck(&priv.ElgamalPublicKey, signedData) {
+		t.Error("Echec de la vérification de la signature DSA")
+	}
+
+	// Vérification croisée avec crypto/dsa
+	d := deserialize(deserialize(signedData)[1])
+	pub := dsa.PublicKey{
+		Parameters: dsa.Parameters{
+			P: new(big.Int).Add(priv.Q, big1),
+			Q: priv.SubgroupOrder,
+			G: priv.G,
+		},
+		Y: priv.H,
+	}
+	if !dsa.Verify(&pub, hash(crypto.SHA256, data), new(big.Int).SetBytes(d[3]), new(big.Int).SetBytes(d[4])) {
+		t.Error("crypto/dsa refuse la signature DSA")
+	}
+}
+
+func TestDSAWithoutSubgroup(t *testing.T) {
+	if _, err := DSASign(rand.Reader, keys, []byte("abc"), crypto.SHA256); err == nil {
+		t.Error("Une clé sans sous-groupe d'ordre premier a été acceptée pour DSA")
+	}
+}
+
+func TestDSAKeyGeneration(t *testing.T) {
+	params, err := GenerateDSAParameters(rand.Reader, 1024, 160)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if params.P.BitLen() != 1024 || params.Q.BitLen() != 160 {
+		t.Error("Les paramètres DSA n'ont pas la bonne taille")
+	}
+
+	pMinus1 := new(big.Int).Sub(params.P, big1)
+	if new(big.Int).Mod(pMinus1, params.Q).Sign() != 0 {
+		t.Error("q ne divise pas p-1")
+	}
+	if new(big.Int).Exp(params.G, params.Q, params.P).Cmp(big1) != 0 {
+		t.Error("g n'est pas d'ordre q")
+	}
+
+	priv := GenerateDSAKeys(rand.Reader, params)
+	tpriv := LoadPrivateKey(priv.GetBytes())
+	if tpriv.SubgroupOrder == nil || tpriv.SubgroupOrder.Cmp(params.Q) != 0 {
+		t.Error("L'ordre du sous-groupe n'a pas été enregistré avec la clé")
+	}
+
+	signedData, _ := DSASign(rand.Reader, tpriv, []byte("abc"), crypto.SHA512)
+	if !ElgamalCheck(LoadPublicKey(priv.ElgamalPublicKey.GetBytes()), signedData) {
+		t.Error("Echec de la vérification de la signature DSA")
+	}
+}
+
+// Cas de vérification sur le modèle des fichiers SigVer du CAVP
+// (FIPS 186-4) : chaque signature, produite ou vérifiée de façon croisée
+// par crypto/dsa, est accompagnée du résultat attendu. Les fichiers du
+// CAVP eux-mêmes sont vérifiés par TestDSACAVPSigVer.
+func TestDSASigVer(t *testing.T) {
+	priv := dsaVectorKey()
+	pub := &priv.ElgamalPublicKey
+	q := pub.SubgroupOrder
+
+	ref := dsa.PrivateKey{
+		PublicKey: dsa.PublicKey{
+			Parameters: dsa.Parameters{P: new(big.Int).Add(pub.Q, big1), Q: q, G: pub.G},
+			Y:          pub.H,
+		},
+		X: priv.X,
+	}
+
+	msg := []byte(dsaVector.Msg)
+	digest := hash(crypto.SHA256, msg)
+	r, s, err := dsa.Sign(rand.Reader, &ref, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := dsaVectorKey()
+	other.H = new(big.Int).Exp(other.G, big2, new(big.Int).Add(other.Q, big1))
+
+	for _, c := range []struct {
+		name   string
+		pub    *ElgamalPublicKey
+		digest []byte
+		r, s   *big.Int
+		result bool
+	}{
+		{"vecteur OpenSSL", pub, digest, mustHex(dsaVector.R), mustHex(dsaVector.S), true},
+		{"signature crypto/dsa", pub, digest, r, s, true},
+		{"message modifié", pub, hash(crypto.SHA256, append(msg, '.')), r, s, false},
+		{"r modifié", pub, digest, new(big.Int).Add(r, big1), s, false},
+		{"s modifié", pub, digest, r, new(big.Int).Add(s, big1), false},
+		{"clé modifiée", &other.ElgamalPublicKey, digest, r, s, false},
+		{"r nul", pub, digest, big.NewInt(0), s, false},
+		{"s nul", pub, digest, r, big.NewInt(0), false},
+		{"r + q", pub, digest, new(big.Int).Add(r, q), s, false},
+		{"s + q", pub, digest, r, new(big.Int).Add(s, q), false},
+		{"r = q", pub, digest, q, s, false},
+	} {
+		if got := dsaCheck(c.pub, c.digest, crypto.SHA256, c.r, c.s); got != c.result {
+			t.Errorf("%s : résultat %v, attendu %v", c.name, got, c.result)
+		}
+		if got := c.r.Sign() > 0 && c.s.Sign() > 0 && dsa.Verify(&dsa.PublicKey{
+			Parameters: ref.Parameters, Y: c.pub.H,
+		}, c.digest, c.r, c.s); got != c.result {
+			t.Errorf("%s : crypto/dsa renvoie %v, attendu %v", c.name, got, c.result)
+		}
+	}
+}
+
+// Vérifie les paramètres et les clés produits par GenerateDSAParameters et
+// GenerateDSAKeys comme le font les tests PQGVer et KeyPair du CAVP
+// (FIPS 186-4, A.1.1.3, A.2.2 et B.1)
+func TestDSAPQGKeyPair(t *testing.T) {
+	for _, sizes := range [][2]int{{1024, 160}, {2048, 224}} {
+		L, N := sizes[0], sizes[1]
+		params, err := GenerateDSAParameters(rand.Reader, L, N)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, q, g := params.P, params.Q, params.G
+
+		if p.BitLen() != L || q.BitLen() != N {
+			t.Errorf("(%d, %d) : tailles de p et q incorrectes", L, N)
+		}
+		if !p.ProbablyPrime(20) || !q.ProbablyPrime(20) {
+			t.Errorf("(%d, %d) : p ou q n'est pas premier", L, N)
+		}
+		if new(big.Int).Mod(new(big.Int).Sub(p, big1), q).Sign() != 0 {
+			t.Errorf("(%d, %d) : q ne divise pas p-1", L, N)
+		}
+		if g.Cmp(big2) < 0 || g.Cmp(new(big.Int).Sub(p, big1)) >= 0 ||
+			new(big.Int).Exp(g, q, p).Cmp(big1) != 0 {
+			t.Errorf("(%d, %d) : g n'engendre pas le sous-groupe d'ordre q", L, N)
+		}
+
+		for i := 0; i < 5; i++ {
+			priv := GenerateDSAKeys(rand.Reader, params)
+			if priv.X.Sign() <= 0 || priv.X.Cmp(q) >= 0 {
+				t.Errorf("(%d, %d) : x hors de [1, q-1]", L, N)
+			}
+			if priv.H.Cmp(new(big.Int).Exp(g, priv.X, p)) != 0 {
+				t.Errorf("(%d, %d) : y différent de g^x", L, N)
+			}
+			if err := priv.Validate(); err != nil {
+				t.Errorf("(%d, %d) : %v", L, N, err)
+			}
+		}
+	}
+}
+
+// En-tête des sections des fichiers du CAVP vérifiées : ce sont les seules
+// tailles et la seule fonction de hachage de dsaPrimesFromSeed
+const cavpSectionDSA = "L=2048, N=256, SHA-256"
+
+// Lit les enregistrements des sections cavpSectionDSA du fichier de
+// réponses du CAVP testdata/cavp/name (FIPS 186-4, fichiers SigGen.txt,
+// SigVer.rsp et PQGVer.rsp de 186-3dsatestvectors.zip). Les noms des
+// champs sont mis en minuscules ; un bloc ne contenant que P, Q et G est
+// commun aux enregistrements qui le suivent et recopié dans chacun d'eux.
+// Le test est ignoré si le fichier n'a pas été téléchargé.
+func readCAVPFile(t *testing.T, name string) []map[string]string {
+	f, err := os.Open(filepath.Join("testdata", "cavp", name))
+	if os.IsNotExist(err) {
+		t.Skipf("testdata/cavp/%s absent : fichier de 186-3dsatestvectors.zip (CAVP, NIST)", name)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var records []map[string]string
+	var common, block map[string]string
+	selected := false
+
+	flush := func() {
+		if block == nil || !selected {
+			block = nil
+			return
+		}
+		group := true
+		for k := range block {
+			if k != "p" && k != "q" && k != "g" {
+				group = false
+			}
+		}
+		if group {
+			common = block
+		} else {
+			for k, v := range common {
+				if _, ok := block[k]; !ok {
+					block[k] = v
+				}
+			}
+			records = append(records, block)
+		}
+		block = nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+		case line[0] == '#':
+		case line[0] == '[':
+			flush()
+			selected = strings.Contains(line, cavpSectionDSA)
+			common = nil
+		default:
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				t.Fatalf("%s : ligne invalide %q", name, line)
+			}
+			if block == nil {
+				block = make(map[string]string)
+			}
+			block[strings.ToLower(strings.TrimSpace(k))] = strings.TrimSpace(v)
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) == 0 {
+		t.Fatalf("%s : aucun vecteur %s", name, cavpSectionDSA)
+	}
+	return records
+}
+
+// Renvoie le champ hexadécimal key de l'enregistrement record
+func cavpInt(t *testing.T, record map[string]string, key string) *big.Int {
+	n, ok := new(big.Int).SetString(record[key], 16)
+	if !ok {
+		t.Fatalf("Champ %s invalide : %q", key, record[key])
+	}
+	return n
+}
+
+// Renvoie l'empreinte SHA-256 du champ Msg de l'enregistrement record
+func cavpDigest(t *testing.T, record map[string]string) []byte {
+	msg, err := hex.DecodeString(record["msg"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash(crypto.SHA256, msg)
+}
+
+// Renvoie la clé DSA (P, Q, G, Y [, X]) de l'enregistrement record
+func cavpKey(t *testing.T, record map[string]string) *ElgamalPrivateKey {
+	priv := &ElgamalPrivateKey{
+		ElgamalPublicKey: ElgamalPublicKey{
+			Q:             new(big.Int).Sub(cavpInt(t, record, "p"), big1),
+			G:             cavpInt(t, record, "g"),
+			H:             cavpInt(t, record, "y"),
+			SubgroupOrder: cavpInt(t, record, "q"),
+		},
+	}
+	if _, ok := record["x"]; ok {
+		priv.X = cavpInt(t, record, "x")
+	}
+	return priv
+}
+
+// Indique si le champ Result d'un enregistrement annonce un succès
+// (« P ») ou un échec (« F (raison) »)
+func cavpResult(t *testing.T, record map[string]string) bool {
+	switch result := record["result"]; {
+	case strings.HasPrefix(result, "P"):
+		return true
+	case strings.HasPrefix(result, "F"):
+		return false
+	default:
+		t.Fatalf("Résultat invalide : %q", result)
+		return false
+	}
+}
+
+// Génération de signatures avec le nonce K imposé par le CAVP
+func TestDSACAVPSigGen(t *testing.T) {
+	for i, record := range readCAVPFile(t, "SigGen.txt") {
+		priv := cavpKey(t, record)
+		p := new(big.Int).Add(priv.Q, big1)
+		digest := cavpDigest(t, record)
+
+		if priv.H.Cmp(new(big.Int).Exp(priv.G, priv.X, p)) != 0 {
+			t.Errorf("Vecteur %d : y différent de g^x", i)
+		}
+
+		r, s := dsaSignNonce(priv, p, dsaHashToInt(digest, priv.SubgroupOrder), cavpInt(t, record, "k"))
+		if r == nil || r.Cmp(cavpInt(t, record, "r")) != 0 || s.Cmp(cavpInt(t, record, "s")) != 0 {
+			t.Errorf("Vecteur %d : signature différente de celle du CAVP", i)
+		}
+		if !dsaCheck(&priv.ElgamalPublicKey, digest, crypto.SHA256, cavpInt(t, record, "r"), cavpInt(t, record, "s")) {
+			t.Errorf("Vecteur %d : la signature du CAVP a été refusée", i)
+		}
+	}
+}
+
+// Vérification de signatures, dont celles que le CAVP déclare invalides
+// (message, clé, r ou s modifiés)
+func TestDSACAVPSigVer(t *testing.T) {
+	passed, failed := 0, 0
+	for i, record := range readCAVPFile(t, "SigVer.rsp") {
+		pub := &cavpKey(t, record).ElgamalPublicKey
+		want := cavpResult(t, record)
+
+		got := dsaCheck(pub, cavpDigest(t, record), crypto.SHA256, cavpInt(t, record, "r"), cavpInt(t, record, "s"))
+		if got != want {
+			t.Errorf("Vecteur %d : résultat %v, attendu %v (%s)", i, got, want, record["result"])
+		}
+		if want {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	if passed == 0 || failed == 0 {
+		t.Errorf("SigVer.rsp : %d vecteurs valides et %d invalides", passed, failed)
+	}
+}
+
+// Vérification de p et q générés à partir d'une graine (A.1.1.2, nombres
+// premiers probables), dont les paramètres que le CAVP déclare invalides.
+// Les enregistrements sans graine (vérification de g, A.2) sont ignorés.
+func TestDSACAVPPQGVer(t *testing.T) {
+	passed, failed := 0, 0
+	for i, record := range readCAVPFile(t, "PQGVer.rsp") {
+		seedField, counterField := "domain_parameter_seed", "counter"
+		if _, ok := record[seedField]; !ok {
+			seedField, counterField = "seed", "c"
+		}
+		if _, ok := record[seedField]; !ok {
+			continue
+		}
+
+		seed, err := hex.DecodeString(record[seedField])
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter, err := strconv.Atoi(record[counterField])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := cavpResult(t, record)
+
+		p, q, c, err := dsaPrimesFromSeed(2048, 256, seed)
+		got := err == nil && c == counter &&
+			p.Cmp(cavpInt(t, record, "p")) == 0 && q.Cmp(cavpInt(t, record, "q")) == 0
+		if got != want {
+			t.Errorf("Vecteur %d : résultat %v, attendu %v (%s)", i, got, want, record["result"])
+		}
+		if want {
+			passed++
+		} else {
+			failed++
+		}
+	}
+	if passed == 0 || failed == 0 {
+		t.Errorf("PQGVer.rsp : %d vecteurs valides et %d invalides", passed, failed)
+	}
+}
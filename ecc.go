@@ -0,0 +1,339 @@
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Courbes elliptiques supportées
+const (
+	curveP256       = "p256"
+	curveCurve25519 = "curve25519"
+)
+
+// Identifiant du format de chiffrement ECIES (ECDH + HKDF + AES-GCM)
+const eccECIESVersion = "ecc-ecies-v1"
+
+// Informations de contexte utilisées lors de la dérivation de la clé AES
+const eccECIESInfo = "gocrypto ecc ecies aes-256-gcm"
+
+var (
+	errUnknownCurve  = errors.New("gocrypto: courbe elliptique inconnue")
+	errInvalidECCKey = errors.New("gocrypto: clé ECC invalide")
+)
+
+// ECCPublicKey représente une clé publique sur une courbe elliptique
+type ECCPublicKey struct {
+	Curve string // Curve est le nom de la courbe (p256 ou curve25519)
+
+	// Point est le point public encodé :
+	//  - p256 : point SEC 1 non compressé (65 octets)
+	//  - curve25519 : clé publique Ed25519 (32 octets), la clé X25519
+	//    utilisée pour le chiffrement en est déduite
+	Point []byte
+}
+
+// ECCPrivateKey représente une paire de clés sur une courbe elliptique
+type ECCPrivateKey struct {
+	ECCPublicKey
+
+	// D est le secret de 32 octets : le scalaire pour p256, la graine
+	// Ed25519 pour curve25519
+	D []byte
+}
+
+// GetBytes renvoie sous forme d'octets la clé publique : courbe | point
+func (pub *ECCPublicKey) GetBytes() []byte {
+	return serialize([]byte(pub.Curve), pub.Point)
+}
+
+// GetBytes renvoie sous forme d'octets la clé privée : courbe | point | D
+func (priv *ECCPrivateKey) GetBytes() []byte {
+	return serialize([]byte(priv.Curve), priv.Point, priv.D)
+}
+
+// LoadECCPublicKey charge une clé publique écrite avec GetBytes
+func LoadECCPublicKey(b []byte) (*ECCPublicKey, error) {
+	v := deserialize(b)
+	if len(v) != 2 {
+		return nil, errInvalidECCKey
+	}
+
+	pub := &ECCPublicKey{Curve: string(v[0]), Point: v[1]}
+	if _, err := pub.ecdhKey(); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
+// LoadECCPrivateKey charge une clé privée écrite avec GetBytes
+func LoadECCPrivateKey(b []byte) (*ECCPrivateKey, error) {
+	v := deserialize(b)
+	if len(v) != 3 {
+		return nil, errInvalidECCKey
+	}
+
+	priv, err := newECCKey(string(v[0]), v[2])
+	if err != nil {
+		return nil, err
+	}
+
+	// Le point enregistré doit correspondre au secret
+	if string(priv.Point) != string(v[1]) {
+		return nil, errInvalidECCKey
+	}
+	return priv, nil
+}
+
+// Construit la paire de clés associée au secret d sur la courbe curve
+func newECCKey(curve string, d []byte) (*ECCPrivateKey, error) {
+	var point []byte
+
+	switch curve {
+	case curveP256:
+		k, err := ecdh.P256().NewPrivateKey(d)
+		if err != nil {
+			return nil, errInvalidECCKey
+		}
+		point = k.PublicKey().Bytes()
+	case curveCurve25519:
+		if len(d) != ed25519.SeedSize {
+			return nil, errInvalidECCKey
+		}
+		point = ed25519.NewKeyFromSeed(d).Public().(ed25519.PublicKey)
+	default:
+		return nil, errUnknownCurve
+	}
+
+	return &ECCPrivateKey{
+		ECCPublicKey: ECCPublicKey{Curve: curve, Point: point},
+		D:            d,
+	}, nil
+}
+
+// GenerateECCKeys génère une paire de clés sur la courbe curve
+// (p256 ou curve25519) en lisant l'aléa depuis rand
+func GenerateECCKeys(rand io.Reader, curve string) (*ECCPrivateKey, error) {
+	for {
+		priv, err := newECCKey(curve, readRandom(rand, 32))
+
+		// Pour p256, un scalaire nul ou supérieur à l'ordre de la courbe
+		// est rejeté : on recommence le tirage
+		if err == errInvalidECCKey {
+			continue
+		}
+		return priv, err
+	}
+}
+
+// Nombre premier 2^255 - 19 définissant le corps de Curve25519
+var curve25519P = new(big.Int).Sub(new(big.Int).Lsh(big1, 255), big.NewInt(19))
+
+// Convertit une clé publique Ed25519 (coordonnée y de la courbe d'Edwards)
+// en clé publique X25519 (coordonnée u de la courbe de Montgomery) :
+// u = (1 + y) / (1 - y)  (mod p)
+func edwardsToMontgomery(point []byte) ([]byte, error) {
+	if len(point) != ed25519.PublicKeySize {
+		return nil, errInvalidECCKey
+	}
+
+	// Passage en big-endian et suppression du bit de signe de x
+	b := make([]byte, len(point))
+	for i := range point {
+		b[i] = point[len(point)-1-i]
+	}
+	b[0] &= 0x7f
+	y := new(big.Int).SetBytes(b)
+
+	num := new(big.Int).Add(big1, y)
+	den := new(big.Int).Sub(big1, y)
+	den.Mod(den, curve25519P)
+	if den.ModInverse(den, curve25519P) == nil {
+		return nil, errInvalidECCKey
+	}
+	u := num.Mul(num, den)
+	u.Mod(u, curve25519P)
+
+	// Encodage little-endian sur 32 octets
+	out := fixedBytes(u, 32)
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// Renvoie la clé publique ECDH associée à la clé
+func (pub *ECCPublicKey) ecdhKey() (*ecdh.PublicKey, error) {
+	switch pub.Curve {
+	case curveP256:
+		k, err := ecdh.P256().NewPublicKey(pub.Point)
+		if err != nil {
+			return nil, errInvalidECCKey
+		}
+		return k, nil
+	case curveCurve25519:
+		u, err := edwardsToMontgomery(pub.Point)
+		if err != nil {
+			return nil, err
+		}
+		return ecdh.X25519().NewPublicKey(u)
+	default:
+		return nil, errUnknownCurve
+	}
+}
+
+// Renvoie la clé privée ECDH associée à la clé
+func (priv *ECCPrivateKey) ecdhKey() (*ecdh.PrivateKey, error) {
+	switch priv.Curve {
+	case curveP256:
+		return ecdh.P256().NewPrivateKey(priv.D)
+	case curveCurve25519:
+		// Même dérivation que pour Ed25519 : les 32 premiers octets de
+		// SHA-512(graine), la réduction est faite par X25519
+		h := sha512.Sum512(priv.D)
+		return ecdh.X25519().NewPrivateKey(h[:32])
+	default:
+		return nil, errUnknownCurve
+	}
+}
+
+// Dérive la clé AES-256 et le nonce GCM depuis le secret ECDH, le point
+// éphémère et le point du destinataire avec HKDF-SHA256
+func deriveECIESKey(shared, ephemeral, recipient []byte) (key, nonce []byte) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+
+	okm, err := hkdf.Key(sha256.New, shared, salt, eccECIESInfo, 32+12)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
+	}
+
+	return okm[:32], okm[32:]
+}
+
+// ECCEncrypt chiffre un message pour pub avec ECIES : une clé éphémère
+// est tirée, le secret ECDH est dérivé avec HKDF et le message est chiffré
+// avec AES-GCM :
+// ciphertext = version | point éphémère | AES-GCM(message)
+func ECCEncrypt(rand io.Reader, pub *ECCPublicKey, plaintext []byte) ([]byte, error) {
+	recipient, err := pub.ecdhKey()
+	if err != nil {
+		return nil, err
+	}
+
+	eph, err := GenerateECCKeys(rand, pub.Curve)
+	if err != nil {
+		return nil, err
+	}
+	ephKey, err := eph.ecdhKey()
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := ephKey.ECDH(recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	ephPoint := ephKey.PublicKey().Bytes()
+	key, nonce := deriveECIESKey(shared, ephPoint, recipient.Bytes())
+
+	version := []byte(eccECIESVersion)
+	sealed := newGCM(key).Seal(nil, nonce, plaintext, version)
+
+	return serialize(version, ephPoint, sealed), nil
+}
+
+// ECCDecrypt déchiffre les messages chiffrés avec ECCEncrypt
+func ECCDecrypt(priv *ECCPrivateKey, ciphertext []byte) ([]byte, error) {
+	d := deserialize(ciphertext)
+	if len(d) != 3 || string(d[0]) != eccECIESVersion {
+		return nil, errInvalidCiphertext
+	}
+
+	k, err := priv.ecdhKey()
+	if err != nil {
+		return nil, err
+	}
+
+	ephKey, err := k.Curve().NewPublicKey(d[1])
+	if err != nil {
+		return nil, errInvalidCiphertext
+	}
+
+	shared, err := k.ECDH(ephKey)
+	if err != nil {
+		return nil, errInvalidCiphertext
+	}
+
+	key, nonce := deriveECIESKey(shared, d[1], k.PublicKey().Bytes())
+
+	plaintext, err := newGCM(key).Open(nil, nonce, d[2], d[0])
+	if err != nil {
+		return nil, errDecryption
+	}
+	return plaintext, nil
+}
+
+// Signe data : ECDSA avec SHA-256 pour p256, Ed25519 pour curve25519
+func eccSign(rand io.Reader, priv *ECCPrivateKey, data []byte) ([]byte, error) {
+	switch priv.Curve {
+	case curveP256:
+		k, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), priv.D)
+		if err != nil {
+			return nil, errInvalidECCKey
+		}
+		digest := sha256.Sum256(data)
+		return ecdsa.SignASN1(rand, k, digest[:])
+	case curveCurve25519:
+		return ed25519.Sign(ed25519.NewKeyFromSeed(priv.D), data), nil
+	default:
+		return nil, errUnknownCurve
+	}
+}
+
+// Vérifie la signature de data produite par eccSign
+func eccCheck(pub *ECCPublicKey, data, signature []byte) bool {
+	switch pub.Curve {
+	case curveP256:
+		k, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), pub.Point)
+		if err != nil {
+			return false
+		}
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(k, digest[:], signature)
+	case curveCurve25519:
+		if len(pub.Point) != ed25519.PublicKeySize {
+			return false
+		}
+		return ed25519.Verify(ed25519.PublicKey(pub.Point), data, signature)
+	default:
+		return false
+	}
+}
+
+// ECCSign signe le document "data" et concataine la signature
+// au document.
+func ECCSign(rand io.Reader, priv *ECCPrivateKey, data []byte) (signedData []byte, err error) {
+	signature, err := eccSign(rand, priv, data)
+	if err != nil {
+		return nil, err
+	}
+	return serialize(data, signature), nil
+}
+
+// ECCCheck vérifie que la signature du document est bien valide.
+func ECCCheck(pub *ECCPublicKey, signedData []byte) bool {
+	d := deserialize(signedData)
+	if len(d) != 2 {
+		return false
+	}
+	return eccCheck(pub, d[0], d[1])
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha512"
+	"testing"
+)
+
+var eccCurves = []string{curveP256, curveCurve25519}
+
+func TestECCEncryption(t *testing.T) {
+	for _, curve := range eccCurves {
+		priv, err := GenerateECCKeys(rand.Reader, curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m1 := range [][]byte{randomBytes(1000), {}} {
+			c, err := ECCEncrypt(rand.Reader, &priv.ECCPublicKey, m1)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			m2, err := ECCDecrypt(priv, c)
+			if err != nil || !bytes.Equal(m1, m2) {
+				t.Error("Le chiffrement/déchiffrement ECC a échoué sur", curve)
+			}
+
+			c[len(c)-1] ^= 1
+			if _, err := ECCDecrypt(priv, c); err == nil {
+				t.Error("Un message chiffré modifié a été déchiffré sur", curve)
+			}
+		}
+	}
+}
+
+func TestECCKeyStorage(t *testing.T) {
+	for _, curve := range eccCurves {
+		priv, _ := GenerateECCKeys(rand.Reader, curve)
+
+		pub, err := LoadECCPublicKey(priv.ECCPublicKey.GetBytes())
+		if err != nil || pub.Curve != curve || !bytes.Equal(pub.Point, priv.Point) {
+			t.Error("Les deux clés publiques ne sont pas égales sur", curve)
+		}
+
+		tpriv, err := LoadECCPrivateKey(priv.GetBytes())
+		if err != nil || !bytes.Equal(tpriv.D, priv.D) {
+			t.Error("Les deux clés privées ne sont pas égales sur", curve)
+		}
+
+		if len(priv.D) != 32 {
+			t.Error("La clé privée ne fait pas 32 octets sur", curve)
+		}
+	}
+}
+
+func TestECCSignature(t *testing.T) {
+	data := randomBytes(1000)
+
+	for _, curve := range eccCurves {
+		priv, _ := GenerateECCKeys(rand.Reader, curve)
+
+		signedData, err := ECCSign(rand.Reader, priv, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ECCCheck(&priv.ECCPublicKey, signedData) {
+			t.Error("Echec de la vérification de la signature sur", curve)
+		}
+
+		other, _ := GenerateECCKeys(rand.Reader, curve)
+		if ECCCheck(&other.ECCPublicKey, signedData) {
+			t.Error("La signature est valide pour une autre clé sur", curve)
+		}
+	}
+}
+
+func TestEdwardsToMontgomery(t *testing.T) {
+	priv, _ := GenerateECCKeys(rand.Reader, curveCurve25519)
+
+	h := sha512.Sum512(priv.D)
+	x, _ := ecdh.X25519().NewPrivateKey(h[:32])
+
+	u, err := edwardsToMontgomery(priv.Point)
+	if err != nil || !bytes.Equal(u, x.PublicKey().Bytes()) {
+		t.Error("La conversion Ed25519 vers X25519 est incorrecte")
+	}
+}
@@ -1,9 +1,21 @@
 package main
 
-import "math/big"
-
-// Taille mininale de p pour éviter de couper
-// les messages en trop petite taille
+import (
+	"bytes"
+	"crypto"
+	stdaes "crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/sha256"
+	_ "crypto/sha3"
+	_ "crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Taille minimale de p en bits
 const elgamalMinSize = 128
 
 // ElgamalPublicKey représente une clé publique
@@ -11,6 +23,12 @@ type ElgamalPublicKey struct {
 	Q *big.Int // Q est l'ordre du corps Zp
 	G *big.Int // G est le générateur du corps Zp
 	H *big.Int // H = G^X (où x est la clé privée)
+
+	Group string // Group est le nom du groupe standard utilisé ("" sinon)
+
+	// SubgroupOrder est l'ordre premier du sous-groupe engendré par G pour
+	// les clés DSA, nil sinon
+	SubgroupOrder *big.Int
 }
 
 // ElgamalPrivateKey représente une paire de clés
@@ -20,100 +38,119 @@ type ElgamalPrivateKey struct {
 	X *big.Int // X est généré aléatoirement lors de la création des clés
 }
 
-// GetBytes renvoie sous forme d'octets la clé privée
+// Renvoie les champs optionnels de la clé : [ groupe [ | ordre du sous-groupe ] ]
+func (pub *ElgamalPublicKey) optionalFields() [][]byte {
+	switch {
+	case pub.SubgroupOrder != nil:
+		return [][]byte{[]byte(pub.Group), pub.SubgroupOrder.Bytes()}
+	case pub.Group != "":
+		return [][]byte{[]byte(pub.Group)}
+	default:
+		return nil
+	}
+}
+
+// Renseigne les champs optionnels de la clé lus depuis un fichier
+func (pub *ElgamalPublicKey) loadOptionalFields(v [][]byte) {
+	var group string
+	if len(v) > 0 {
+		group = string(v[0])
+	}
+	pub.Group = recogniseGroup(pub, group)
+
+	if len(v) > 1 {
+		pub.SubgroupOrder = new(big.Int).SetBytes(v[1])
+	}
+}
+
+// GetBytes renvoie sous forme d'octets la clé publique :
+// Q | G | H [ | groupe [ | ordre du sous-groupe ] ]
 func (pub *ElgamalPublicKey) GetBytes() []byte {
-	return serialize(pub.Q.Bytes(), pub.G.Bytes(), pub.H.Bytes())
+	fields := [][]byte{pub.Q.Bytes(), pub.G.Bytes(), pub.H.Bytes()}
+	return serialize(append(fields, pub.optionalFields()...)...)
 }
 
-// GetBytes renvoie sous forme d'octets la clé privée
+// GetBytes renvoie sous forme d'octets la clé privée :
+// Q | G | H | X [ | groupe [ | ordre du sous-groupe ] ]
 func (priv *ElgamalPrivateKey) GetBytes() []byte {
-	return append(priv.ElgamalPublicKey.GetBytes(), serialize(priv.X.Bytes())...)
+	fields := [][]byte{priv.Q.Bytes(), priv.G.Bytes(), priv.H.Bytes(), priv.X.Bytes()}
+	return serialize(append(fields, priv.optionalFields()...)...)
 }
 
-// LoadPrivateKey permet de charger la clé publique mise sous la
-// forme d'un tableau de byte
+// LoadPrivateKey permet de charger la clé privée mise sous la
+// forme d'un tableau de byte (PEM, DER ou raw, voir ParseElgamalPrivateKey).
+// Elle panique si la clé est invalide.
 func LoadPrivateKey(b []byte) *ElgamalPrivateKey {
-	v := deserialize(b)
-	return &ElgamalPrivateKey{
-		ElgamalPublicKey: ElgamalPublicKey{
-			Q: new(big.Int).SetBytes(v[0]),
-			G: new(big.Int).SetBytes(v[1]),
-			H: new(big.Int).SetBytes(v[2]),
-		},
-		X: new(big.Int).SetBytes(v[3]),
+	priv, err := ParseElgamalPrivateKey(b)
+	if err != nil {
+		panic(err)
 	}
+	return priv
 }
 
 // LoadPublicKey permet de charger la clé publique mise sous la
-// forme d'un tableau de byte
+// forme d'un tableau de byte (PEM, DER ou raw, voir ParseElgamalPublicKey).
+// Les paramètres des groupes standards sont reconnus et leur nom est
+// renseigné dans le champ Group. Elle panique si la clé est invalide.
 func LoadPublicKey(b []byte) *ElgamalPublicKey {
-	v := deserialize(b)
-	return &ElgamalPublicKey{
-		Q: new(big.Int).SetBytes(v[0]),
-		G: new(big.Int).SetBytes(v[1]),
-		H: new(big.Int).SetBytes(v[2]),
+	pub, err := ParseElgamalPublicKey(b)
+	if err != nil {
+		panic(err)
 	}
+	return pub
 }
 
 // Génère un groupe cyclique Zp, trouve un générateur g et renvoie (p, g)
-// p est un nombre entier de size bits (size est multiple de 8)
-// Le nombre p est également supérieur à la taille maximale d'un message + 1
-func generateCyclicGroup(size int) (p, g *big.Int) {
-	var (
-		n       = new(big.Int)
-		T       = new(big.Int)
-		pMinus1 = new(big.Int)
-		pmin    = new(big.Int)
-	)
+// p est un nombre premier sûr (p = 2n + 1 avec n premier) de size bits,
+// cherché sur jobs goroutines (runtime.NumCPU() si jobs <= 0)
+func generateCyclicGroup(rand io.Reader, size, jobs int) (p, g *big.Int) {
+	T := new(big.Int)
 
-	p = new(big.Int)
-	pmin.SetBit(pmin, elgamalMinSize+1, 1)
+	p = generateSafePrime(rand, size, jobs)
+	pMinus1 := new(big.Int).Sub(p, big1)
+	n := new(big.Int).Rsh(p, 1)
 
+	// On cherche un générateur g dans Zp : l'ordre de Zp* étant 2n,
+	// g est générateur si g^2 != 1 et g^n != 1
 	for {
-		n = generateRandomPrime(size / 8)
+		g = randRange(rand, big2, new(big.Int).Sub(pMinus1, big1))
 
-		// p = 2n + 1
-		pMinus1.Mul(n, big2)
-		p.Add(pMinus1, big1)
-
-		// p < pmin
-		if p.Cmp(pmin) == -1 {
+		// Si g^2 == 1 alors g n'est pas générateur
+		if T.Exp(g, big2, p).Cmp(big1) == 0 {
 			continue
 		}
 
-		// p n'est pas premier
-		if !probablyPrime(p, 25) {
+		// Si g^n == 1 alors g engendre seulement le sous-groupe d'ordre n
+		if T.Exp(g, n, p).Cmp(big1) == 0 {
 			continue
 		}
 
-		// On cherche un générateur g dans Zp
-		for {
-			g = randRange(big2, pMinus1)
+		return p, g
+	}
+}
 
-			// Si g^(p-1) != 1 alors g n'est pas générateur
-			if T.Exp(g, pMinus1, p).Cmp(big1) != 0 {
-				continue
-			}
+// GenerateElgamalKeys génère une paire de clés de size bits et la renvoie.
+// Tout l'aléa nécessaire est lu depuis rand (en général crypto/rand.Reader).
+// La recherche du nombre premier est répartie sur jobs goroutines
+// (runtime.NumCPU() si jobs <= 0).
+func GenerateElgamalKeys(rand io.Reader, size, jobs int) *ElgamalPrivateKey {
+	if size < elgamalMinSize {
+		panic(fmt.Sprintf("gocrypto: la taille de la clé doit être d'au moins %d bits", elgamalMinSize))
+	}
 
-			// Si g^2 == 1 alors g n'est pas générateur
-			if T.Exp(g, big2, p).Cmp(big1) == 0 {
-				continue
-			}
+	p, g := generateCyclicGroup(rand, size, jobs)
 
-			return p, g
-		}
-	}
+	return newElgamalKey(rand, p, g)
 }
 
-// GenerateElgamalKeys génère une paire de clés et la renvoie
-func GenerateElgamalKeys(size int) *ElgamalPrivateKey {
-	p, g := generateCyclicGroup(size)
-
+// Tire une clé privée x et renvoie la paire de clés associée
+// au groupe (p, g)
+func newElgamalKey(rand io.Reader, p, g *big.Int) *ElgamalPrivateKey {
 	// Calcul de l'ordre de Zp
 	q := new(big.Int).Sub(p, big1)
 
 	// Calcul de x
-	x := randRange(big1, new(big.Int).Sub(q, big1))
+	x := randRange(rand, big1, new(big.Int).Sub(q, big1))
 
 	// Calcul de h
 	h := new(big.Int).Exp(g, x, p)
@@ -124,60 +161,97 @@ func GenerateElgamalKeys(size int) *ElgamalPrivateKey {
 	}
 }
 
-func elgamalEncryptBytes(pubkey *ElgamalPublicKey, plaintext []byte) (c1bytes, c2bytes []byte) {
-	var (
-		c2     = new(big.Int)
-		m      = new(big.Int)
-		c2b    []byte
-		offset int
-	)
+// Identifiants du format de chiffrement hybride ElGamal (KEM) + AES-GCM
+// (DEM). La version 2 enregistre l'identifiant de la clé du destinataire.
+const (
+	elgamalKEMVersion   = "elgamal-kem-v2"
+	elgamalKEMVersionV1 = "elgamal-kem-v1"
+)
 
-	// Calcul du nombre de d'élément de Zp
-	p := new(big.Int).Add(pubkey.Q, big1)
+// Informations de contexte utilisées lors de la dérivation de la clé AES
+const elgamalKEMInfo = "gocrypto elgamal kem aes-256-gcm"
 
-	// Calcul de la taille de p en octets
+// Nom du schéma de signature ElGamal
+const schemeElgamal = "elgamal"
+
+var (
+	errUnknownScheme     = errors.New("gocrypto: schéma de signature inconnu")
+	errInvalidCiphertext = errors.New("gocrypto: message chiffré invalide")
+	errDecryption        = errors.New("gocrypto: échec du déchiffrement (clé incorrecte ou message altéré)")
+)
+
+// Encode n sur exactement size octets (big-endian)
+func fixedBytes(n *big.Int, size int) []byte {
+	return n.FillBytes(make([]byte, size))
+}
+
+// Dérive la clé AES-256 et le nonce GCM depuis le secret partagé s
+// et la première partie du chiffré c1 avec HKDF-SHA256.
+// Une clé différente est tirée pour chaque message, le nonce peut donc
+// être dérivé en même temps que la clé.
+func deriveKEMKey(p, c1, s *big.Int) (key, nonce []byte) {
 	pLen := (p.BitLen() + 7) / 8
 
+	okm, err := hkdf.Key(sha256.New, fixedBytes(s, pLen), fixedBytes(c1, pLen), elgamalKEMInfo, 32+12)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
+	}
+
+	return okm[:32], okm[32:]
+}
+
+// Encapsule une clé symétrique pour pubkey : renvoie c1 = g^y et la clé
+// dérivée du secret partagé s = h^y
+func elgamalEncapsulate(rand io.Reader, pubkey *ElgamalPublicKey) (c1 *big.Int, key, nonce []byte) {
+	// Calcul du nombre de d'élément de Zp
+	p := new(big.Int).Add(pubkey.Q, big1)
+
 	// On choisit aléatoirement un nombre entre 1 et (q-1)
-	y := randRange(big1, new(big.Int).Sub(pubkey.Q, big1))
+	y := randRange(rand, big1, new(big.Int).Sub(pubkey.Q, big1))
 
 	// Calcul de la première partie du message chiffré
-	c1 := new(big.Int).Exp(pubkey.G, y, p)
+	c1 = new(big.Int).Exp(pubkey.G, y, p)
 
 	// Calcul du secret partagé
 	s := new(big.Int).Exp(pubkey.H, y, p)
 
-	// Calcul de la taille d'un bloc en clair et d'un bloc chiffré
-	plainBlockSize, cipherBlockSize := pLen-1, pLen
-
-	// On ajoute un padding au message en clair pour que sa taille soit un multiple
-	// de la taille de la clé
-	plaintext = addPadding(plaintext, plainBlockSize*8)
+	key, nonce = deriveKEMKey(p, c1, s)
+	return c1, key, nonce
+}
 
-	// Calcul du nombre de bloc à chiffrer
-	nblock := len(plaintext) / plainBlockSize
+// Retrouve la clé symétrique encapsulée dans c1 avec la clé privée
+func elgamalDecapsulate(priv *ElgamalPrivateKey, c1 *big.Int) (key, nonce []byte, err error) {
+	// Calcul du nombre de d'élément de Zp
+	p := new(big.Int).Add(priv.Q, big1)
 
-	c2bytes = make([]byte, cipherBlockSize*nblock)
+	// On doit avoir 1 < c1 < p-1
+	if c1.Cmp(big1) <= 0 || c1.Cmp(priv.Q) >= 0 {
+		return nil, nil, errInvalidCiphertext
+	}
 
-	// Chiffrement du message bloc par bloc
-	for i := 0; i < nblock; i++ {
-		// Traduit le bloc en un nombre dans Zp
-		m.SetBytes(plaintext[i*plainBlockSize : (i+1)*plainBlockSize])
+	// Calcul du secret partagé
+	s := new(big.Int).Exp(c1, priv.X, p)
 
-		// Calcul de c2 = m * s
-		c2.Mul(m, s)
-		c2.Mod(c2, p)
+	key, nonce = deriveKEMKey(p, c1, s)
+	return key, nonce, nil
+}
 
-		// Copie de c2 dans le chiffré final
-		c2b = c2.Bytes()
-		offset = cipherBlockSize - len(c2b)
-		copy(c2bytes[(i*cipherBlockSize)+offset:(i+1)*cipherBlockSize], c2b)
+// Renvoie le chiffrement AES-GCM initialisé avec la clé key
+func newGCM(key []byte) cipher.AEAD {
+	block, err := stdaes.NewCipher(key)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
 	}
-
-	return c1.Bytes(), c2bytes
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
+	}
+	return gcm
 }
 
-func elgamalDecryptBytes(priv *ElgamalPrivateKey, c1bytes, c2bytes []byte) (plaintext []byte) {
+// Déchiffre les messages produits par l'ancienne version de ElgamalEncrypt
+// qui chiffrait le message bloc par bloc avec le même secret partagé
+func elgamalDecryptLegacy(priv *ElgamalPrivateKey, c1bytes, c2bytes []byte) (plaintext []byte, err error) {
 	var (
 		c2     = new(big.Int)
 		m      = new(big.Int)
@@ -196,6 +270,12 @@ func elgamalDecryptBytes(priv *ElgamalPrivateKey, c1bytes, c2bytes []byte) (plai
 	// Calcul de la taille d'un bloc en clair et d'un bloc chiffré
 	plainBlockSize, cipherBlockSize := pLen-1, pLen
 
+	// On doit avoir 1 < c1 < p-1 et au moins un bloc chiffré complet
+	if c1.Cmp(big1) <= 0 || c1.Cmp(priv.Q) >= 0 ||
+		len(c2bytes) == 0 || len(c2bytes)%cipherBlockSize != 0 {
+		return nil, errInvalidCiphertext
+	}
+
 	// Calcul du nombre de bloc
 	nblock := len(c2bytes) / cipherBlockSize
 
@@ -211,6 +291,9 @@ func elgamalDecryptBytes(priv *ElgamalPrivateKey, c1bytes, c2bytes []byte) (plai
 	for i := 0; i < nblock; i++ {
 		// Lecture d'un block et conversion en entier dans Zp
 		c2.SetBytes(c2bytes[i*cipherBlockSize : (i+1)*cipherBlockSize])
+		if c2.Cmp(p) >= 0 {
+			return nil, errInvalidCiphertext
+		}
 
 		// Calcul de c2 * sInverse dans Zp
 		m.Mul(c2, sInverse)
@@ -219,39 +302,117 @@ func elgamalDecryptBytes(priv *ElgamalPrivateKey, c1bytes, c2bytes []byte) (plai
 		// Copie du résultat dans le tableau de sortie
 		mb = m.Bytes()
 		offset = plainBlockSize - len(mb)
+		if offset < 0 {
+			return nil, errInvalidCiphertext
+		}
 		copy(plaintext[i*plainBlockSize+offset:(i+1)*plainBlockSize], mb)
 	}
 
 	// Supprime le padding
-	plaintext = removePadding(plaintext)
+	return removePadding(plaintext)
+}
+
+// Retrouve la clé symétrique encapsulée dans c1
+type kemDecapsulator func(c1 *big.Int) (key, nonce []byte, err error)
 
-	return plaintext
+// Déchiffre sealed avec la clé encapsulée dans c1, ad étant les données
+// authentifiées par AES-GCM
+func elgamalOpen(decapsulate kemDecapsulator, c1, sealed, ad []byte) ([]byte, error) {
+	key, nonce, err := decapsulate(new(big.Int).SetBytes(c1))
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := newGCM(key).Open(nil, nonce, sealed, ad)
+	if err != nil {
+		return nil, errDecryption
+	}
+	return plaintext, nil
 }
 
 // ElgamalDecrypt déchiffre les messages chiffrés avec la
-// fonction ElgamalEncrypt
-func ElgamalDecrypt(priv *ElgamalPrivateKey, ciphertext []byte) (plaintext []byte) {
-	// Récupère c1 et c2 sous la forme de tableau d'octets
+// fonction ElgamalEncrypt. Les messages chiffrés avec l'ancien
+// format bloc par bloc et ceux chiffrés pour plusieurs destinataires avec
+// ElgamalEncryptMulti sont également acceptés. Une KeyMismatchError (ou
+// une NotRecipientError) est renvoyée si le message a été chiffré pour une
+// autre clé.
+func ElgamalDecrypt(priv *ElgamalPrivateKey, ciphertext []byte) (plaintext []byte, err error) {
 	d := deserialize(ciphertext)
-	c1bytes, c2bytes := d[0], d[1]
 
-	// Déchiffre le message chiffré
-	plaintext = elgamalDecryptBytes(priv, c1bytes, c2bytes)
+	if len(d) == 2 {
+		// Ancien format : c1 | c2
+		return elgamalDecryptLegacy(priv, d[0], d[1])
+	}
 
-	return plaintext
+	decapsulate := func(c1 *big.Int) ([]byte, []byte, error) {
+		return elgamalDecapsulate(priv, c1)
+	}
+	return elgamalDecryptKEM(&priv.ElgamalPublicKey, decapsulate, d)
+}
+
+// Déchiffre un message au format KEM/DEM (d étant ses champs) destiné à
+// pub, la clé symétrique étant retrouvée avec decapsulate
+func elgamalDecryptKEM(pub *ElgamalPublicKey, decapsulate kemDecapsulator, d [][]byte) ([]byte, error) {
+	switch {
+	case len(d) == 4 && string(d[0]) == elgamalKEMVersion:
+		if err := pub.checkKeyID(d[1]); err != nil {
+			return nil, err
+		}
+		return elgamalOpen(decapsulate, d[2], d[3], serialize(d[0], d[1]))
+	case len(d) == 3 && string(d[0]) == elgamalMultiVersion:
+		return elgamalDecryptMulti(pub, decapsulate, d[0], d[1], d[2])
+	case len(d) == 3 && string(d[0]) == elgamalKEMVersionV1:
+		// Format sans identifiant de clé : version | c1 | AES-GCM(message)
+		return elgamalOpen(decapsulate, d[1], d[2], d[0])
+	default:
+		return nil, errInvalidCiphertext
+	}
+}
+
+// Renvoie les valeurs c1 encapsulant la clé du message destinée à pub
+func elgamalRecipientC1(pub *ElgamalPublicKey, ciphertext []byte) ([]*big.Int, error) {
+	d := deserialize(ciphertext)
+
+	switch {
+	case len(d) == 4 && string(d[0]) == elgamalKEMVersion:
+		if err := pub.checkKeyID(d[1]); err != nil {
+			return nil, err
+		}
+		return []*big.Int{new(big.Int).SetBytes(d[2])}, nil
+	case len(d) == 3 && string(d[0]) == elgamalMultiVersion:
+		slots, err := multiRecipientSlots(pub, d[1])
+		if err != nil {
+			return nil, err
+		}
+		c1 := make([]*big.Int, len(slots))
+		for i, s := range slots {
+			c1[i] = new(big.Int).SetBytes(s[1])
+		}
+		return c1, nil
+	case len(d) == 3 && string(d[0]) == elgamalKEMVersionV1:
+		return []*big.Int{new(big.Int).SetBytes(d[1])}, nil
+	default:
+		return nil, errInvalidCiphertext
+	}
 }
 
-// ElgamalEncrypt chiffre les messages d'une taille quelconque et renvoie le
-// résultat sous la forme de bytes représentant c1 et c2 :
-// ciphertext = c1 | c2 | len(c1)
-func ElgamalEncrypt(pubkey *ElgamalPublicKey, plaintext []byte) (ciphertext []byte) {
-	c1, c2 := elgamalEncryptBytes(pubkey, plaintext)
+// ElgamalEncrypt chiffre les messages d'une taille quelconque. Une clé
+// AES est encapsulée avec ElGamal (c1 = g^y, clé dérivée de h^y par HKDF)
+// puis le message est chiffré avec AES-GCM :
+// ciphertext = version | identifiant de la clé | c1 | AES-GCM(message)
+// La version et l'identifiant de la clé sont authentifiés par AES-GCM.
+// L'aléa nécessaire au chiffrement est lu depuis rand.
+func ElgamalEncrypt(rand io.Reader, pubkey *ElgamalPublicKey, plaintext []byte) (ciphertext []byte) {
+	c1, key, nonce := elgamalEncapsulate(rand, pubkey)
+
+	version, keyID := []byte(elgamalKEMVersion), pubkey.keyIDBytes()
+	sealed := newGCM(key).Seal(nil, nonce, plaintext, serialize(version, keyID))
 
-	// Rassemble c1 et c2 dans un même tableau
-	return serialize(c1, c2)
+	return serialize(version, keyID, c1.Bytes(), sealed)
 }
 
-func sign(priv *ElgamalPrivateKey, data []byte) (signature []byte) {
+// Signe l'empreinte digest (calculée avec h) avec le schéma ElGamal
+func sign(rand io.Reader, priv *ElgamalPrivateKey, digest []byte, h crypto.Hash) (signature []byte) {
 	var y *big.Int
 
 	// Calcul du nombre de d'élément de Zp
@@ -260,10 +421,11 @@ func sign(priv *ElgamalPrivateKey, data []byte) (signature []byte) {
 	// Calcul de p-1
 	pMinus1 := new(big.Int).Sub(p, big1)
 
-	// On choisit aléatoirement un nombre entre 1 et (q-1)
-	// y doit être premier avec (p-1)
+	// y est dérivé de x et de l'empreinte (RFC 6979), entre 1 et (q-1),
+	// et doit être premier avec (p-1)
+	nonces := newNonceGenerator(rand, h, priv.Q, priv.X, digest)
 	for {
-		y = randRange(big1, new(big.Int).Sub(priv.Q, big1))
+		y = nonces.next()
 		if new(big.Int).GCD(nil, nil, y, pMinus1).Cmp(big1) == 0 {
 			break
 		}
@@ -275,8 +437,8 @@ func sign(priv *ElgamalPrivateKey, data []byte) (signature []byte) {
 	// Calcul de s1 = g^y  (mod p)
 	s1 := new(big.Int).Exp(priv.G, y, p)
 
-	// Calcul du hash du document
-	hm := new(big.Int).SetBytes(hash(data))
+	// Calcul du hash du document réduit modulo p-1
+	hm := hashToInt(digest, pMinus1)
 
 	// Calcul de s2 = (hm - x*s1)*y⁻¹  (mod p)
 	s2 := new(big.Int).Set(hm)
@@ -284,26 +446,106 @@ func sign(priv *ElgamalPrivateKey, data []byte) (signature []byte) {
 	s2.Mul(s2, yInv)
 	s2.Mod(s2, pMinus1)
 
-	// Concatène s1 et s2
-	return serialize(s1.Bytes(), s2.Bytes())
+	return encodeSignature(schemeElgamal, h, &priv.ElgamalPublicKey, s1, s2)
 }
 
-func check(pub *ElgamalPublicKey, data, signature []byte) bool {
-	var (
-		s1 = new(big.Int)
-		s2 = new(big.Int)
-	)
+// Encode une signature : schéma | hash | identifiant de la clé | r | s
+func encodeSignature(scheme string, h crypto.Hash, pub *ElgamalPublicKey, r, s *big.Int) []byte {
+	return serialize([]byte(scheme), []byte(hashName(h)), pub.keyIDBytes(), r.Bytes(), s.Bytes())
+}
+
+// Champs d'une signature analysée par parseSignature
+type parsedSignature struct {
+	scheme string
+	h      crypto.Hash
+	r, s   *big.Int
+}
 
-	// Récupère s1 et s2 depuis la signature
+// Analyse une signature. Les signatures sont de la forme schéma | hash |
+// identifiant de la clé | r | s ; les formats précédents, sans
+// identifiant (schéma | hash | r | s) ou propres à ElGamal
+// (hash | s1 | s2), sont toujours acceptés. Renvoie nil si la signature
+// est mal formée, ou une KeyMismatchError si elle a été faite avec une
+// autre clé que pub.
+func parseSignature(pub *ElgamalPublicKey, signature []byte) (*parsedSignature, error) {
 	d := deserialize(signature)
-	s1.SetBytes(d[0])
-	s2.SetBytes(d[1])
 
+	var scheme, hashField, a, b []byte
+	switch {
+	case len(d) == 3:
+		scheme, hashField, a, b = []byte(schemeElgamal), d[0], d[1], d[2]
+	case len(d) == 4:
+		scheme, hashField, a, b = d[0], d[1], d[2], d[3]
+	case len(d) == 5:
+		if err := pub.checkKeyID(d[2]); err != nil {
+			return nil, err
+		}
+		scheme, hashField, a, b = d[0], d[1], d[3], d[4]
+	default:
+		return nil, nil
+	}
+
+	h, ok := signatureHashes[string(hashField)]
+	if !ok {
+		return nil, nil
+	}
+	return &parsedSignature{
+		scheme: string(scheme),
+		h:      h,
+		r:      new(big.Int).SetBytes(a),
+		s:      new(big.Int).SetBytes(b),
+	}, nil
+}
+
+// Vérifie la signature du message lu depuis msg (voir parseSignature). La
+// signature est analysée avant de lire le message, qui n'est lu qu'une
+// fois et jamais conservé en mémoire. Une KeyMismatchError est renvoyée si
+// la signature a été faite avec une autre clé.
+func checkReader(pub *ElgamalPublicKey, msg io.Reader, signature []byte) (bool, error) {
+	sig, err := parseSignature(pub, signature)
+	if sig == nil {
+		return false, err
+	}
+	h, r, s := sig.h, sig.r, sig.s
+
+	switch sig.scheme {
+	case schemeElgamal:
+		digest, err := hashReader(h, msg)
+		if err != nil {
+			return false, err
+		}
+		return elgamalCheck(pub, digest, h, r, s), nil
+	case schemeDSA:
+		digest, err := hashReader(h, msg)
+		if err != nil {
+			return false, err
+		}
+		return dsaCheck(pub, digest, h, r, s), nil
+	case schemeSchnorr, schemeSchnorrV2:
+		return schnorrCheck(pub, msg, h, sig.scheme, r, s)
+	default:
+		return false, nil
+	}
+}
+
+// Vérifie la signature de data
+func check(pub *ElgamalPublicKey, data, signature []byte) bool {
+	ok, err := checkReader(pub, bytes.NewReader(data), signature)
+	return ok && err == nil
+}
+
+// Vérifie la signature ElGamal (s1, s2) de l'empreinte digest
+func elgamalCheck(pub *ElgamalPublicKey, digest []byte, h crypto.Hash, s1, s2 *big.Int) bool {
 	// Calcul du nombre de d'élément de Zp
 	p := new(big.Int).Add(pub.Q, big1)
 
-	// Calcul du hash du document
-	hm := new(big.Int).SetBytes(hash(data))
+	// On doit avoir 0 < s1 < p et 0 <= s2 < p-1
+	if s1.Sign() <= 0 || s1.Cmp(p) >= 0 || s2.Cmp(pub.Q) >= 0 {
+		return false
+	}
+
+	// Calcul du hash du document réduit modulo p-1
+	hm := hashToInt(digest, pub.Q)
 
 	// Calcul de la première moitié de l'égalité
 	// a = g^hm  (mod p)
@@ -318,20 +560,114 @@ func check(pub *ElgamalPublicKey, data, signature []byte) bool {
 	return a.Cmp(b) == 0
 }
 
-func hash(data []byte) []byte {
-	return data[:10]
+// Algorithmes de hachage utilisables pour les signatures, indexés par
+// le nom enregistré dans la signature
+var signatureHashes = map[string]crypto.Hash{
+	"sha256":   crypto.SHA256,
+	"sha512":   crypto.SHA512,
+	"sha3-256": crypto.SHA3_256,
+}
+
+// Renvoie le nom sous lequel l'algorithme h est enregistré
+// dans les signatures
+func hashName(h crypto.Hash) string {
+	for name, v := range signatureHashes {
+		if v == h {
+			return name
+		}
+	}
+	panic("gocrypto: algorithme de hachage non supporté : " + h.String())
+}
+
+// Calcule l'empreinte du document avec l'algorithme h
+func hash(h crypto.Hash, data []byte) []byte {
+	d := h.New()
+	d.Write(data)
+	return d.Sum(nil)
+}
+
+// Calcule l'empreinte des données lues depuis r avec l'algorithme h,
+// sans les conserver en mémoire
+func hashReader(h crypto.Hash, r io.Reader) ([]byte, error) {
+	d := h.New()
+	if _, err := io.Copy(d, r); err != nil {
+		return nil, err
+	}
+	return d.Sum(nil), nil
+}
+
+// Convertit une empreinte en un entier réduit modulo n
+func hashToInt(digest []byte, n *big.Int) *big.Int {
+	hm := new(big.Int).SetBytes(digest)
+	return hm.Mod(hm, n)
 }
 
-// ElgamalSign signe le document "data" et concataine la signature
-// au document.
-func ElgamalSign(priv *ElgamalPrivateKey, data []byte) (signedData []byte) {
-	signature := sign(priv, data)
+// ElgamalSign signe le document "data" avec l'algorithme de hachage h
+// et concataine la signature au document. Le nombre aléatoire de la
+// signature est dérivé de la clé privée et du document (RFC 6979) : si
+// rand est nil, la signature est reproductible ; sinon, de l'aléa lu
+// depuis rand y est ajouté.
+func ElgamalSign(rand io.Reader, priv *ElgamalPrivateKey, data []byte, h crypto.Hash) (signedData []byte) {
+	signature := sign(rand, priv, hash(h, data), h)
 	return serialize(data, signature)
 }
 
+// ElgamalSignReader signe le message lu depuis msg avec le schéma scheme
+// (elgamal, dsa ou schnorr) et renvoie uniquement la signature. Le
+// message est haché au fil de la lecture : la mémoire utilisée ne dépend
+// pas de sa taille. rand est utilisé comme pour ElgamalSign.
+func ElgamalSignReader(rand io.Reader, priv *ElgamalPrivateKey, msg io.Reader, h crypto.Hash, scheme string) (signature []byte, err error) {
+	switch scheme {
+	case schemeElgamal:
+		digest, err := hashReader(h, msg)
+		if err != nil {
+			return nil, err
+		}
+		return sign(rand, priv, digest, h), nil
+	case schemeDSA:
+		// Vérifie la clé avant de lire le message
+		if priv.SubgroupOrder == nil {
+			return nil, errNoDSAGroup
+		}
+		digest, err := hashReader(h, msg)
+		if err != nil {
+			return nil, err
+		}
+		return dsaSign(rand, priv, digest, h)
+	case schemeSchnorr:
+		return schnorrSign(rand, priv, msg, h)
+	default:
+		return nil, errUnknownScheme
+	}
+}
+
+// ElgamalCheckReader vérifie une signature produite par ElgamalSignReader
+// ou ElgamalSignDetached pour le message lu depuis msg. Une erreur est
+// renvoyée en cas d'échec de la lecture, ou une KeyMismatchError si la
+// signature a été faite avec une autre clé.
+func ElgamalCheckReader(pub *ElgamalPublicKey, msg io.Reader, signature []byte) (bool, error) {
+	return checkReader(pub, msg, signature)
+}
+
+// ElgamalSignDetached signe le document "data" avec le schéma scheme
+// (elgamal, dsa ou schnorr) et renvoie uniquement la signature, à
+// conserver à côté du document.
+func ElgamalSignDetached(rand io.Reader, priv *ElgamalPrivateKey, data []byte, h crypto.Hash, scheme string) (signature []byte, err error) {
+	return ElgamalSignReader(rand, priv, bytes.NewReader(data), h, scheme)
+}
+
+// ElgamalCheckDetached vérifie une signature produite par
+// ElgamalSignDetached pour le document "data".
+func ElgamalCheckDetached(pub *ElgamalPublicKey, data, signature []byte) bool {
+	return check(pub, data, signature)
+}
+
 // ElgamalCheck vérifie que la signature du document est bien valide.
 func ElgamalCheck(pub *ElgamalPublicKey, signedData []byte) bool {
 	d := deserialize(signedData)
+	if len(d) != 2 {
+		return false
+	}
 	data, signature := d[0], d[1]
 	return check(pub, data, signature)
 }
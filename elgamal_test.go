@@ -2,10 +2,16 @@ package main
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"io"
+	"math/big"
+	mrand "math/rand"
 	"testing"
 )
 
-var keys = GenerateElgamalKeys(160)
+var keys = GenerateElgamalKeys(rand.Reader, 160, 0)
 
 func TestElgamalEncryption(t *testing.T) {
 	m := make([][]byte, 2)
@@ -13,16 +19,58 @@ func TestElgamalEncryption(t *testing.T) {
 	m[1] = []byte{0, 0, 54, 89, 75, 31, 0, 0, 0}
 
 	for _, m1 := range m {
-		c := ElgamalEncrypt(&keys.ElgamalPublicKey, m1)
+		c := ElgamalEncrypt(rand.Reader, &keys.ElgamalPublicKey, m1)
 
-		m2 := ElgamalDecrypt(keys, c)
+		m2, err := ElgamalDecrypt(keys, c)
 
-		if !bytes.Equal(m1, m2) {
+		if err != nil || !bytes.Equal(m1, m2) {
 			t.Error("Le chiffrement/déchiffrement Elgamal a échoué")
 		}
 	}
 }
 
+func TestElgamalEncryptionTampered(t *testing.T) {
+	c := ElgamalEncrypt(rand.Reader, &keys.ElgamalPublicKey, []byte("message secret"))
+	c[len(c)-1] ^= 1
+
+	if _, err := ElgamalDecrypt(keys, c); err == nil {
+		t.Error("Un message chiffré modifié a été déchiffré sans erreur")
+	}
+}
+
+// Chiffrement bloc par bloc utilisé avant le passage au chiffrement hybride
+func legacyElgamalEncrypt(pubkey *ElgamalPublicKey, plaintext []byte) []byte {
+	p := new(big.Int).Add(pubkey.Q, big1)
+	pLen := (p.BitLen() + 7) / 8
+
+	y := randRange(rand.Reader, big1, new(big.Int).Sub(pubkey.Q, big1))
+	c1 := new(big.Int).Exp(pubkey.G, y, p)
+	s := new(big.Int).Exp(pubkey.H, y, p)
+
+	plainBlockSize, cipherBlockSize := pLen-1, pLen
+	plaintext = addPadding(rand.Reader, plaintext, plainBlockSize*8)
+	nblock := len(plaintext) / plainBlockSize
+	c2bytes := make([]byte, 0, cipherBlockSize*nblock)
+
+	for i := 0; i < nblock; i++ {
+		m := new(big.Int).SetBytes(plaintext[i*plainBlockSize : (i+1)*plainBlockSize])
+		c2 := m.Mul(m, s)
+		c2bytes = append(c2bytes, fixedBytes(c2.Mod(c2, p), cipherBlockSize)...)
+	}
+
+	return serialize(c1.Bytes(), c2bytes)
+}
+
+func TestElgamalLegacyDecryption(t *testing.T) {
+	m1 := randomBytes(100)
+
+	m2, err := ElgamalDecrypt(keys, legacyElgamalEncrypt(&keys.ElgamalPublicKey, m1))
+
+	if err != nil || !bytes.Equal(m1, m2) {
+		t.Error("Le déchiffrement de l'ancien format a échoué")
+	}
+}
+
 func TestElgamalKeyStorage(t *testing.T) {
 	priv := keys
 	pub := priv.ElgamalPublicKey
@@ -43,9 +91,166 @@ func TestElgamalKeyStorage(t *testing.T) {
 func TestSignature(t *testing.T) {
 	data := randomBytes(1000)
 
-	signedData := ElgamalSign(keys, data)
+	for name, h := range signatureHashes {
+		signedData := ElgamalSign(rand.Reader, keys, data, h)
+
+		if !ElgamalCheck(&keys.ElgamalPublicKey, signedData) {
+			t.Error("Echec de la vérification de la signature avec", name)
+		}
+	}
+}
+
+func TestSignatureShortDocument(t *testing.T) {
+	signedData := ElgamalSign(rand.Reader, keys, []byte("abc"), crypto.SHA256)
 
 	if !ElgamalCheck(&keys.ElgamalPublicKey, signedData) {
-		t.Error("Echec de la vérification de la signature.")
+		t.Error("Echec de la vérification de la signature d'un document court.")
+	}
+}
+
+func TestSignatureCommonPrefix(t *testing.T) {
+	data := []byte("0123456789 document original")
+	forged := []byte("0123456789 document modifié")
+
+	signature := sign(rand.Reader, keys, hash(crypto.SHA256, data), crypto.SHA256)
+
+	if check(&keys.ElgamalPublicKey, forged, signature) {
+		t.Error("La signature est valide pour un autre document de même préfixe.")
+	}
+}
+
+// Renvoie une source d'aléa reproductible pour les tests
+func deterministicReader() *mrand.Rand {
+	return mrand.New(mrand.NewSource(42))
+}
+
+func TestDeterministicRandomness(t *testing.T) {
+	k1 := GenerateElgamalKeys(deterministicReader(), 160, 1)
+	k2 := GenerateElgamalKeys(deterministicReader(), 160, 1)
+	if k1.Q.Cmp(k2.Q) != 0 || k1.G.Cmp(k2.G) != 0 || k1.X.Cmp(k2.X) != 0 {
+		t.Fatal("La génération de clés n'est pas reproductible.")
+	}
+
+	data := []byte("message de test")
+
+	c1 := ElgamalEncrypt(deterministicReader(), &k1.ElgamalPublicKey, data)
+	c2 := ElgamalEncrypt(deterministicReader(), &k1.ElgamalPublicKey, data)
+	if !bytes.Equal(c1, c2) {
+		t.Error("Le chiffrement n'est pas reproductible.")
+	}
+
+	s1 := ElgamalSign(deterministicReader(), k1, data, crypto.SHA256)
+	s2 := ElgamalSign(deterministicReader(), k1, data, crypto.SHA256)
+	if !bytes.Equal(s1, s2) {
+		t.Error("La signature n'est pas reproductible.")
+	}
+}
+
+func TestDetachedSignature(t *testing.T) {
+	data := randomBytes(1000)
+	pub := &keys.ElgamalPublicKey
+
+	for _, scheme := range []string{schemeElgamal, schemeSchnorr} {
+		signature, err := ElgamalSignDetached(rand.Reader, keys, data, crypto.SHA256, scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !ElgamalCheckDetached(pub, data, signature) {
+			t.Error("Echec de la vérification de la signature détachée", scheme)
+		}
+
+		if ElgamalCheckDetached(pub, append(data, 0), signature) {
+			t.Error("La signature détachée est valide pour un autre document", scheme)
+		}
+
+		if !ElgamalCheck(pub, serialize(data, signature)) {
+			t.Error("La signature détachée n'est pas utilisable en mode intégré", scheme)
+		}
+	}
+
+	if _, err := ElgamalSignDetached(rand.Reader, keys, data, crypto.SHA256, "rsa"); err == nil {
+		t.Error("Un schéma de signature inconnu a été accepté")
+	}
+}
+
+// Lecteur renvoyant une erreur après avoir produit n octets
+type failingReader struct{ n int }
+
+func (r *failingReader) Read(b []byte) (int, error) {
+	if r.n == 0 {
+		return 0, errors.New("lecture impossible")
+	}
+	if len(b) > r.n {
+		b = b[:r.n]
+	}
+	r.n -= len(b)
+	return len(b), nil
+}
+
+func TestStreamingSignature(t *testing.T) {
+	pub := &keys.ElgamalPublicKey
+
+	// 64 Mio générés à la volée, jamais conservés en mémoire
+	const size = 64 << 20
+	msg := func() io.Reader { return io.LimitReader(mrand.New(mrand.NewSource(1)), size) }
+
+	for _, scheme := range []string{schemeElgamal, schemeSchnorr} {
+		signature, err := ElgamalSignReader(rand.Reader, keys, msg(), crypto.SHA256, scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ok, err := ElgamalCheckReader(pub, msg(), signature)
+		if err != nil || !ok {
+			t.Error("Echec de la vérification de la signature en flux", scheme)
+		}
+
+		ok, _ = ElgamalCheckReader(pub, io.LimitReader(msg(), size-1), signature)
+		if ok {
+			t.Error("La signature en flux est valide pour un document tronqué", scheme)
+		}
+
+		if _, err := ElgamalSignReader(rand.Reader, keys, &failingReader{1000}, crypto.SHA256, scheme); err == nil {
+			t.Error("Une erreur de lecture a été ignorée lors de la signature", scheme)
+		}
+		if _, err := ElgamalCheckReader(pub, &failingReader{1000}, signature); err == nil {
+			t.Error("Une erreur de lecture a été ignorée lors de la vérification", scheme)
+		}
+	}
+}
+
+func TestElgamalLegacyDecryptionMalformed(t *testing.T) {
+	p := new(big.Int).Add(keys.Q, big1)
+	pLen := (p.BitLen() + 7) / 8
+	block := fixedBytes(big.NewInt(12345), pLen)
+
+	for _, c := range []struct {
+		name   string
+		c1, c2 []byte
+	}{
+		{"c1 = 0, c2 vide", []byte{0}, nil},
+		{"c2 vide", []byte{2}, nil},
+		{"c2 non aligné", []byte{2}, block[1:]},
+		{"c1 = 0", []byte{0}, block},
+		{"c1 = 1", []byte{1}, block},
+		{"c1 = p-1", keys.Q.Bytes(), block},
+		{"c1 = p", p.Bytes(), block},
+		{"c2 = p", []byte{2}, fixedBytes(p, pLen)},
+	} {
+		if _, err := ElgamalDecrypt(keys, serialize(c.c1, c.c2)); err != errInvalidCiphertext {
+			t.Errorf("%s : erreur inattendue %v", c.name, err)
+		}
+	}
+
+	// Bloc dont le dernier octet annonce un padding plus long que le bloc
+	y := randRange(rand.Reader, big1, new(big.Int).Sub(keys.Q, big1))
+	c1 := new(big.Int).Exp(keys.G, y, p)
+	s := new(big.Int).Exp(keys.H, y, p)
+	m := new(big.Int).SetBytes(bytes.Repeat([]byte{0xff}, pLen-1))
+	c2 := m.Mul(m, s)
+	c2.Mod(c2, p)
+	if _, err := ElgamalDecrypt(keys, serialize(c1.Bytes(), fixedBytes(c2, pLen))); err != errInvalidCiphertext {
+		t.Errorf("Padding invalide : erreur inattendue %v", err)
 	}
 }
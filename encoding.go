@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+)
+
+// Formats d'enregistrement des clés ElGamal
+const (
+	formatRaw = "raw" // format historique de serialize
+	formatDER = "der" // SubjectPublicKeyInfo / PKCS#8
+	formatPEM = "pem" // DER encapsulé dans un bloc PEM
+)
+
+// Types des blocs PEM
+const (
+	pemPublicKey  = "PUBLIC KEY"
+	pemPrivateKey = "PRIVATE KEY"
+)
+
+// Identifiants des algorithmes : dhKeyAgreement (PKCS#3) pour les groupes
+// sans sous-groupe connu, dhpublicnumber (X9.42) lorsque l'ordre du
+// sous-groupe est connu (clés DSA). Ce sont ceux qu'utilise OpenSSL.
+var (
+	oidDHKeyAgreement = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 3, 1}
+	oidDHPublicNumber = asn1.ObjectIdentifier{1, 2, 840, 10046, 2, 1}
+)
+
+var (
+	errUnknownKeyFormat  = errors.New("gocrypto: format de clé inconnu")
+	errInvalidElgamalKey = errors.New("gocrypto: clé ElGamal invalide")
+	errPrivateKeyFile    = errors.New("gocrypto: le fichier contient une clé privée, une clé publique est attendue")
+)
+
+// Paramètres PKCS#3 : DHParameter ::= SEQUENCE { prime, base,
+// privateValueLength OPTIONAL }
+type pkcs3Params struct {
+	P                  *big.Int
+	G                  *big.Int
+	PrivateValueLength int `asn1:"optional"`
+}
+
+// Paramètres X9.42 : DomainParameters ::= SEQUENCE { p, g, q,
+// j OPTIONAL, validationParms OPTIONAL }
+type x942Params struct {
+	P          *big.Int
+	G          *big.Int
+	Q          *big.Int
+	J          *big.Int      `asn1:"optional"`
+	Validation asn1.RawValue `asn1:"optional"`
+}
+
+// SubjectPublicKeyInfo (RFC 5280)
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// PrivateKeyInfo (PKCS#8, RFC 5208)
+type privateKeyInfo struct {
+	Version    int
+	Algorithm  pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+// Renvoie l'identifiant d'algorithme et les paramètres du groupe de la clé
+func (pub *ElgamalPublicKey) algorithmIdentifier() (pkix.AlgorithmIdentifier, error) {
+	p := new(big.Int).Add(pub.Q, big1)
+
+	var (
+		oid    asn1.ObjectIdentifier
+		params []byte
+		err    error
+	)
+	if pub.SubgroupOrder != nil {
+		oid = oidDHPublicNumber
+		params, err = asn1.Marshal(x942Params{P: p, G: pub.G, Q: pub.SubgroupOrder})
+	} else {
+		oid = oidDHKeyAgreement
+		params, err = asn1.Marshal(pkcs3Params{P: p, G: pub.G})
+	}
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+
+	return pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.RawValue{FullBytes: params}}, nil
+}
+
+// Lit les paramètres du groupe depuis l'identifiant d'algorithme
+func parseAlgorithmIdentifier(algo pkix.AlgorithmIdentifier) (*ElgamalPublicKey, error) {
+	pub := new(ElgamalPublicKey)
+	var p *big.Int
+
+	switch {
+	case algo.Algorithm.Equal(oidDHKeyAgreement):
+		var params pkcs3Params
+		if rest, err := asn1.Unmarshal(algo.Parameters.FullBytes, &params); err != nil || len(rest) > 0 {
+			return nil, errInvalidElgamalKey
+		}
+		p, pub.G = params.P, params.G
+	case algo.Algorithm.Equal(oidDHPublicNumber):
+		var params x942Params
+		if rest, err := asn1.Unmarshal(algo.Parameters.FullBytes, &params); err != nil || len(rest) > 0 {
+			return nil, errInvalidElgamalKey
+		}
+		p, pub.G, pub.SubgroupOrder = params.P, params.G, params.Q
+	default:
+		return nil, errInvalidElgamalKey
+	}
+
+	if p.Cmp(big2) <= 0 || pub.G.Sign() <= 0 {
+		return nil, errInvalidElgamalKey
+	}
+	pub.Q = new(big.Int).Sub(p, big1)
+	return pub, nil
+}
+
+// MarshalDER renvoie la clé publique encodée en DER (SubjectPublicKeyInfo)
+func (pub *ElgamalPublicKey) MarshalDER() ([]byte, error) {
+	algo, err := pub.algorithmIdentifier()
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := asn1.Marshal(pub.H)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(subjectPublicKeyInfo{
+		Algorithm: algo,
+		PublicKey: asn1.BitString{Bytes: y, BitLength: 8 * len(y)},
+	})
+}
+
+// MarshalDER renvoie la clé privée encodée en DER (PKCS#8). La clé
+// publique n'y figure pas : elle est recalculée au chargement.
+func (priv *ElgamalPrivateKey) MarshalDER() ([]byte, error) {
+	algo, err := priv.algorithmIdentifier()
+	if err != nil {
+		return nil, err
+	}
+
+	x, err := asn1.Marshal(priv.X)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(privateKeyInfo{Algorithm: algo, PrivateKey: x})
+}
+
+// Encode renvoie la clé publique au format format (raw, der ou pem)
+func (pub *ElgamalPublicKey) Encode(format string) ([]byte, error) {
+	if format == formatRaw {
+		return pub.GetBytes(), nil
+	}
+
+	der, err := pub.MarshalDER()
+	if err != nil {
+		return nil, err
+	}
+	return encodeDER(der, pemPublicKey, format)
+}
+
+// Encode renvoie la clé privée au format format (raw, der ou pem)
+func (priv *ElgamalPrivateKey) Encode(format string) ([]byte, error) {
+	if format == formatRaw {
+		return priv.GetBytes(), nil
+	}
+
+	der, err := priv.MarshalDER()
+	if err != nil {
+		return nil, err
+	}
+	return encodeDER(der, pemPrivateKey, format)
+}
+
+// Renvoie der tel quel ou encapsulé dans un bloc PEM de type pemType
+func encodeDER(der []byte, pemType, format string) ([]byte, error) {
+	switch format {
+	case formatDER:
+		return der, nil
+	case formatPEM:
+		return pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der}), nil
+	default:
+		return nil, errUnknownKeyFormat
+	}
+}
+
+// Détecte le format d'une clé : renvoie son encodage DER si elle est au
+// format PEM (bloc de type pemType) ou DER, nil si elle est au format raw
+func detectDER(b []byte, pemType string) ([]byte, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN ")) {
+		block, _ := pem.Decode(b)
+		if block == nil || block.Type != pemType {
+			return nil, errInvalidElgamalKey
+		}
+		return block.Bytes, nil
+	}
+
+	// Une clé raw commence par la longueur de Q sur 4 octets, qui peut
+	// ressembler à un en-tête de SEQUENCE : on exige que tout b soit une
+	// SEQUENCE DER valide
+	var seq asn1.RawValue
+	if rest, err := asn1.Unmarshal(b, &seq); err == nil && len(rest) == 0 &&
+		seq.Class == asn1.ClassUniversal && seq.Tag == asn1.TagSequence {
+		return b, nil
+	}
+	return nil, nil
+}
+
+// Lit une clé publique encodée en DER (SubjectPublicKeyInfo)
+func parsePublicKeyDER(der []byte) (*ElgamalPublicKey, error) {
+	var spki subjectPublicKeyInfo
+	if rest, err := asn1.Unmarshal(der, &spki); err != nil || len(rest) > 0 {
+		return nil, errInvalidElgamalKey
+	}
+
+	pub, err := parseAlgorithmIdentifier(spki.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	if rest, err := asn1.Unmarshal(spki.PublicKey.RightAlign(), &pub.H); err != nil || len(rest) > 0 {
+		return nil, errInvalidElgamalKey
+	}
+
+	pub.Group = recogniseGroup(pub, "")
+	return pub, nil
+}
+
+// Lit une clé privée encodée en DER (PKCS#8)
+func parsePrivateKeyDER(der []byte) (*ElgamalPrivateKey, error) {
+	var info privateKeyInfo
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) > 0 || info.Version != 0 {
+		return nil, errInvalidElgamalKey
+	}
+
+	pub, err := parseAlgorithmIdentifier(info.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	priv := &ElgamalPrivateKey{ElgamalPublicKey: *pub}
+	if rest, err := asn1.Unmarshal(info.PrivateKey, &priv.X); err != nil || len(rest) > 0 {
+		return nil, errInvalidElgamalKey
+	}
+	// Un INTEGER négatif ou trop grand donnerait une clé publique
+	// incohérente avec X (g^-x est l'inverse de g^x)
+	if priv.X.Sign() <= 0 || priv.X.Cmp(priv.Q) >= 0 {
+		return nil, errInvalidElgamalKey
+	}
+
+	// y = g^x  (mod p)
+	p := new(big.Int).Add(priv.Q, big1)
+	priv.H = new(big.Int).Exp(priv.G, priv.X, p)
+
+	priv.Group = recogniseGroup(&priv.ElgamalPublicKey, "")
+	return priv, nil
+}
+
+// Indique si le champ f d'une clé raw est un nom de groupe : vide ou celui
+// d'un groupe standard
+func isGroupField(f []byte) bool {
+	if len(f) == 0 {
+		return true
+	}
+	_, ok := standardGroups[string(f)]
+	return ok
+}
+
+// Indique si les champs v d'une clé raw sont ceux d'une clé privée
+// (Q | G | H | X [ | groupe [ | ordre du sous-groupe ] ]) ou d'une clé
+// publique (Q | G | H [ | groupe [ | ordre du sous-groupe ] ]). Le champ
+// qui suit H est un nom de groupe pour une clé publique, jamais pour une
+// clé privée puisque X n'est pas nul. ok est faux si les champs ne
+// correspondent à aucun des deux formats.
+func rawPrivateKey(v [][]byte) (private, ok bool) {
+	switch len(v) {
+	case 3:
+		return false, true
+	case 4:
+		return !isGroupField(v[3]), true
+	case 5:
+		if isGroupField(v[3]) {
+			return false, true
+		}
+		return true, isGroupField(v[4])
+	case 6:
+		return true, !isGroupField(v[3]) && isGroupField(v[4])
+	default:
+		return false, false
+	}
+}
+
+// ParseElgamalPublicKey charge une clé publique au format PEM, DER ou raw,
+// détecté automatiquement. Une erreur explicite est renvoyée pour un
+// fichier de clé privée.
+func ParseElgamalPublicKey(b []byte) (*ElgamalPublicKey, error) {
+	if IsProtectedKey(b) {
+		return nil, errProtectedKey
+	}
+
+	if block, _ := pem.Decode(b); block != nil && block.Type == pemPrivateKey {
+		return nil, errPrivateKeyFile
+	}
+
+	der, err := detectDER(b, pemPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if der != nil {
+		pub, err := parsePublicKeyDER(der)
+		if err != nil {
+			if _, err := parsePrivateKeyDER(der); err == nil {
+				return nil, errPrivateKeyFile
+			}
+		}
+		return pub, err
+	}
+
+	v := deserialize(b)
+	private, ok := rawPrivateKey(v)
+	if !ok {
+		return nil, errInvalidElgamalKey
+	}
+	if private {
+		return nil, errPrivateKeyFile
+	}
+	pub := &ElgamalPublicKey{
+		Q: new(big.Int).SetBytes(v[0]),
+		G: new(big.Int).SetBytes(v[1]),
+		H: new(big.Int).SetBytes(v[2]),
+	}
+	pub.loadOptionalFields(v[3:])
+
+	return pub, nil
+}
+
+// ParseElgamalPrivateKey charge une clé privée au format PEM, DER ou raw,
+// détecté automatiquement. Les clés protégées par une phrase de passe
+// doivent d'abord être déchiffrées avec UnprotectPrivateKey.
+func ParseElgamalPrivateKey(b []byte) (*ElgamalPrivateKey, error) {
+	if IsProtectedKey(b) {
+		return nil, errProtectedKey
+	}
+
+	der, err := detectDER(b, pemPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	if der != nil {
+		return parsePrivateKeyDER(der)
+	}
+
+	v := deserialize(b)
+	if private, ok := rawPrivateKey(v); !ok || !private {
+		return nil, errInvalidElgamalKey
+	}
+	priv := &ElgamalPrivateKey{
+		ElgamalPublicKey: ElgamalPublicKey{
+			Q: new(big.Int).SetBytes(v[0]),
+			G: new(big.Int).SetBytes(v[1]),
+			H: new(big.Int).SetBytes(v[2]),
+		},
+		X: new(big.Int).SetBytes(v[3]),
+	}
+	priv.loadOptionalFields(v[4:])
+
+	return priv, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// KeyMismatchError indique qu'un message chiffré ou une signature a été
+// produit pour une autre clé que celle fournie
+type KeyMismatchError struct {
+	Expected uint64 // identifiant de la clé enregistré dans le message
+	Actual   uint64 // identifiant de la clé fournie
+}
+
+func (e *KeyMismatchError) Error() string {
+	return fmt.Sprintf("gocrypto: le message concerne la clé %016X, la clé fournie est %016X", e.Expected, e.Actual)
+}
+
+// Fingerprint renvoie l'empreinte de la clé : SHA-256 de Q | G | H encodés
+// avec serialize. Elle ne dépend ni du format du fichier de clé ni des
+// champs optionnels (nom du groupe, ordre du sous-groupe).
+func (pub *ElgamalPublicKey) Fingerprint() []byte {
+	h := sha256.Sum256(serialize(pub.Q.Bytes(), pub.G.Bytes(), pub.H.Bytes()))
+	return h[:]
+}
+
+// KeyID renvoie l'identifiant de la clé : les 64 premiers bits de son
+// empreinte
+func (pub *ElgamalPublicKey) KeyID() uint64 {
+	return binary.BigEndian.Uint64(pub.Fingerprint())
+}
+
+// Renvoie l'identifiant de la clé sur 8 octets, tel qu'il est enregistré
+// dans les messages chiffrés et les signatures
+func (pub *ElgamalPublicKey) keyIDBytes() []byte {
+	return binary.BigEndian.AppendUint64(nil, pub.KeyID())
+}
+
+// Vérifie que l'identifiant id enregistré dans un message correspond à la
+// clé pub
+func (pub *ElgamalPublicKey) checkKeyID(id []byte) error {
+	if len(id) != 8 {
+		return errInvalidCiphertext
+	}
+
+	expected := binary.BigEndian.Uint64(id)
+	if actual := pub.KeyID(); expected != actual {
+		return &KeyMismatchError{Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// Affiche une empreinte en hexadécimal par groupes de 4 caractères
+func formatFingerprint(fp []byte) string {
+	hex := fmt.Sprintf("%X", fp)
+
+	groups := make([]string, 0, len(hex)/4)
+	for i := 0; i < len(hex); i += 4 {
+		groups = append(groups, hex[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
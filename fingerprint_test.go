@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFingerprintVector(t *testing.T) {
+	// SHA-256 de Q | G | H pour la clé du vecteur de test DSA, calculé
+	// indépendamment
+	const expected = "04C87CC4336100C2126D9EB4916CA24DDDE91B3883808C48B32CFD7399B9F40B"
+
+	pub := &dsaVectorKey().ElgamalPublicKey
+	if fp := hex.EncodeToString(pub.Fingerprint()); fp != strings.ToLower(expected) {
+		t.Error("Empreinte incorrecte :", fp)
+	}
+	if pub.KeyID() != 0x04C87CC4336100C2 {
+		t.Errorf("Identifiant de clé incorrect : %016X", pub.KeyID())
+	}
+}
+
+func TestFingerprintIndependentOfFormat(t *testing.T) {
+	fp := keys.Fingerprint()
+
+	for _, format := range []string{formatRaw, formatDER, formatPEM} {
+		b, err := keys.ElgamalPublicKey.Encode(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub, err := ParseElgamalPublicKey(b)
+		if err != nil || !bytes.Equal(pub.Fingerprint(), fp) {
+			t.Error("L'empreinte dépend du format de la clé", format)
+		}
+	}
+
+	if bytes.Equal(GenerateElgamalKeys(rand.Reader, 160, 0).Fingerprint(), fp) {
+		t.Error("Deux clés différentes ont la même empreinte")
+	}
+}
+
+func TestEncryptionKeyMismatch(t *testing.T) {
+	other := GenerateElgamalKeys(rand.Reader, 160, 0)
+	c := ElgamalEncrypt(rand.Reader, &keys.ElgamalPublicKey, []byte("message"))
+
+	_, err := ElgamalDecrypt(other, c)
+	var mismatch *KeyMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Expected != keys.KeyID() || mismatch.Actual != other.KeyID() {
+		t.Error("Le déchiffrement avec une autre clé n'a pas été signalé", err)
+	}
+
+	// L'identifiant de la clé est authentifié : le remplacer par celui
+	// d'une autre clé ne permet pas de faire accepter le message
+	d := deserialize(c)
+	d[1] = other.keyIDBytes()
+	if _, err := ElgamalDecrypt(keys, serialize(d...)); err == nil {
+		t.Error("Un identifiant de clé modifié a été accepté")
+	}
+}
+
+func TestEncryptionWithoutKeyID(t *testing.T) {
+	// Format elgamal-kem-v1 : version | c1 | AES-GCM(message)
+	m := []byte("message au format v1")
+	c1, key, nonce := elgamalEncapsulate(rand.Reader, &keys.ElgamalPublicKey)
+	version := []byte(elgamalKEMVersionV1)
+	c := serialize(version, c1.Bytes(), newGCM(key).Seal(nil, nonce, m, version))
+
+	d, err := ElgamalDecrypt(keys, c)
+	if err != nil || !bytes.Equal(d, m) {
+		t.Error("Echec du déchiffrement d'un message sans identifiant de clé", err)
+	}
+}
+
+func TestSignatureKeyMismatch(t *testing.T) {
+	other := GenerateElgamalKeys(rand.Reader, 160, 0)
+	data := []byte("document signé")
+
+	for _, scheme := range []string{schemeElgamal, schemeSchnorr} {
+		signature, err := ElgamalSignDetached(rand.Reader, keys, data, crypto.SHA256, scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ok, err := ElgamalCheckReader(&other.ElgamalPublicKey, bytes.NewReader(data), signature)
+		var mismatch *KeyMismatchError
+		if ok || !errors.As(err, &mismatch) || mismatch.Expected != keys.KeyID() {
+			t.Error("La vérification avec une autre clé n'a pas été signalée", scheme, err)
+		}
+
+		// Signature sans identifiant de clé : schéma | hash | r | s
+		d := deserialize(signature)
+		legacy := serialize(d[0], d[1], d[3], d[4])
+		if !ElgamalCheckDetached(&keys.ElgamalPublicKey, data, legacy) {
+			t.Error("Une signature sans identifiant de clé a été refusée", scheme)
+		}
+
+		// Ancien format propre à ElGamal : hash | s1 | s2
+		if scheme == schemeElgamal && !ElgamalCheckDetached(&keys.ElgamalPublicKey, data, serialize(d[1], d[3], d[4])) {
+			t.Error("Une signature ElGamal à l'ancien format a été refusée")
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Groupe de Diffie-Hellman standard : p est un nombre premier sûr et g
+// engendre le sous-groupe d'ordre (p-1)/2
+type standardGroup struct {
+	P *big.Int
+	G *big.Int
+}
+
+func mustHex(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("gocrypto: constante hexadécimale invalide")
+	}
+	return n
+}
+
+// Groupes standards utilisables pour les clés ElGamal, indexés par
+// l'identifiant enregistré dans les fichiers de clé
+var standardGroups = map[string]standardGroup{
+	// RFC 7919, annexe A.1
+	"ffdhe2048": {
+		P: mustHex("FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+			"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+			"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+			"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+			"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+			"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+			"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+			"C58EF1837D1683B2C6F34A26C1B2EFFA886B423861285C97FFFFFFFFFFFFFFFF"),
+		G: big2,
+	},
+	// RFC 7919, annexe A.2
+	"ffdhe3072": {
+		P: mustHex("FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+			"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+			"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+			"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+			"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+			"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+			"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+			"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+			"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+			"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+			"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+			"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B66C62E37FFFFFFFFFFFFFFFF"),
+		G: big2,
+	},
+	// RFC 7919, annexe A.3
+	"ffdhe4096": {
+		P: mustHex("FFFFFFFFFFFFFFFFADF85458A2BB4A9AAFDC5620273D3CF1D8B9C583CE2D3695" +
+			"A9E13641146433FBCC939DCE249B3EF97D2FE363630C75D8F681B202AEC4617A" +
+			"D3DF1ED5D5FD65612433F51F5F066ED0856365553DED1AF3B557135E7F57C935" +
+			"984F0C70E0E68B77E2A689DAF3EFE8721DF158A136ADE73530ACCA4F483A797A" +
+			"BC0AB182B324FB61D108A94BB2C8E3FBB96ADAB760D7F4681D4F42A3DE394DF4" +
+			"AE56EDE76372BB190B07A7C8EE0A6D709E02FCE1CDF7E2ECC03404CD28342F61" +
+			"9172FE9CE98583FF8E4F1232EEF28183C3FE3B1B4C6FAD733BB5FCBC2EC22005" +
+			"C58EF1837D1683B2C6F34A26C1B2EFFA886B4238611FCFDCDE355B3B6519035B" +
+			"BC34F4DEF99C023861B46FC9D6E6C9077AD91D2691F7F7EE598CB0FAC186D91C" +
+			"AEFE130985139270B4130C93BC437944F4FD4452E2D74DD364F2E21E71F54BFF" +
+			"5CAE82AB9C9DF69EE86D2BC522363A0DABC521979B0DEADA1DBF9A42D5C4484E" +
+			"0ABCD06BFA53DDEF3C1B20EE3FD59D7C25E41D2B669E1EF16E6F52C3164DF4FB" +
+			"7930E9E4E58857B6AC7D5F42D69F6D187763CF1D5503400487F55BA57E31CC7A" +
+			"7135C886EFB4318AED6A1E012D9E6832A907600A918130C46DC778F971AD0038" +
+			"092999A333CB8B7A1A1DB93D7140003C2A4ECEA9F98D0ACC0A8291CDCEC97DCF" +
+			"8EC9B55A7F88A46B4DB5A851F44182E1C68A007E5E655F6AFFFFFFFFFFFFFFFF"),
+		G: big2,
+	},
+	// RFC 3526, groupe 14
+	"modp2048": {
+		P: mustHex("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74" +
+			"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437" +
+			"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED" +
+			"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05" +
+			"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB" +
+			"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B" +
+			"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718" +
+			"3995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF"),
+		G: big2,
+	},
+}
+
+var errUnknownGroup = errors.New("gocrypto: groupe inconnu")
+
+// Renvoie la liste triée des noms de groupes standards
+func standardGroupNames() []string {
+	names := make([]string, 0, len(standardGroups))
+	for name := range standardGroups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Renvoie le nom du groupe standard de paramètres (p, g), ou une chaîne
+// vide si les paramètres ne correspondent à aucun groupe connu
+func lookupGroup(p, g *big.Int) string {
+	for name, group := range standardGroups {
+		if group.P.Cmp(p) == 0 && group.G.Cmp(g) == 0 {
+			return name
+		}
+	}
+	return ""
+}
+
+// Renvoie le nom du groupe des paramètres de la clé. Le nom enregistré
+// dans le fichier n'est conservé que s'il correspond aux paramètres.
+func recogniseGroup(pub *ElgamalPublicKey, stored string) string {
+	p := new(big.Int).Add(pub.Q, big1)
+
+	if group, ok := standardGroups[stored]; ok && group.P.Cmp(p) == 0 && group.G.Cmp(pub.G) == 0 {
+		return stored
+	}
+	return lookupGroup(p, pub.G)
+}
+
+// GenerateElgamalKeysInGroup génère une paire de clés dans le groupe
+// standard name (ffdhe2048, ffdhe3072, ffdhe4096 ou modp2048)
+func GenerateElgamalKeysInGroup(rand io.Reader, name string) (*ElgamalPrivateKey, error) {
+	group, ok := standardGroups[name]
+	if !ok {
+		return nil, errUnknownGroup
+	}
+
+	priv := newElgamalKey(rand, group.P, group.G)
+	priv.Group = name
+	return priv, nil
+}
+
+// Renvoie la liste des groupes pour les messages d'aide
+func standardGroupList() string {
+	return strings.Join(standardGroupNames(), "|")
+}
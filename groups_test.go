@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestStandardGroupsAreSafePrimes(t *testing.T) {
+	for name, group := range standardGroups {
+		n := new(big.Int).Rsh(group.P, 1)
+		if !group.P.ProbablyPrime(2) || !n.ProbablyPrime(2) {
+			t.Error("Le groupe", name, "n'est pas défini par un nombre premier sûr")
+		}
+	}
+}
+
+func TestGroupKeyStorage(t *testing.T) {
+	priv, err := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pub := LoadPublicKey(priv.ElgamalPublicKey.GetBytes())
+	if pub.Group != "ffdhe2048" || pub.H.Cmp(priv.H) != 0 {
+		t.Error("Le groupe de la clé publique n'a pas été reconnu")
+	}
+
+	tpriv := LoadPrivateKey(priv.GetBytes())
+	if tpriv.Group != "ffdhe2048" || tpriv.X.Cmp(priv.X) != 0 {
+		t.Error("Le groupe de la clé privée n'a pas été reconnu")
+	}
+
+	m := []byte("message dans un groupe standard")
+	d, err := ElgamalDecrypt(tpriv, ElgamalEncrypt(rand.Reader, pub, m))
+	if err != nil || !bytes.Equal(m, d) {
+		t.Error("Le chiffrement dans un groupe standard a échoué")
+	}
+}
+
+func TestGroupRecognisedWithoutLabel(t *testing.T) {
+	priv, _ := GenerateElgamalKeysInGroup(rand.Reader, "modp2048")
+	pub := priv.ElgamalPublicKey
+
+	// Ancien format, sans identifiant de groupe
+	b := serialize(pub.Q.Bytes(), pub.G.Bytes(), pub.H.Bytes())
+	if LoadPublicKey(b).Group != "modp2048" {
+		t.Error("Les paramètres du groupe modp2048 n'ont pas été reconnus")
+	}
+}
+
+func TestGroupLabelMismatch(t *testing.T) {
+	pub := keys.ElgamalPublicKey
+	b := serialize(pub.Q.Bytes(), pub.G.Bytes(), pub.H.Bytes(), []byte("ffdhe2048"))
+
+	if LoadPublicKey(b).Group != "" {
+		t.Error("Un nom de groupe ne correspondant pas aux paramètres a été accepté")
+	}
+}
+
+func TestUnknownGroup(t *testing.T) {
+	if _, err := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe1024"); err == nil {
+		t.Error("Un groupe inconnu a été accepté")
+	}
+}
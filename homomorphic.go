@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Identifiant du format des chiffrés d'éléments du groupe
+const elgamalElementVersion = "elgamal-element-v1"
+
+// Borne maximale acceptée par ElgamalDecryptExponent : la recherche du
+// logarithme discret utilise une table de sqrt(borne) éléments
+const exponentMaxBound = 1 << 40
+
+var (
+	errNotInGroup    = errors.New("gocrypto: le message n'appartient pas au sous-groupe d'ordre premier de la clé")
+	errExponentBound = fmt.Errorf("gocrypto: la borne du logarithme discret doit être comprise entre 0 et %d", uint64(exponentMaxBound))
+	errExponentRange = errors.New("gocrypto: message en dehors de l'intervalle [0, borne]")
+)
+
+// ElgamalCiphertext est le chiffré ElGamal d'un élément m du groupe :
+// (c1, c2) = (g^y, m * h^y). Contrairement aux messages de ElgamalEncrypt,
+// ces chiffrés sont homomorphes : le produit de deux chiffrés est un
+// chiffré du produit des messages.
+//
+// Pour que le chiffrement soit sûr (IND-CPA), on se place dans le
+// sous-groupe d'ordre premier utilisé par les signatures de Schnorr (voir
+// schnorrGroup) : pour une clé ElGamal, g et h y sont remplacés par g^2
+// et h^2 et les messages doivent être des carrés modulo p.
+type ElgamalCiphertext struct {
+	C1 *big.Int
+	C2 *big.Int
+}
+
+// Vérifie que les deux éléments du chiffré appartiennent au sous-groupe
+func (c *ElgamalCiphertext) valid(p, q *big.Int) bool {
+	return c.C1 != nil && c.C2 != nil && isGroupElement(c.C1, p, q) && isGroupElement(c.C2, p, q)
+}
+
+// Indique si x appartient au sous-groupe d'ordre q de Zp (1 compris)
+func isGroupElement(x, p, q *big.Int) bool {
+	return x.Sign() > 0 && x.Cmp(p) < 0 && new(big.Int).Exp(x, q, p).Cmp(big1) == 0
+}
+
+// Chiffre l'élément m du sous-groupe avec l'aléa y tiré de rand
+func encryptElement(rand io.Reader, pub *ElgamalPublicKey, m *big.Int) *ElgamalCiphertext {
+	p, q, g, h := schnorrGroup(pub)
+
+	y := randRange(rand, big1, new(big.Int).Sub(q, big1))
+
+	c2 := new(big.Int).Exp(h, y, p)
+	c2.Mul(c2, m)
+	c2.Mod(c2, p)
+
+	return &ElgamalCiphertext{C1: new(big.Int).Exp(g, y, p), C2: c2}
+}
+
+// ElgamalEncryptElement chiffre l'élément m, qui doit appartenir au
+// sous-groupe d'ordre premier de la clé (un carré modulo p pour une clé
+// ElGamal). L'aléa nécessaire au chiffrement est lu depuis rand.
+func ElgamalEncryptElement(rand io.Reader, pub *ElgamalPublicKey, m *big.Int) (*ElgamalCiphertext, error) {
+	p, q, _, _ := schnorrGroup(pub)
+	if !isGroupElement(m, p, q) {
+		return nil, errNotInGroup
+	}
+	return encryptElement(rand, pub, m), nil
+}
+
+// ElgamalDecryptElement déchiffre un chiffré produit par
+// ElgamalEncryptElement, ElgamalMultiply ou ElgamalRerandomize :
+// m = c2 / c1^x, x étant aussi la clé privée dans le sous-groupe
+// ((g^2)^x = h^2)
+func ElgamalDecryptElement(priv *ElgamalPrivateKey, c *ElgamalCiphertext) (*big.Int, error) {
+	p, q, _, _ := schnorrGroup(&priv.ElgamalPublicKey)
+	if !c.valid(p, q) {
+		return nil, errInvalidCiphertext
+	}
+
+	// c1^-x = c1^(q-x) dans le sous-groupe d'ordre q
+	x := new(big.Int).Mod(priv.X, q)
+	m := new(big.Int).Exp(c.C1, x.Sub(q, x), p)
+	m.Mul(m, c.C2)
+	return m.Mod(m, p), nil
+}
+
+// ElgamalMultiply renvoie le produit des chiffrés a et b : un chiffré du
+// produit des messages, ou de leur somme en mode exponentiel
+func ElgamalMultiply(pub *ElgamalPublicKey, a, b *ElgamalCiphertext) *ElgamalCiphertext {
+	p := new(big.Int).Add(pub.Q, big1)
+
+	c1 := new(big.Int).Mul(a.C1, b.C1)
+	c2 := new(big.Int).Mul(a.C2, b.C2)
+	return &ElgamalCiphertext{C1: c1.Mod(c1, p), C2: c2.Mod(c2, p)}
+}
+
+// ElgamalRerandomize renvoie un nouveau chiffré du même message,
+// (c1 * g^r, c2 * h^r), impossible à relier à c sans la clé privée
+func ElgamalRerandomize(rand io.Reader, pub *ElgamalPublicKey, c *ElgamalCiphertext) *ElgamalCiphertext {
+	return ElgamalMultiply(pub, c, encryptElement(rand, pub, big1))
+}
+
+// ElgamalEncryptExponent chiffre l'entier m en mode exponentiel :
+// (c1, c2) = (g^y, g^m * h^y). Le produit de chiffrés (ElgamalMultiply)
+// chiffre alors la somme des messages, ce qui permet d'additionner des
+// votes sans les déchiffrer.
+func ElgamalEncryptExponent(rand io.Reader, pub *ElgamalPublicKey, m *big.Int) (*ElgamalCiphertext, error) {
+	if m.Sign() < 0 {
+		return nil, errExponentRange
+	}
+
+	p, _, g, _ := schnorrGroup(pub)
+	return encryptElement(rand, pub, new(big.Int).Exp(g, m, p)), nil
+}
+
+// ElgamalDecryptExponent déchiffre un chiffré en mode exponentiel dont le
+// message est compris entre 0 et bound : g^m est déchiffré puis m est
+// retrouvé par l'algorithme baby-step giant-step en O(sqrt(bound))
+// opérations.
+func ElgamalDecryptExponent(priv *ElgamalPrivateKey, c *ElgamalCiphertext, bound uint64) (*big.Int, error) {
+	if bound > exponentMaxBound {
+		return nil, errExponentBound
+	}
+
+	gm, err := ElgamalDecryptElement(priv, c)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := discreteLog(&priv.ElgamalPublicKey, gm, bound)
+	if !ok {
+		return nil, errExponentRange
+	}
+	return new(big.Int).SetUint64(m), nil
+}
+
+// Cherche m dans [0, bound] tel que g^m = target (baby-step giant-step)
+func discreteLog(pub *ElgamalPublicKey, target *big.Int, bound uint64) (uint64, bool) {
+	p, _, g, _ := schnorrGroup(pub)
+
+	// Pas de la recherche : s = floor(sqrt(bound)) + 1, d'où s^2 > bound
+	s := new(big.Int).Sqrt(new(big.Int).SetUint64(bound)).Uint64() + 1
+
+	// Petits pas : g^j pour 0 <= j < s
+	table := make(map[string]uint64, s)
+	e := big.NewInt(1)
+	for j := uint64(0); j < s; j++ {
+		if _, ok := table[string(e.Bytes())]; !ok {
+			table[string(e.Bytes())] = j
+		}
+		e = e.Mul(e, g).Mod(e, p)
+	}
+
+	// Grands pas : target * g^(-s*i) pour 0 <= i <= s
+	factor := new(big.Int).ModInverse(e, p)
+	gamma := new(big.Int).Set(target)
+	for i := uint64(0); i <= s; i++ {
+		if j, ok := table[string(gamma.Bytes())]; ok {
+			if m := i*s + j; m <= bound {
+				return m, true
+			}
+			return 0, false
+		}
+		gamma.Mul(gamma, factor).Mod(gamma, p)
+	}
+	return 0, false
+}
+
+// GetBytes renvoie sous forme d'octets le chiffré : version | c1 | c2
+func (c *ElgamalCiphertext) GetBytes() []byte {
+	return serialize([]byte(elgamalElementVersion), c.C1.Bytes(), c.C2.Bytes())
+}
+
+// ParseElgamalCiphertext charge un chiffré enregistré avec GetBytes
+func ParseElgamalCiphertext(b []byte) (*ElgamalCiphertext, error) {
+	d := deserialize(b)
+	if len(d) != 3 || string(d[0]) != elgamalElementVersion {
+		return nil, errInvalidCiphertext
+	}
+	return &ElgamalCiphertext{C1: new(big.Int).SetBytes(d[1]), C2: new(big.Int).SetBytes(d[2])}, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// Renvoie un élément aléatoire du sous-groupe d'ordre premier de la clé
+func randomGroupElement(pub *ElgamalPublicKey) *big.Int {
+	p, q, g, _ := schnorrGroup(pub)
+	return new(big.Int).Exp(g, randRange(rand.Reader, big1, q), p)
+}
+
+func TestElementEncryption(t *testing.T) {
+	for _, priv := range []*ElgamalPrivateKey{keys, dsaVectorKey()} {
+		pub := &priv.ElgamalPublicKey
+		p := new(big.Int).Add(pub.Q, big1)
+		a, b := randomGroupElement(pub), randomGroupElement(pub)
+
+		ca, err := ElgamalEncryptElement(rand.Reader, pub, a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cb, err := ElgamalEncryptElement(rand.Reader, pub, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Le produit des chiffrés chiffre le produit des messages
+		ab := new(big.Int).Mul(a, b)
+		ab.Mod(ab, p)
+		if m, err := ElgamalDecryptElement(priv, ElgamalMultiply(pub, ca, cb)); err != nil || m.Cmp(ab) != 0 {
+			t.Error("Le produit des chiffrés ne chiffre pas le produit des messages", err)
+		}
+
+		// Le chiffré rerandomisé est différent mais chiffre le même message
+		r := ElgamalRerandomize(rand.Reader, pub, ca)
+		if r.C1.Cmp(ca.C1) == 0 || r.C2.Cmp(ca.C2) == 0 {
+			t.Error("Le chiffré n'a pas été rerandomisé")
+		}
+		c, err := ParseElgamalCiphertext(r.GetBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, err := ElgamalDecryptElement(priv, c); err != nil || m.Cmp(a) != 0 {
+			t.Error("Le chiffré rerandomisé ne chiffre pas le même message", err)
+		}
+	}
+}
+
+func TestElementNotInGroup(t *testing.T) {
+	// Pour une clé ElGamal, seuls les carrés modulo p sont acceptés : g
+	// engendre Zp* et n'est donc pas un carré
+	if _, err := ElgamalEncryptElement(rand.Reader, &keys.ElgamalPublicKey, keys.G); err != errNotInGroup {
+		t.Error("Un message hors du sous-groupe a été accepté", err)
+	}
+
+	c := &ElgamalCiphertext{C1: keys.G, C2: big1}
+	if _, err := ElgamalDecryptElement(keys, c); err != errInvalidCiphertext {
+		t.Error("Un chiffré hors du sous-groupe a été accepté", err)
+	}
+}
+
+func TestExponentialTally(t *testing.T) {
+	pub := &keys.ElgamalPublicKey
+	votes := []int64{1, 0, 1, 1, 0, 1, 0, 0, 1, 1, 1, 0, 1}
+
+	var total int64
+	tally, err := ElgamalEncryptExponent(rand.Reader, pub, big0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range votes {
+		c, err := ElgamalEncryptExponent(rand.Reader, pub, big.NewInt(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tally = ElgamalMultiply(pub, tally, c)
+		total += v
+	}
+
+	m, err := ElgamalDecryptExponent(keys, tally, uint64(len(votes)))
+	if err != nil || m.Int64() != total {
+		t.Error("Décompte incorrect :", m, err)
+	}
+
+	if _, err := ElgamalDecryptExponent(keys, tally, uint64(total-1)); err != errExponentRange {
+		t.Error("Un message hors de la borne a été déchiffré", err)
+	}
+	if _, err := ElgamalDecryptExponent(keys, tally, exponentMaxBound+1); err != errExponentBound {
+		t.Error("Une borne trop grande a été acceptée", err)
+	}
+}
+
+func TestDiscreteLog(t *testing.T) {
+	pub := &keys.ElgamalPublicKey
+	p, _, g, _ := schnorrGroup(pub)
+
+	for _, m := range []uint64{0, 1, 99, 100, 1000, 123456} {
+		bound := m
+		if m < 1000 {
+			bound = 1000
+		}
+		if r, ok := discreteLog(pub, new(big.Int).Exp(g, new(big.Int).SetUint64(m), p), bound); !ok || r != m {
+			t.Error("Logarithme discret incorrect pour", m, r)
+		}
+	}
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 func usage() {
 	fmt.Println(`
-Usage: gocrypto { aes | elgamal }
+Usage: gocrypto { aes | elgamal | ecc | rsa | pgp | dh }
 
     * gocrypto aes
             genkey [-size=128] <key-file>
@@ -16,15 +24,66 @@ Usage: gocrypto { aes | elgamal }
             decrypt <key-file> <cipher-file> [ <plain-file> ]
 
     * gocrypto elgamal
-            genkey [-size=160] <priv-key-file>
+            genkey [-size=160] [-jobs=0] [-group=ffdhe2048 | -dsa] [-format=raw] [-protect] <priv-key-file>
+            encrypt [-skip-validation] <pub-key-file>... <plain-file> <cipher-file>
+            decrypt <priv-key-file> <cipher-file> [ <plain-file> ]
+            sign [-hash=sha256] [-scheme=elgamal] [-detached] [-hedged] <priv-key-file> <file>
+            check [-skip-validation] <pub-key-file> <signed-file>
+            check [-skip-validation] <pub-key-file> <file> <sig-file>
+            check-batch [-skip-validation] <manifest-file>
+            fingerprint <key-file>
+            verify-key <key-file>
+            prove [-context=""] <priv-key-file> <proof-file>
+            prove <priv-key-file> <cipher-file> <plain-file> <proof-file>
+            verify-proof [-context=""] <pub-key-file> <proof-file>
+            verify-proof <pub-key-file> <cipher-file> <plain-file> <proof-file>
+            threshold deal [-group=ffdhe2048] -t=<t> -n=<n> -index=<i> <out-prefix>
+            threshold finish [-protect] <share-key-file> <commitments-or-share-file>...
+            threshold partial <share-key-file> <cipher-file> <partial-file>
+            threshold combine <pub-key-file> <cipher-file> <partial-file>... <plain-file>
+            proxy encrypt <pub-key-file> <plain-file> <cipher-file>
+            proxy rekey <from-priv-key-file> <to-priv-key-file> <rekey-file>
+            proxy reencrypt <rekey-file> <cipher-file> <new-cipher-file>
+            proxy decrypt <priv-key-file> <cipher-file> [ <plain-file> ]
+
+    * gocrypto ecc
+            genkey [-curve=curve25519] <priv-key-file>
             encrypt <pub-key-file> <plain-file> <cipher-file>
             decrypt <priv-key-file> <cipher-file> [ <plain-file> ]
             sign <priv-key-file> <file>
             check <pub-key-file> <signed-file>
+
+    * gocrypto rsa
+            genkey [-size=2048] [-blind] <priv-key-file>
+            encrypt <pub-key-file> <plain-file> <cipher-file>
+            decrypt <priv-key-file> <cipher-file> [ <plain-file> ]
+            sign [-hash=sha256] <priv-key-file> <file>
+            check <pub-key-file> <signed-file>
+            blind <pub-key-file> <file> <request-file> <secret-file>
+            blind-sign <priv-key-file> <request-file> <response-file>
+            unblind <pub-key-file> <secret-file> <response-file> <signature-file>
+            blind-check <pub-key-file> <signature-file> [ <file> ]
+
+    * gocrypto pgp
+            import [-secret] [-format=raw] <pgp-key-file> <key-file>
+            export [-secret] [-created=0] <key-file> <pgp-key-file>
+            encrypt [-skip-validation] <pgp-pub-key-file> <plain-file> <cipher-file>
+            decrypt <pgp-priv-key-file> <cipher-file> [ <plain-file> ]
+
+    * gocrypto dh
+            derive [-size=128] [-skip-validation] <priv-key-file> <peer-pub-key-file> <aes-key-file>
 `[1:])
 	os.Exit(255)
 }
 
+// Affiche l'erreur et quitte le programme si err n'est pas nil
+func checkError(err error) {
+	if err != nil {
+		fmt.Println("Erreur :", err)
+		os.Exit(1)
+	}
+}
+
 func aes() {
 	cmd := os.Args[2]
 	switch cmd {
@@ -71,6 +130,9 @@ func aes() {
 		key := readBytes(keyPath)
 
 		d := AESDecrypt(cipher, key)
+		if d == nil {
+			checkError(errInvalidCiphertext)
+		}
 		if dataPath == "" {
 			os.Stdout.Write(d)
 		} else {
@@ -81,26 +143,740 @@ func aes() {
 	}
 }
 
+// Lit le fichier de clé privée path, en demandant sa phrase de passe si
+// la clé est protégée
+func readPrivateKeyFile(path string) ([]byte, error) {
+	b := readBytes(path)
+
+	if IsProtectedKey(b) {
+		passphrase, err := readPassphrase("Phrase de passe de "+path+" : ", false)
+		if err != nil {
+			return nil, err
+		}
+		return UnprotectPrivateKey(b, passphrase)
+	}
+	return b, nil
+}
+
+// Charge la clé privée ElGamal du fichier path, en demandant sa phrase de
+// passe si elle est protégée
+func loadElgamalPrivateKey(path string) (*ElgamalPrivateKey, error) {
+	b, err := readPrivateKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseElgamalPrivateKey(b)
+}
+
+// Charge la part de clé du fichier path, en demandant sa phrase de passe
+// si elle est protégée
+func loadThresholdKey(path string) (*ThresholdKey, error) {
+	b, err := readPrivateKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseThresholdKey(b)
+}
+
+// Charge la clé ElGamal du fichier path, privée (priv n'est alors pas nil)
+// ou publique. Les clés privées sont reconnues en premier.
+func loadElgamalKey(path string) (pub *ElgamalPublicKey, priv *ElgamalPrivateKey, err error) {
+	b := readBytes(path)
+
+	if IsProtectedKey(b) {
+		priv, err = loadElgamalPrivateKey(path)
+	} else if priv, err = ParseElgamalPrivateKey(b); err != nil {
+		pub, err = ParseElgamalPublicKey(b)
+		return pub, nil, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &priv.ElgamalPublicKey, priv, nil
+}
+
 func elgamal() {
 	cmd := os.Args[2]
 	switch cmd {
 	case "genkey":
 		fs := flag.NewFlagSet("genkey", flag.ExitOnError)
 		keySize := fs.Int("size", 160, "Taille de la clé")
+		jobs := fs.Int("jobs", 0, "Nombre de recherches parallèles (0 = nombre de processeurs)")
+		group := fs.String("group", "", "Groupe standard à utiliser ("+standardGroupList()+")")
+		dsa := fs.Bool("dsa", false, "Génère des paramètres DSA (FIPS 186-4) de size bits (1024, 2048, 3072)")
+		format := fs.String("format", formatRaw, "Format des fichiers de clé (pem, der, raw)")
+		protect := fs.Bool("protect", false, "Chiffre la clé privée avec une phrase de passe")
 		fs.Parse(os.Args[3:])
 
 		if fs.Arg(0) == "" {
 			usage()
 		}
 
-		fmt.Printf("Géneration de la clé de %d bits... ", *keySize)
-		priv := GenerateElgamalKeys(*keySize)
-		pub := priv.ElgamalPublicKey
+		if *format != formatRaw && *format != formatDER && *format != formatPEM {
+			fmt.Println("Format de clé inconnu :", *format)
+			os.Exit(1)
+		}
+
+		// La phrase de passe est demandée avant la génération, qui peut
+		// être longue
+		var passphrase []byte
+		if *protect {
+			var err error
+			passphrase, err = readPassphrase("Phrase de passe de la clé : ", true)
+			checkError(err)
+		}
+
+		var priv *ElgamalPrivateKey
+		if *dsa {
+			N, err := dsaSubgroupSize(*keySize)
+			checkError(err)
+
+			fmt.Printf("Géneration des paramètres DSA (L=%d, N=%d)... ", *keySize, N)
+			params, err := GenerateDSAParameters(rand.Reader, *keySize, N)
+			checkError(err)
+			priv = GenerateDSAKeys(rand.Reader, params)
+		} else if *group != "" {
+			fmt.Printf("Géneration de la clé dans le groupe %s... ", *group)
+			var err error
+			priv, err = GenerateElgamalKeysInGroup(rand.Reader, *group)
+			checkError(err)
+		} else {
+			if *keySize < elgamalMinSize {
+				fmt.Printf("La taille de la clé doit être d'au moins %d bits\n", elgamalMinSize)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Géneration de la clé de %d bits... ", *keySize)
+			priv = GenerateElgamalKeys(rand.Reader, *keySize, *jobs)
+		}
+		fmt.Println("Terminé")
+
+		privBytes, err := priv.Encode(*format)
+		checkError(err)
+		if *protect {
+			privBytes = ProtectPrivateKey(rand.Reader, privBytes, passphrase, *format == formatPEM)
+		}
+		pubBytes, err := priv.ElgamalPublicKey.Encode(*format)
+		checkError(err)
+
+		filename := fs.Arg(0)
+		writeBytes(privBytes, filename)
+		writeBytes(pubBytes, filename+".pub")
+		fmt.Println("Empreinte :", formatFingerprint(priv.Fingerprint()))
+	case "encrypt":
+		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+		skipValidation := fs.Bool("skip-validation", false, "Ne vérifie pas la clé publique (dangereux)")
+		fs.Parse(os.Args[3:])
+
+		// Les derniers arguments sont les fichiers du message, les autres
+		// les clés publiques des destinataires
+		args := fs.Args()
+		if len(args) < 3 {
+			usage()
+		}
+
+		pubKeyPaths := args[:len(args)-2]
+		dataPath, cipherPath := args[len(args)-2], args[len(args)-1]
+
+		pubs := make([]*ElgamalPublicKey, len(pubKeyPaths))
+		for i, path := range pubKeyPaths {
+			pub, err := ParseElgamalPublicKey(readBytes(path))
+			checkError(err)
+			if !*skipValidation {
+				checkError(pub.Validate())
+			}
+			pubs[i] = pub
+		}
+
+		data := readBytes(dataPath)
+
+		// Le format à un seul destinataire reste utilisé quand c'est possible
+		var c []byte
+		if len(pubs) == 1 {
+			c = ElgamalEncrypt(rand.Reader, pubs[0], data)
+		} else {
+			var err error
+			c, err = ElgamalEncryptMulti(rand.Reader, pubs, data)
+			checkError(err)
+		}
+		writeBytes(c, cipherPath)
+	case "decrypt":
+		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		privateKeyPath, cipherPath, dataPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		cipher := readBytes(cipherPath)
+		priv, err := loadElgamalPrivateKey(privateKeyPath)
+		checkError(err)
+
+		d, err := ElgamalDecrypt(priv, cipher)
+		checkError(err)
+		if dataPath == "" {
+			os.Stdout.Write(d)
+		} else {
+			writeBytes(d, dataPath)
+		}
+	case "sign":
+		fs := flag.NewFlagSet("sign", flag.ExitOnError)
+		hashAlgo := fs.String("hash", "sha256", "Algorithme de hachage (sha256, sha512, sha3-256)")
+		scheme := fs.String("scheme", schemeElgamal, "Schéma de signature (elgamal, dsa, schnorr)")
+		detached := fs.Bool("detached", false, "Écrit uniquement la signature dans <file>.sig")
+		hedged := fs.Bool("hedged", false, "Ajoute de l'aléa au nonce déterministe (signatures non reproductibles)")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		h, ok := signatureHashes[*hashAlgo]
+		if !ok {
+			fmt.Println("Algorithme de hachage inconnu :", *hashAlgo)
+			os.Exit(1)
+		}
+
+		privateKeyPath, dataPath := fs.Arg(0), fs.Arg(1)
+		priv, err := loadElgamalPrivateKey(privateKeyPath)
+		checkError(err)
+
+		// Par défaut, le nonce est dérivé de la clé et du document
+		var random io.Reader
+		if *hedged {
+			random = rand.Reader
+		}
+
+		if *detached {
+			// Le document est haché au fil de la lecture, sans être chargé
+			// en mémoire
+			f := openFile(dataPath)
+			defer f.Close()
+
+			signature, err := ElgamalSignReader(random, priv, f, h, *scheme)
+			checkError(err)
+			writeBytes(signature, dataPath+".sig")
+		} else {
+			data := readBytes(dataPath)
+			signature, err := ElgamalSignDetached(random, priv, data, h, *scheme)
+			checkError(err)
+			writeBytes(serialize(data, signature), dataPath+".signed")
+		}
+	case "check":
+		fs := flag.NewFlagSet("check", flag.ExitOnError)
+		skipValidation := fs.Bool("skip-validation", false, "Ne vérifie pas la clé publique (dangereux)")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		pub, err := ParseElgamalPublicKey(readBytes(fs.Arg(0)))
+		checkError(err)
+		if !*skipValidation {
+			checkError(pub.Validate())
+		}
+
+		var (
+			msg       io.Reader
+			signature []byte
+		)
+		if fs.Arg(2) != "" {
+			// Signature détachée : <pub-key> <file> <sig>
+			signature = readBytes(fs.Arg(2))
+			f := openFile(fs.Arg(1))
+			defer f.Close()
+			msg = f
+		} else {
+			d := deserialize(readBytes(fs.Arg(1)))
+			if len(d) != 2 {
+				fmt.Println("Invalid signature")
+				return
+			}
+			msg, signature = bytes.NewReader(d[0]), d[1]
+		}
+
+		valid, err := ElgamalCheckReader(pub, msg, signature)
+		var mismatch *KeyMismatchError
+		if errors.As(err, &mismatch) {
+			fmt.Printf("Invalid signature : signée avec la clé %016X, la clé fournie est %016X\n", mismatch.Expected, mismatch.Actual)
+			return
+		}
+		checkError(err)
+
+		if valid {
+			fmt.Println("Signature OK")
+		} else {
+			fmt.Println("Invalid signature")
+		}
+	case "check-batch":
+		elgamalCheckBatch()
+	case "fingerprint":
+		fs := flag.NewFlagSet("fingerprint", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" {
+			usage()
+		}
+
+		// La clé peut être publique ou privée
+		pub, _, err := loadElgamalKey(fs.Arg(0))
+		checkError(err)
+
+		fmt.Println(formatFingerprint(pub.Fingerprint()))
+	case "prove":
+		fs := flag.NewFlagSet("prove", flag.ExitOnError)
+		context := fs.String("context", "", "Contexte de la preuve de possession de la clé (par exemple un nombre choisi par le vérificateur)")
+		fs.Parse(os.Args[3:])
+
+		// Avec deux arguments, preuve de possession de la clé ; avec quatre,
+		// preuve de déchiffrement du message
+		var proof *Proof
+		switch fs.NArg() {
+		case 2:
+			priv, err := loadElgamalPrivateKey(fs.Arg(0))
+			checkError(err)
+			proof = ProveKeyOwnership(rand.Reader, priv, []byte(*context))
+		case 4:
+			priv, err := loadElgamalPrivateKey(fs.Arg(0))
+			checkError(err)
+			var d []byte
+			d, proof, err = ProveDecryption(rand.Reader, priv, readBytes(fs.Arg(1)))
+			checkError(err)
+			writeBytes(d, fs.Arg(2))
+		default:
+			usage()
+		}
+		writeBytes(proof.GetBytes(), fs.Arg(fs.NArg()-1))
+	case "verify-proof":
+		fs := flag.NewFlagSet("verify-proof", flag.ExitOnError)
+		context := fs.String("context", "", "Contexte de la preuve de possession de la clé")
+		fs.Parse(os.Args[3:])
+
+		if fs.NArg() != 2 && fs.NArg() != 4 {
+			usage()
+		}
+
+		pub, err := ParseElgamalPublicKey(readBytes(fs.Arg(0)))
+		checkError(err)
+		proof, err := ParseProof(readBytes(fs.Arg(fs.NArg() - 1)))
+		checkError(err)
+
+		if fs.NArg() == 2 {
+			err = VerifyKeyOwnership(pub, []byte(*context), proof)
+		} else {
+			err = VerifyDecryption(pub, readBytes(fs.Arg(1)), readBytes(fs.Arg(2)), proof)
+		}
+
+		if err != nil {
+			fmt.Println("Preuve invalide :", err)
+			os.Exit(1)
+		}
+		fmt.Println("Preuve valide")
+	case "threshold":
+		elgamalThreshold()
+	case "proxy":
+		elgamalProxy()
+	case "verify-key":
+		fs := flag.NewFlagSet("verify-key", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" {
+			usage()
+		}
+
+		// Pour une clé privée, on vérifie également que h = g^x
+		pub, priv, err := loadElgamalKey(fs.Arg(0))
+		checkError(err)
+		if priv != nil {
+			err = priv.Validate()
+		} else {
+			err = pub.Validate()
+		}
+
+		if err != nil {
+			fmt.Println("Clé invalide :", err)
+			os.Exit(1)
+		}
+		fmt.Println("Clé valide")
+	default:
+		usage()
+	}
+}
+
+// Déchiffrement à seuil : génération distribuée de la clé et déchiffrement
+// par au moins t des n participants, les fichiers étant échangés entre eux
+func elgamalThreshold() {
+	if len(os.Args) < 4 {
+		usage()
+	}
+
+	cmd := os.Args[3]
+	switch cmd {
+	case "deal":
+		fs := flag.NewFlagSet("deal", flag.ExitOnError)
+		group := fs.String("group", "ffdhe2048", "Groupe standard à utiliser ("+standardGroupList()+")")
+		threshold := fs.Int("t", 0, "Nombre de participants nécessaires au déchiffrement")
+		participants := fs.Int("n", 0, "Nombre total de participants")
+		index := fs.Int("index", 0, "Numéro du participant (de 1 à n)")
+		fs.Parse(os.Args[4:])
+
+		if fs.Arg(0) == "" {
+			usage()
+		}
+
+		params, ok := standardGroups[*group]
+		if !ok {
+			checkError(errUnknownGroup)
+		}
+
+		commitments, shares, err := DKGDeal(rand.Reader, params.P, params.G, *threshold, *participants, *index)
+		checkError(err)
+
+		// Les engagements sont publiés, chaque part doit être transmise
+		// confidentiellement à son destinataire
+		prefix := fs.Arg(0)
+		writeBytes(commitments.GetBytes(), prefix+".commitments")
+		for _, s := range shares {
+			writeBytes(s.GetBytes(), fmt.Sprintf("%s.share%d", prefix, s.Recipient))
+		}
+		fmt.Printf("Engagements : %s.commitments, parts : %s.share1 à %s.share%d\n", prefix, prefix, prefix, *participants)
+	case "finish":
+		fs := flag.NewFlagSet("finish", flag.ExitOnError)
+		protect := fs.Bool("protect", false, "Chiffre la part de clé avec une phrase de passe")
+		fs.Parse(os.Args[4:])
+
+		if fs.NArg() < 3 {
+			usage()
+		}
+
+		var commitments []*DKGCommitments
+		var shares []*DKGShare
+		for _, path := range fs.Args()[1:] {
+			b := readBytes(path)
+			if c, err := ParseDKGCommitments(b); err == nil {
+				commitments = append(commitments, c)
+				continue
+			}
+			s, err := ParseDKGShare(b)
+			if err != nil {
+				checkError(fmt.Errorf("%s : %w", path, err))
+			}
+			shares = append(shares, s)
+		}
+
+		key, err := DKGFinish(commitments, shares)
+		checkError(err)
+
+		keyBytes := key.GetBytes()
+		if *protect {
+			passphrase, err := readPassphrase("Phrase de passe de la part de clé : ", true)
+			checkError(err)
+			keyBytes = ProtectPrivateKey(rand.Reader, keyBytes, passphrase, false)
+		}
+
+		filename := fs.Arg(0)
+		writeBytes(keyBytes, filename)
+		writeBytes(key.ElgamalPublicKey.GetBytes(), filename+".pub")
+		fmt.Printf("Participant %d sur %d, seuil %d\n", key.Index, key.Participants, key.Threshold)
+		fmt.Println("Empreinte :", formatFingerprint(key.Fingerprint()))
+	case "partial":
+		fs := flag.NewFlagSet("partial", flag.ExitOnError)
+		fs.Parse(os.Args[4:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" || fs.Arg(2) == "" {
+			usage()
+		}
+
+		keyPath, cipherPath, partialPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		key, err := loadThresholdKey(keyPath)
+		checkError(err)
+
+		partial, err := key.PartialDecrypt(readBytes(cipherPath))
+		checkError(err)
+		writeBytes(partial.GetBytes(), partialPath)
+	case "combine":
+		fs := flag.NewFlagSet("combine", flag.ExitOnError)
+		fs.Parse(os.Args[4:])
+
+		args := fs.Args()
+		if len(args) < 4 {
+			usage()
+		}
+
+		pubKeyPath, cipherPath := args[0], args[1]
+		partialPaths, dataPath := args[2:len(args)-1], args[len(args)-1]
+
+		pub, err := ParseElgamalPublicKey(readBytes(pubKeyPath))
+		checkError(err)
+
+		partials := make([]*PartialDecryption, len(partialPaths))
+		for i, path := range partialPaths {
+			partials[i], err = ParsePartialDecryption(readBytes(path))
+			checkError(err)
+		}
+
+		d, err := ThresholdDecrypt(pub, readBytes(cipherPath), partials)
+		checkError(err)
+		writeBytes(d, dataPath)
+	default:
+		usage()
+	}
+}
+
+// Nombre de lignes du manifeste vérifiées ensemble par check-batch
+const manifestChunkSize = 1024
+
+// Ligne du manifeste de check-batch
+type manifestEntry struct {
+	line   int
+	fields []string
+}
+
+// Vérification par lots des signatures listées dans un manifeste. Chaque
+// ligne contient, comme les arguments de check, une clé publique et un
+// document signé, ou une clé publique, un document et sa signature
+// détachée. Les chemins relatifs partent du dossier du manifeste ; les
+// lignes vides et celles commençant par # sont ignorées.
+func elgamalCheckBatch() {
+	fs := flag.NewFlagSet("check-batch", flag.ExitOnError)
+	skipValidation := fs.Bool("skip-validation", false, "Ne vérifie pas les clés publiques (dangereux)")
+	fs.Parse(os.Args[3:])
+
+	if fs.Arg(0) == "" {
+		usage()
+	}
+
+	f := openFile(fs.Arg(0))
+	defer f.Close()
+	dir := filepath.Dir(fs.Arg(0))
+
+	// Les clés sont chargées et validées une seule fois
+	type loadedKey struct {
+		pub *ElgamalPublicKey
+		err error
+	}
+	loaded := make(map[string]loadedKey)
+	loadKey := func(path string) (*ElgamalPublicKey, error) {
+		if k, ok := loaded[path]; ok {
+			return k.pub, k.err
+		}
+		b, err := os.ReadFile(path)
+		var pub *ElgamalPublicKey
+		if err == nil {
+			pub, err = ParseElgamalPublicKey(b)
+		}
+		if err == nil && !*skipValidation {
+			err = pub.Validate()
+		}
+		loaded[path] = loadedKey{pub, err}
+		return pub, err
+	}
+
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	var total, failed int
+
+	// Vérifie les signatures d'un groupe de lignes du manifeste et affiche
+	// les lignes en échec dans l'ordre du manifeste
+	verify := func(entries []manifestEntry) {
+		var (
+			msgs     []SignedMessage
+			pending  []manifestEntry
+			failures = make(map[int]string)
+		)
+		report := func(line int, reason string) {
+			failures[line] = reason
+		}
+		for _, e := range entries {
+			total++
+			if len(e.fields) != 2 && len(e.fields) != 3 {
+				report(e.line, "ligne mal formée")
+				continue
+			}
+
+			pub, err := loadKey(resolve(e.fields[0]))
+			if err != nil {
+				report(e.line, "clé "+e.fields[0]+" : "+err.Error())
+				continue
+			}
+
+			data, err := os.ReadFile(resolve(e.fields[1]))
+			if err != nil {
+				report(e.line, err.Error())
+				continue
+			}
+
+			var signature []byte
+			if len(e.fields) == 3 {
+				signature, err = os.ReadFile(resolve(e.fields[2]))
+				if err != nil {
+					report(e.line, err.Error())
+					continue
+				}
+			} else {
+				d := deserialize(data)
+				if len(d) != 2 {
+					report(e.line, "signature invalide ("+e.fields[1]+")")
+					continue
+				}
+				data, signature = d[0], d[1]
+			}
+
+			msgs = append(msgs, SignedMessage{Pub: pub, Data: data, Signature: signature})
+			pending = append(pending, e)
+		}
+
+		for _, i := range ElgamalCheckBatch(rand.Reader, msgs) {
+			e := pending[i]
+			report(e.line, "signature invalide ("+e.fields[len(e.fields)-1]+")")
+		}
+
+		for _, e := range entries {
+			if reason, ok := failures[e.line]; ok {
+				fmt.Printf("Ligne %d : %s\n", e.line, reason)
+			}
+		}
+		failed += len(failures)
+	}
+
+	var entries []manifestEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		entries = append(entries, manifestEntry{line: line, fields: fields})
+		if len(entries) == manifestChunkSize {
+			verify(entries)
+			entries = entries[:0]
+		}
+	}
+	checkError(scanner.Err())
+	verify(entries)
+
+	fmt.Printf("%d signatures vérifiées, %d invalides\n", total, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// Re-chiffrement par mandataire : un message chiffré pour une clé peut
+// être transformé en un message pour une autre clé sans être déchiffré
+func elgamalProxy() {
+	if len(os.Args) < 4 {
+		usage()
+	}
+
+	cmd := os.Args[3]
+	switch cmd {
+	case "encrypt":
+		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+		skipValidation := fs.Bool("skip-validation", false, "Ne vérifie pas la clé publique (dangereux)")
+		fs.Parse(os.Args[4:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" || fs.Arg(2) == "" {
+			usage()
+		}
+
+		pubKeyPath, dataPath, cipherPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		pub, err := ParseElgamalPublicKey(readBytes(pubKeyPath))
+		checkError(err)
+		if !*skipValidation {
+			checkError(pub.Validate())
+		}
+
+		writeBytes(ProxyEncrypt(rand.Reader, pub, readBytes(dataPath)), cipherPath)
+	case "rekey":
+		fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+		fs.Parse(os.Args[4:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" || fs.Arg(2) == "" {
+			usage()
+		}
+
+		fromPath, toPath, rekeyPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		from, err := loadElgamalPrivateKey(fromPath)
+		checkError(err)
+		to, err := loadElgamalPrivateKey(toPath)
+		checkError(err)
+
+		rk, err := GenerateReEncryptionKey(from, to)
+		checkError(err)
+		writeBytes(rk.GetBytes(), rekeyPath)
+	case "reencrypt":
+		fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+		fs.Parse(os.Args[4:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" || fs.Arg(2) == "" {
+			usage()
+		}
+
+		rekeyPath, cipherPath, newCipherPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		rk, err := ParseReEncryptionKey(readBytes(rekeyPath))
+		checkError(err)
+
+		c, err := ProxyReEncrypt(rk, readBytes(cipherPath))
+		checkError(err)
+		writeBytes(c, newCipherPath)
+	case "decrypt":
+		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+		fs.Parse(os.Args[4:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		privateKeyPath, cipherPath, dataPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		priv, err := loadElgamalPrivateKey(privateKeyPath)
+		checkError(err)
+
+		d, err := ProxyDecrypt(priv, readBytes(cipherPath))
+		checkError(err)
+		if dataPath == "" {
+			os.Stdout.Write(d)
+		} else {
+			writeBytes(d, dataPath)
+		}
+	default:
+		usage()
+	}
+}
+
+func ecc() {
+	cmd := os.Args[2]
+	switch cmd {
+	case "genkey":
+		fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+		curve := fs.String("curve", curveCurve25519, "Courbe elliptique (p256, curve25519)")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" {
+			usage()
+		}
+
+		fmt.Printf("Géneration de la clé sur la courbe %s... ", *curve)
+		priv, err := GenerateECCKeys(rand.Reader, *curve)
+		checkError(err)
 		fmt.Println("Terminé")
 
 		filename := fs.Arg(0)
 		writeBytes(priv.GetBytes(), filename)
-		writeBytes(pub.GetBytes(), filename+".pub")
+		writeBytes(priv.ECCPublicKey.GetBytes(), filename+".pub")
 	case "encrypt":
 		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
 		fs.Parse(os.Args[3:])
@@ -112,9 +888,11 @@ func elgamal() {
 		pubKeyPath, dataPath, cipherPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
 
 		data := readBytes(dataPath)
-		pub := LoadPublicKey(readBytes(pubKeyPath))
+		pub, err := LoadECCPublicKey(readBytes(pubKeyPath))
+		checkError(err)
 
-		c := ElgamalEncrypt(pub, data)
+		c, err := ECCEncrypt(rand.Reader, pub, data)
+		checkError(err)
 		writeBytes(c, cipherPath)
 	case "decrypt":
 		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
@@ -127,9 +905,11 @@ func elgamal() {
 		privateKeyPath, cipherPath, dataPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
 
 		cipher := readBytes(cipherPath)
-		priv := LoadPrivateKey(readBytes(privateKeyPath))
+		priv, err := LoadECCPrivateKey(readBytes(privateKeyPath))
+		checkError(err)
 
-		d := ElgamalDecrypt(priv, cipher)
+		d, err := ECCDecrypt(priv, cipher)
+		checkError(err)
 		if dataPath == "" {
 			os.Stdout.Write(d)
 		} else {
@@ -145,12 +925,123 @@ func elgamal() {
 
 		privateKeyPath, dataPath := fs.Arg(0), fs.Arg(1)
 		data := readBytes(dataPath)
-		priv := LoadPrivateKey(readBytes(privateKeyPath))
+		priv, err := LoadECCPrivateKey(readBytes(privateKeyPath))
+		checkError(err)
 
-		signedData := ElgamalSign(priv, data)
+		signedData, err := ECCSign(rand.Reader, priv, data)
+		checkError(err)
 		writeBytes(signedData, dataPath+".signed")
 	case "check":
+		fs := flag.NewFlagSet("check", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		pubKeyPath, signedDataPath := fs.Arg(0), fs.Arg(1)
+		signedData := readBytes(signedDataPath)
+		pub, err := LoadECCPublicKey(readBytes(pubKeyPath))
+		checkError(err)
+
+		if ECCCheck(pub, signedData) {
+			fmt.Println("Signature OK")
+		} else {
+			fmt.Println("Invalid signature")
+		}
+	default:
+		usage()
+	}
+}
+
+func rsa() {
+	cmd := os.Args[2]
+	switch cmd {
+	case "genkey":
+		fs := flag.NewFlagSet("genkey", flag.ExitOnError)
+		keySize := fs.Int("size", 2048, "Taille de la clé")
+		blind := fs.Bool("blind", false, "Génère une clé réservée aux signatures aveugles")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" {
+			usage()
+		}
+
+		fmt.Printf("Géneration de la clé de %d bits... ", *keySize)
+		generate := GenerateRSAKeys
+		if *blind {
+			generate = GenerateRSABlindKeys
+		}
+		priv, err := generate(rand.Reader, *keySize)
+		checkError(err)
+		fmt.Println("Terminé")
+
+		filename := fs.Arg(0)
+		writeBytes(priv.GetBytes(), filename)
+		writeBytes(priv.RSAPublicKey.GetBytes(), filename+".pub")
+	case "encrypt":
+		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" || fs.Arg(2) == "" {
+			usage()
+		}
+
+		pubKeyPath, dataPath, cipherPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		data := readBytes(dataPath)
+		pub, err := LoadRSAPublicKey(readBytes(pubKeyPath))
+		checkError(err)
+
+		c, err := RSAEncrypt(rand.Reader, pub, data)
+		checkError(err)
+		writeBytes(c, cipherPath)
+	case "decrypt":
+		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		privateKeyPath, cipherPath, dataPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		cipher := readBytes(cipherPath)
+		priv, err := LoadRSAPrivateKey(readBytes(privateKeyPath))
+		checkError(err)
+
+		d, err := RSADecrypt(priv, cipher)
+		checkError(err)
+		if dataPath == "" {
+			os.Stdout.Write(d)
+		} else {
+			writeBytes(d, dataPath)
+		}
+	case "sign":
 		fs := flag.NewFlagSet("sign", flag.ExitOnError)
+		hashAlgo := fs.String("hash", "sha256", "Algorithme de hachage (sha256, sha512, sha3-256)")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		h, ok := signatureHashes[*hashAlgo]
+		if !ok {
+			fmt.Println("Algorithme de hachage inconnu :", *hashAlgo)
+			os.Exit(1)
+		}
+
+		privateKeyPath, dataPath := fs.Arg(0), fs.Arg(1)
+		data := readBytes(dataPath)
+		priv, err := LoadRSAPrivateKey(readBytes(privateKeyPath))
+		checkError(err)
+
+		signedData, err := RSASign(rand.Reader, priv, data, h)
+		checkError(err)
+		writeBytes(signedData, dataPath+".signed")
+	case "check":
+		fs := flag.NewFlagSet("check", flag.ExitOnError)
 		fs.Parse(os.Args[3:])
 
 		if fs.Arg(0) == "" || fs.Arg(1) == "" {
@@ -159,13 +1050,216 @@ func elgamal() {
 
 		pubKeyPath, signedDataPath := fs.Arg(0), fs.Arg(1)
 		signedData := readBytes(signedDataPath)
-		pub := LoadPublicKey(readBytes(pubKeyPath))
+		pub, err := LoadRSAPublicKey(readBytes(pubKeyPath))
+		checkError(err)
 
-		if ElgamalCheck(pub, signedData) {
+		if RSACheck(pub, signedData) {
 			fmt.Println("Signature OK")
 		} else {
 			fmt.Println("Invalid signature")
 		}
+	case "blind":
+		// Demande de signature aveugle : la demande est envoyée au
+		// signataire, le secret reste chez le demandeur
+		fs := flag.NewFlagSet("blind", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.NArg() != 4 {
+			usage()
+		}
+
+		pub, err := LoadRSAPublicKey(readBytes(fs.Arg(0)))
+		checkError(err)
+
+		request, secret, err := RSABlind(rand.Reader, pub, readBytes(fs.Arg(1)))
+		checkError(err)
+		writeBytes(request, fs.Arg(2))
+		writeBytes(secret.GetBytes(), fs.Arg(3))
+	case "blind-sign":
+		fs := flag.NewFlagSet("blind-sign", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.NArg() != 3 {
+			usage()
+		}
+
+		priv, err := LoadRSAPrivateKey(readBytes(fs.Arg(0)))
+		checkError(err)
+
+		response, err := RSABlindSign(priv, readBytes(fs.Arg(1)))
+		checkError(err)
+		writeBytes(response, fs.Arg(2))
+	case "unblind":
+		fs := flag.NewFlagSet("unblind", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.NArg() != 4 {
+			usage()
+		}
+
+		pub, err := LoadRSAPublicKey(readBytes(fs.Arg(0)))
+		checkError(err)
+		secret, err := ParseRSABlindingSecret(readBytes(fs.Arg(1)))
+		checkError(err)
+
+		signature, err := RSAUnblind(pub, secret, readBytes(fs.Arg(2)))
+		checkError(err)
+		writeBytes(signature, fs.Arg(3))
+	case "blind-check":
+		fs := flag.NewFlagSet("blind-check", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.NArg() != 2 && fs.NArg() != 3 {
+			usage()
+		}
+
+		pub, err := LoadRSAPublicKey(readBytes(fs.Arg(0)))
+		checkError(err)
+
+		// Le message signé est écrit dans <file> s'il est demandé
+		msg, ok := RSABlindCheck(pub, readBytes(fs.Arg(1)))
+		if !ok {
+			fmt.Println("Invalid signature")
+			os.Exit(1)
+		}
+		if fs.Arg(2) != "" {
+			writeBytes(msg, fs.Arg(2))
+		}
+		fmt.Println("Signature OK")
+	default:
+		usage()
+	}
+}
+
+func pgp() {
+	cmd := os.Args[2]
+	switch cmd {
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		secret := fs.Bool("secret", false, "Importe la clé secrète")
+		format := fs.String("format", formatRaw, "Format du fichier de clé (pem, der, raw)")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		pgpKeyPath, keyPath := fs.Arg(0), fs.Arg(1)
+
+		var b []byte
+		if *secret {
+			priv, err := ReadOpenPGPPrivateKey(readBytes(pgpKeyPath))
+			checkError(err)
+			b, err = priv.ElgamalPrivateKey.Encode(*format)
+			checkError(err)
+		} else {
+			pub, err := ReadOpenPGPPublicKey(readBytes(pgpKeyPath))
+			checkError(err)
+			b, err = pub.ElgamalPublicKey.Encode(*format)
+			checkError(err)
+		}
+		writeBytes(b, keyPath)
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		secret := fs.Bool("secret", false, "Exporte la clé secrète")
+		createdAt := fs.Int64("created", 0, "Date de création de la clé (timestamp Unix, date courante si 0)")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		keyPath, pgpKeyPath := fs.Arg(0), fs.Arg(1)
+
+		// La date de création fait partie de l'identifiant de la clé
+		// OpenPGP : par défaut, la clé exportée porte la date de l'export
+		created := time.Unix(*createdAt, 0).UTC()
+		if *createdAt == 0 {
+			created = time.Now().UTC().Truncate(time.Second)
+		}
+		if *secret {
+			priv, err := loadElgamalPrivateKey(keyPath)
+			checkError(err)
+			writeBytes((&OpenPGPPrivateKey{ElgamalPrivateKey: *priv, Created: created}).Export(), pgpKeyPath)
+		} else {
+			pub, err := ParseElgamalPublicKey(readBytes(keyPath))
+			checkError(err)
+			writeBytes((&OpenPGPPublicKey{ElgamalPublicKey: *pub, Created: created}).Export(), pgpKeyPath)
+		}
+	case "encrypt":
+		fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+		skipValidation := fs.Bool("skip-validation", false, "Ne vérifie pas la clé publique (dangereux)")
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" || fs.Arg(2) == "" {
+			usage()
+		}
+
+		pubKeyPath, dataPath, cipherPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		data := readBytes(dataPath)
+		pub, err := ReadOpenPGPPublicKey(readBytes(pubKeyPath))
+		checkError(err)
+		if !*skipValidation {
+			checkError(pub.Validate())
+		}
+
+		c, err := OpenPGPEncrypt(rand.Reader, pub, data)
+		checkError(err)
+		writeBytes(pgpArmor(pgpArmorMessage, c), cipherPath)
+	case "decrypt":
+		fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+		fs.Parse(os.Args[3:])
+
+		if fs.Arg(0) == "" || fs.Arg(1) == "" {
+			usage()
+		}
+
+		privateKeyPath, cipherPath, dataPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		cipher := readBytes(cipherPath)
+		priv, err := ReadOpenPGPPrivateKey(readBytes(privateKeyPath))
+		checkError(err)
+
+		d, err := OpenPGPDecrypt(priv, cipher)
+		checkError(err)
+		if dataPath == "" {
+			os.Stdout.Write(d)
+		} else {
+			writeBytes(d, dataPath)
+		}
+	default:
+		usage()
+	}
+}
+
+// Échange de clés Diffie-Hellman entre deux clés ElGamal du même groupe
+func dh() {
+	cmd := os.Args[2]
+	switch cmd {
+	case "derive":
+		fs := flag.NewFlagSet("derive", flag.ExitOnError)
+		keySize := fs.Int("size", 128, "Taille de la clé AES (128, 192 ou 256)")
+		skipValidation := fs.Bool("skip-validation", false, "Ne vérifie pas la clé publique du correspondant (dangereux)")
+		fs.Parse(os.Args[3:])
+
+		if fs.NArg() != 3 {
+			usage()
+		}
+
+		privateKeyPath, peerKeyPath, keyPath := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+		priv, err := loadElgamalPrivateKey(privateKeyPath)
+		checkError(err)
+		peer, err := ParseElgamalPublicKey(readBytes(peerKeyPath))
+		checkError(err)
+		if !*skipValidation {
+			checkError(peer.Validate())
+		}
+
+		key, err := DHDeriveKey(priv, peer, *keySize)
+		checkError(err)
+		writeBytes(key, keyPath)
 	default:
 		usage()
 	}
@@ -183,6 +1277,14 @@ func cli() {
 		aes()
 	case "elgamal":
 		elgamal()
+	case "ecc":
+		ecc()
+	case "rsa":
+		rsa()
+	case "pgp":
+		pgp()
+	case "dh":
+		dh()
 	default:
 		usage()
 		os.Exit(1)
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Variable d'environnement indiquant au binaire de test de se comporter
+// comme la commande gocrypto (voir runCLI)
+const envTestCLI = "GOCRYPTO_TEST_CLI"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envTestCLI) == "1" {
+		cli()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// Exécute gocrypto avec les arguments args dans le dossier dir et renvoie
+// sa sortie. Les variables d'environnement env sont ajoutées.
+func runCLI(t *testing.T, dir string, env []string, args ...string) (string, error) {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), envTestCLI+"=1"), env...)
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestCLIVerifyKey(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, genkey := range [][]string{
+		{"-group=ffdhe2048", "group"},
+		{"-dsa", "-size=1024", "dsa"},
+		{"-size=256", "plain"},
+	} {
+		name := genkey[len(genkey)-1]
+		if out, err := runCLI(t, dir, nil, append([]string{"elgamal", "genkey"}, genkey...)...); err != nil {
+			t.Fatalf("genkey %s : %v\n%s", name, err, out)
+		}
+
+		for _, file := range []string{name, name + ".pub"} {
+			out, err := runCLI(t, dir, nil, "elgamal", "verify-key", file)
+			if err != nil || !strings.Contains(out, "Clé valide") {
+				t.Errorf("verify-key %s : %v\n%s", file, err, out)
+			}
+		}
+
+		// La clé privée est bien vérifiée (h = g^x) : on remplace X
+		priv, err := ParseElgamalPrivateKey(readBytes(filepath.Join(dir, name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv.X.Add(priv.X, big1)
+		writeBytes(priv.GetBytes(), filepath.Join(dir, name+".bad"))
+
+		out, err := runCLI(t, dir, nil, "elgamal", "verify-key", name+".bad")
+		if err == nil || !strings.Contains(out, errKeyPrivateValue.Error()) {
+			t.Errorf("verify-key %s.bad : clé privée incohérente acceptée\n%s", name, out)
+		}
+
+		// L'empreinte est la même pour les deux fichiers
+		fpPriv, err1 := runCLI(t, dir, nil, "elgamal", "fingerprint", name)
+		fpPub, err2 := runCLI(t, dir, nil, "elgamal", "fingerprint", name+".pub")
+		if err1 != nil || err2 != nil || fpPriv != fpPub {
+			t.Errorf("Empreintes différentes pour %s : %q, %q", name, fpPriv, fpPub)
+		}
+	}
+}
+
+func TestCLIProxyRekeyProtectedKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"alice", "bob"} {
+		env := []string{envPassphrase + "=phrase de " + name}
+		if out, err := runCLI(t, dir, env, "elgamal", "genkey", "-protect", "-group=ffdhe2048", name); err != nil {
+			t.Fatalf("genkey %s : %v\n%s", name, err, out)
+		}
+	}
+
+	// Une ligne du descripteur par clé, dans l'ordre des arguments
+	rekey := func(lines string) (string, error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		w.WriteString(lines)
+		w.Close()
+
+		cmd := exec.Command(os.Args[0], "elgamal", "proxy", "rekey", "alice", "bob", "rk")
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), envTestCLI+"=1", envPassphraseFD+"=3")
+		cmd.ExtraFiles = []*os.File{r}
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	if out, err := rekey("phrase de alice\nphrase de bob\n"); err != nil {
+		t.Errorf("rekey avec deux clés protégées : %v\n%s", err, out)
+	}
+	if out, err := rekey("phrase de alice\n"); err == nil {
+		t.Errorf("rekey accepté sans phrase de passe pour la deuxième clé\n%s", out)
+	}
+	if out, err := rekey("phrase de alice\nphrase de alice\n"); err == nil {
+		t.Errorf("rekey accepté avec une mauvaise phrase de passe\n%s", out)
+	}
+
+	// La variable d'environnement ne fournit pas la phrase des deux clés
+	out, err := runCLI(t, dir, []string{envPassphrase + "=phrase de alice"}, "elgamal", "proxy", "rekey", "alice", "bob", "rk")
+	if err == nil || !strings.Contains(out, errPassphraseReused.Error()) {
+		t.Errorf("rekey avec %s : %v\n%s", envPassphrase, err, out)
+	}
+}
+
+func TestCLIThresholdProtectedShare(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, i := range []string{"1", "2"} {
+		if out, err := runCLI(t, dir, nil, "elgamal", "threshold", "deal", "-t=2", "-n=2", "-index="+i, "d"+i); err != nil {
+			t.Fatalf("deal %s : %v\n%s", i, err, out)
+		}
+	}
+	for _, i := range []string{"1", "2"} {
+		env := []string{envPassphrase + "=part " + i}
+		out, err := runCLI(t, dir, env, "elgamal", "threshold", "finish", "-protect", "k"+i,
+			"d1.commitments", "d2.commitments", "d1.share"+i, "d2.share"+i)
+		if err != nil {
+			t.Fatalf("finish %s : %v\n%s", i, err, out)
+		}
+		if !IsProtectedKey(readBytes(filepath.Join(dir, "k"+i))) {
+			t.Errorf("La part de clé k%s n'est pas protégée", i)
+		}
+	}
+
+	writeBytes([]byte("message à seuil"), filepath.Join(dir, "plain"))
+	if out, err := runCLI(t, dir, nil, "elgamal", "encrypt", "k1.pub", "plain", "cipher"); err != nil {
+		t.Fatalf("encrypt : %v\n%s", err, out)
+	}
+
+	if out, err := runCLI(t, dir, []string{envPassphrase + "=mauvaise"}, "elgamal", "threshold", "partial", "k1", "cipher", "p1"); err == nil {
+		t.Errorf("partial accepté avec une mauvaise phrase de passe\n%s", out)
+	}
+	for _, i := range []string{"1", "2"} {
+		env := []string{envPassphrase + "=part " + i}
+		if out, err := runCLI(t, dir, env, "elgamal", "threshold", "partial", "k"+i, "cipher", "p"+i); err != nil {
+			t.Fatalf("partial %s : %v\n%s", i, err, out)
+		}
+	}
+
+	if out, err := runCLI(t, dir, nil, "elgamal", "threshold", "combine", "k1.pub", "cipher", "p1", "p2", "result"); err != nil {
+		t.Fatalf("combine : %v\n%s", err, out)
+	}
+	if b := readBytes(filepath.Join(dir, "result")); string(b) != "message à seuil" {
+		t.Errorf("Message déchiffré incorrect : %q", b)
+	}
+}
+
+func TestCLIGnuPGKeyValidation(t *testing.T) {
+	dir := t.TempDir()
+	writeBytes(readTestdata(t, "gpg-public.asc"), filepath.Join(dir, "gpg.asc"))
+	writeBytes([]byte("message pour GnuPG"), filepath.Join(dir, "plain"))
+
+	// La clé de GnuPG est validée par les deux commandes de chiffrement
+	if out, err := runCLI(t, dir, nil, "pgp", "encrypt", "gpg.asc", "plain", "plain.asc"); err != nil {
+		t.Errorf("pgp encrypt : %v\n%s", err, out)
+	}
+	if out, err := runCLI(t, dir, nil, "pgp", "import", "gpg.asc", "gpg.pub"); err != nil {
+		t.Fatalf("pgp import : %v\n%s", err, out)
+	}
+	if out, err := runCLI(t, dir, nil, "elgamal", "encrypt", "gpg.pub", "plain", "plain.enc"); err != nil {
+		t.Errorf("elgamal encrypt : %v\n%s", err, out)
+	}
+
+	// Une clé OpenPGP invalide est refusée par pgp encrypt
+	pub, err := ReadOpenPGPPublicKey(readTestdata(t, "gpg-public.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub.H.SetInt64(1)
+	writeBytes(pub.Export(), filepath.Join(dir, "bad.asc"))
+
+	out, err := runCLI(t, dir, nil, "pgp", "encrypt", "bad.asc", "plain", "bad.enc")
+	if err == nil || !strings.Contains(out, errKeyPublicValue.Error()) {
+		t.Errorf("pgp encrypt : clé invalide acceptée\n%s", out)
+	}
+}
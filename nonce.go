@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"io"
+	"math/big"
+)
+
+// Taille en octets de l'aléa ajouté à la graine des nonces hedged
+const nonceEntropySize = 32
+
+// Générateur des nonces de signature (RFC 6979, section 3.2) : HMAC-DRBG
+// initialisé avec la clé privée x et l'empreinte du message, de sorte que
+// le nonce ne dépende pas de la qualité de la source d'aléa et qu'il soit
+// différent pour chaque message. Des candidats successifs sont produits
+// tant que l'appelant les refuse.
+type nonceGenerator struct {
+	h    crypto.Hash
+	q    *big.Int
+	qLen int
+
+	k, v    []byte
+	started bool
+}
+
+// Renvoie les qLen bits de poids fort de b (bits2int de la RFC 6979)
+func bits2int(b []byte, qLen int) *big.Int {
+	z := new(big.Int).SetBytes(b)
+	if excess := len(b)*8 - qLen; excess > 0 {
+		z.Rsh(z, uint(excess))
+	}
+	return z
+}
+
+// Calcule HMAC_K(données...)
+func (g *nonceGenerator) mac(data ...[]byte) []byte {
+	m := hmac.New(g.h.New, g.k)
+	for _, d := range data {
+		m.Write(d)
+	}
+	return m.Sum(nil)
+}
+
+// Initialise le générateur des nonces dans [1, q-1] pour la clé x et
+// l'empreinte digest (calculée avec h). Si rand n'est pas nil, des octets
+// aléatoires sont ajoutés à la graine (RFC 6979, section 3.6) : les
+// nonces restent sûrs même si rand est défaillant, mais les signatures ne
+// sont plus reproductibles.
+func newNonceGenerator(rand io.Reader, h crypto.Hash, q, x *big.Int, digest []byte) *nonceGenerator {
+	g := &nonceGenerator{h: h, q: q, qLen: q.BitLen()}
+	rLen := (g.qLen + 7) / 8
+
+	// int2octets(x) | bits2octets(digest) [ | aléa ]
+	seed := new(big.Int).Mod(x, q).FillBytes(make([]byte, rLen))
+	z := bits2int(digest, g.qLen)
+	if z.Cmp(q) >= 0 {
+		z.Sub(z, q)
+	}
+	seed = append(seed, z.FillBytes(make([]byte, rLen))...)
+	if rand != nil {
+		seed = append(seed, readRandom(rand, nonceEntropySize)...)
+	}
+
+	size := h.Size()
+	g.k = make([]byte, size)
+	g.v = make([]byte, size)
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+
+	g.k = g.mac(g.v, []byte{0x00}, seed)
+	g.v = g.mac(g.v)
+	g.k = g.mac(g.v, []byte{0x01}, seed)
+	g.v = g.mac(g.v)
+	return g
+}
+
+// Renvoie le nonce suivant, dans [1, q-1]
+func (g *nonceGenerator) next() *big.Int {
+	for {
+		// Le candidat précédent a été refusé : on fait évoluer l'état
+		if g.started {
+			g.k = g.mac(g.v, []byte{0x00})
+			g.v = g.mac(g.v)
+		}
+		g.started = true
+
+		var t []byte
+		for len(t)*8 < g.qLen {
+			g.v = g.mac(g.v)
+			t = append(t, g.v...)
+		}
+
+		if k := bits2int(t, g.qLen); k.Sign() > 0 && k.Cmp(g.q) < 0 {
+			return k
+		}
+	}
+}
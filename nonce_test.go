@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"testing"
+)
+
+func TestDeterministicSignature(t *testing.T) {
+	data := randomBytes(1000)
+
+	for _, test := range []struct {
+		scheme string
+		priv   *ElgamalPrivateKey
+	}{
+		{schemeElgamal, keys},
+		{schemeDSA, dsaVectorKey()},
+		{schemeSchnorr, keys},
+	} {
+		pub := &test.priv.ElgamalPublicKey
+
+		a, err := ElgamalSignDetached(nil, test.priv, data, crypto.SHA256, test.scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ElgamalSignDetached(nil, test.priv, data, crypto.SHA256, test.scheme)
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s : la signature déterministe n'est pas reproductible", test.scheme)
+		}
+		if !ElgamalCheckDetached(pub, data, a) {
+			t.Errorf("%s : échec de la vérification de la signature déterministe", test.scheme)
+		}
+
+		other, _ := ElgamalSignDetached(nil, test.priv, []byte("autre document"), crypto.SHA256, test.scheme)
+		if bytes.Equal(deserialize(a)[3], deserialize(other)[3]) {
+			t.Errorf("%s : même nonce pour deux documents différents", test.scheme)
+		}
+
+		// Signature hedged : valide mais non reproductible
+		c, _ := ElgamalSignDetached(rand.Reader, test.priv, data, crypto.SHA256, test.scheme)
+		if bytes.Equal(a, c) {
+			t.Errorf("%s : l'aléa n'est pas pris en compte", test.scheme)
+		}
+		if !ElgamalCheckDetached(pub, data, c) {
+			t.Errorf("%s : échec de la vérification de la signature hedged", test.scheme)
+		}
+	}
+}
+
+func TestDeterministicSchnorrStream(t *testing.T) {
+	data := []byte("document lu une seule fois")
+
+	// io.MultiReader masque le Seek du bytes.Reader : le message n'est
+	// de toute façon lu qu'une fois
+	stream := io.MultiReader(bytes.NewReader(data))
+	signature, err := ElgamalSignReader(nil, keys, stream, crypto.SHA256, schemeSchnorr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ElgamalCheckDetached(&keys.ElgamalPublicKey, data, signature) {
+		t.Error("Echec de la vérification de la signature d'un flux")
+	}
+
+	again, _ := ElgamalSignDetached(nil, keys, data, crypto.SHA256, schemeSchnorr)
+	if !bytes.Equal(signature, again) {
+		t.Error("La signature déterministe d'un flux n'est pas reproductible")
+	}
+}
+
+// Les nonces ECDSA de crypto/ecdsa sont déterministes (RFC 6979) lorsque
+// l'aléa est nil : le premier nonce du générateur doit donner le même r
+func TestNonceGeneratorECDSA(t *testing.T) {
+	curve := elliptic.P256()
+	n := curve.Params().N
+
+	priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []string{"sample", "test"} {
+		digest := hash(crypto.SHA256, []byte(msg))
+
+		der, err := priv.Sign(nil, digest, crypto.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(der, &sig); err != nil {
+			t.Fatal(err)
+		}
+
+		k := newNonceGenerator(nil, crypto.SHA256, n, priv.D, digest).next()
+		r, _ := curve.ScalarBaseMult(k.Bytes())
+		if r.Mod(r, n).Cmp(sig.R) != 0 {
+			t.Errorf("Nonce différent de celui de crypto/ecdsa pour %q", msg)
+		}
+	}
+}
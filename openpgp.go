@@ -0,0 +1,778 @@
+package main
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/zlib"
+	stdaes "crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Types de paquets OpenPGP (RFC 4880, 4.3)
+const (
+	pgpTagPKESK        = 1
+	pgpTagSecretKey    = 5
+	pgpTagPublicKey    = 6
+	pgpTagSecretSubkey = 7
+	pgpTagCompressed   = 8
+	pgpTagSED          = 9
+	pgpTagLiteral      = 11
+	pgpTagPublicSubkey = 14
+	pgpTagSEIPD        = 18
+	pgpTagMDC          = 19
+)
+
+// Algorithmes OpenPGP (RFC 4880, 9.1 à 9.3)
+const (
+	pgpAlgoElgamal = 16
+
+	pgpCipherAES128 = 7
+	pgpCipherAES192 = 8
+	pgpCipherAES256 = 9
+
+	pgpCompressionNone  = 0
+	pgpCompressionZIP   = 1
+	pgpCompressionZLIB  = 2
+	pgpCompressionBZIP2 = 3
+)
+
+// Types des blocs d'armure ASCII
+const (
+	pgpArmorMessage    = "PGP MESSAGE"
+	pgpArmorPublicKey  = "PGP PUBLIC KEY BLOCK"
+	pgpArmorPrivateKey = "PGP PRIVATE KEY BLOCK"
+)
+
+// Profondeur maximale des paquets compressés imbriqués
+const pgpMaxNesting = 4
+
+// Taille maximale du contenu d'un paquet compressé, une fois décompressé :
+// quelques octets compressés peuvent sinon occuper toute la mémoire
+const pgpMaxDecompressedSize = 256 << 20
+
+var (
+	errPGPPacket       = errors.New("gocrypto: paquet OpenPGP invalide")
+	errPGPArmor        = errors.New("gocrypto: armure OpenPGP invalide")
+	errPGPNoKey        = errors.New("gocrypto: aucune clé ElGamal dans les paquets OpenPGP")
+	errPGPProtectedKey = errors.New("gocrypto: clé secrète OpenPGP protégée par une phrase de passe")
+	errPGPNoRecipient  = errors.New("gocrypto: le message n'est pas chiffré pour cette clé")
+	errPGPUnsupported  = errors.New("gocrypto: fonctionnalité OpenPGP non supportée")
+	errPGPNoMDC        = errors.New("gocrypto: message OpenPGP sans protection d'intégrité (MDC)")
+	errPGPTooLarge     = errors.New("gocrypto: paquet OpenPGP compressé trop volumineux")
+)
+
+// OpenPGPPublicKey représente une clé publique ElGamal OpenPGP (algorithme
+// 16). La date de création fait partie de l'empreinte de la clé.
+type OpenPGPPublicKey struct {
+	ElgamalPublicKey
+	Created time.Time
+}
+
+// OpenPGPPrivateKey représente une clé secrète ElGamal OpenPGP
+type OpenPGPPrivateKey struct {
+	ElgamalPrivateKey
+	Created time.Time
+}
+
+// Public renvoie la clé publique associée
+func (priv *OpenPGPPrivateKey) Public() *OpenPGPPublicKey {
+	return &OpenPGPPublicKey{ElgamalPublicKey: priv.ElgamalPublicKey, Created: priv.Created}
+}
+
+// Un paquet OpenPGP : son type et son contenu
+type pgpPacket struct {
+	tag  byte
+	body []byte
+}
+
+// Lit la longueur d'un paquet au nouveau format (RFC 4880, 4.2.2). partial
+// est vrai si le paquet se poursuit après ce fragment.
+func readPGPNewLength(b []byte) (length int, partial bool, rest []byte, err error) {
+	switch {
+	case len(b) < 1:
+		return 0, false, nil, errPGPPacket
+	case b[0] < 192:
+		return int(b[0]), false, b[1:], nil
+	case b[0] < 224:
+		if len(b) < 2 {
+			return 0, false, nil, errPGPPacket
+		}
+		return (int(b[0])-192)<<8 + int(b[1]) + 192, false, b[2:], nil
+	case b[0] < 255:
+		return 1 << (b[0] & 0x1f), true, b[1:], nil
+	default:
+		if len(b) < 5 {
+			return 0, false, nil, errPGPPacket
+		}
+		return int(binary.BigEndian.Uint32(b[1:5])), false, b[5:], nil
+	}
+}
+
+// Lit le premier paquet de b, au nouveau ou à l'ancien format
+func readPGPPacket(b []byte) (p pgpPacket, rest []byte, err error) {
+	if len(b) < 1 || b[0]&0x80 == 0 {
+		return p, nil, errPGPPacket
+	}
+	ctb, b := b[0], b[1:]
+
+	// Ancien format : type sur 4 bits, taille de la longueur sur 2 bits
+	if ctb&0x40 == 0 {
+		p.tag = (ctb >> 2) & 0x0f
+
+		var length int
+		switch ctb & 3 {
+		case 0:
+			if len(b) < 1 {
+				return p, nil, errPGPPacket
+			}
+			length, b = int(b[0]), b[1:]
+		case 1:
+			if len(b) < 2 {
+				return p, nil, errPGPPacket
+			}
+			length, b = int(binary.BigEndian.Uint16(b)), b[2:]
+		case 2:
+			if len(b) < 4 {
+				return p, nil, errPGPPacket
+			}
+			length, b = int(binary.BigEndian.Uint32(b)), b[4:]
+		default:
+			// Longueur indéterminée : le paquet s'étend jusqu'à la fin
+			length = len(b)
+		}
+
+		if length < 0 || length > len(b) {
+			return p, nil, errPGPPacket
+		}
+		return pgpPacket{p.tag, b[:length]}, b[length:], nil
+	}
+
+	// Nouveau format : le contenu peut être découpé en fragments
+	p.tag = ctb & 0x3f
+	for {
+		length, partial, r, err := readPGPNewLength(b)
+		if err != nil {
+			return p, nil, err
+		}
+		if length < 0 || length > len(r) {
+			return p, nil, errPGPPacket
+		}
+		p.body = append(p.body, r[:length]...)
+		b = r[length:]
+
+		if !partial {
+			return p, b, nil
+		}
+	}
+}
+
+// Découpe b en une suite de paquets
+func readPGPPackets(b []byte) ([]pgpPacket, error) {
+	var packets []pgpPacket
+	for len(b) > 0 {
+		p, rest, err := readPGPPacket(b)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, p)
+		b = rest
+	}
+	return packets, nil
+}
+
+// Encode un paquet au nouveau format avec une longueur définie
+func writePGPPacket(tag byte, body []byte) []byte {
+	out := []byte{0xc0 | tag}
+
+	switch n := len(body); {
+	case n < 192:
+		out = append(out, byte(n))
+	case n < 8384:
+		n -= 192
+		out = append(out, byte(n>>8)+192, byte(n))
+	default:
+		out = append(out, 255)
+		out = binary.BigEndian.AppendUint32(out, uint32(n))
+	}
+
+	return append(out, body...)
+}
+
+// Lit un entier multiprécision : longueur en bits sur 2 octets puis
+// valeur en big-endian (RFC 4880, 3.2)
+func readMPI(b []byte) (n *big.Int, rest []byte, err error) {
+	if len(b) < 2 {
+		return nil, nil, errPGPPacket
+	}
+	length := (int(binary.BigEndian.Uint16(b)) + 7) / 8
+	if len(b) < 2+length {
+		return nil, nil, errPGPPacket
+	}
+	return new(big.Int).SetBytes(b[2 : 2+length]), b[2+length:], nil
+}
+
+// Encode un entier multiprécision
+func writeMPI(n *big.Int) []byte {
+	out := binary.BigEndian.AppendUint16(nil, uint16(n.BitLen()))
+	return append(out, n.Bytes()...)
+}
+
+// Somme de contrôle sur 16 bits utilisée pour les clés secrètes et les
+// clés de session
+func pgpChecksum(b []byte) uint16 {
+	var sum uint16
+	for _, c := range b {
+		sum += uint16(c)
+	}
+	return sum
+}
+
+// Calcule le CRC-24 de l'armure ASCII (RFC 4880, 6.1)
+func crc24(b []byte) uint32 {
+	crc := uint32(0xb704ce)
+	for _, c := range b {
+		crc ^= uint32(c) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864cfb
+			}
+		}
+	}
+	return crc & 0xffffff
+}
+
+// Encapsule data dans une armure ASCII de type blockType
+func pgpArmor(blockType string, data []byte) []byte {
+	var sb strings.Builder
+	sb.WriteString("-----BEGIN " + blockType + "-----\n\n")
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 64 {
+		sb.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	if encoded != "" {
+		sb.WriteString(encoded + "\n")
+	}
+
+	crc := crc24(data)
+	sb.WriteString("=" + base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)}) + "\n")
+	sb.WriteString("-----END " + blockType + "-----\n")
+
+	return []byte(sb.String())
+}
+
+// Retire l'armure ASCII de b si elle est présente. Les données binaires
+// sont renvoyées telles quelles.
+func pgpDearmor(b []byte) ([]byte, error) {
+	text := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(text, "-----BEGIN PGP ") {
+		return b, nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	// Les en-têtes (Version, Comment...) se terminent par une ligne vide
+	i := 1
+	for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+		i++
+	}
+
+	var body strings.Builder
+	var checksum string
+	for i++; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case strings.HasPrefix(line, "-----END PGP "):
+			data, err := base64.StdEncoding.DecodeString(body.String())
+			if err != nil {
+				return nil, errPGPArmor
+			}
+
+			// La somme de contrôle est facultative
+			if checksum != "" {
+				crc, err := base64.StdEncoding.DecodeString(checksum)
+				if err != nil || len(crc) != 3 || uint32(crc[0])<<16|uint32(crc[1])<<8|uint32(crc[2]) != crc24(data) {
+					return nil, errPGPArmor
+				}
+			}
+			return data, nil
+		case strings.HasPrefix(line, "="):
+			checksum = line[1:]
+		default:
+			body.WriteString(line)
+		}
+	}
+
+	return nil, errPGPArmor
+}
+
+// Contenu d'un paquet de clé publique v4 : version | date | algorithme |
+// p | g | y
+func (pub *OpenPGPPublicKey) packetBody() []byte {
+	p := new(big.Int).Add(pub.Q, big1)
+
+	out := []byte{4}
+	out = binary.BigEndian.AppendUint32(out, uint32(pub.Created.Unix()))
+	out = append(out, pgpAlgoElgamal)
+	out = append(out, writeMPI(p)...)
+	out = append(out, writeMPI(pub.G)...)
+	return append(out, writeMPI(pub.H)...)
+}
+
+// Fingerprint renvoie l'empreinte v4 de la clé : SHA-1 du paquet de clé
+// publique (RFC 4880, 12.2)
+func (pub *OpenPGPPublicKey) Fingerprint() []byte {
+	body := pub.packetBody()
+
+	h := sha1.New()
+	h.Write([]byte{0x99, byte(len(body) >> 8), byte(len(body))})
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// KeyID renvoie l'identifiant de la clé : les 64 bits de poids faible de
+// son empreinte
+func (pub *OpenPGPPublicKey) KeyID() uint64 {
+	fp := pub.Fingerprint()
+	return binary.BigEndian.Uint64(fp[len(fp)-8:])
+}
+
+// Lit la partie publique d'un paquet de clé v4. Renvoie errPGPNoKey si la
+// clé n'est pas une clé ElGamal.
+func parsePGPPublicKey(body []byte) (pub *OpenPGPPublicKey, rest []byte, err error) {
+	if len(body) < 6 || body[0] != 4 {
+		return nil, nil, errPGPUnsupported
+	}
+	if body[5] != pgpAlgoElgamal {
+		return nil, nil, errPGPNoKey
+	}
+
+	pub = &OpenPGPPublicKey{Created: time.Unix(int64(binary.BigEndian.Uint32(body[1:5])), 0).UTC()}
+
+	var p *big.Int
+	rest = body[6:]
+	for _, n := range []**big.Int{&p, &pub.G, &pub.H} {
+		if *n, rest, err = readMPI(rest); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if p.Cmp(big2) <= 0 {
+		return nil, nil, errPGPPacket
+	}
+	pub.Q = new(big.Int).Sub(p, big1)
+	pub.Group = recogniseGroup(&pub.ElgamalPublicKey, "")
+	return pub, rest, nil
+}
+
+// Lit un paquet de clé secrète v4 non protégée
+func parsePGPPrivateKey(body []byte) (*OpenPGPPrivateKey, error) {
+	pub, rest, err := parsePGPPublicKey(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Octet d'usage S2K : 0 si la clé n'est pas chiffrée
+	if len(rest) < 1 {
+		return nil, errPGPPacket
+	}
+	if rest[0] != 0 {
+		return nil, errPGPProtectedKey
+	}
+	rest = rest[1:]
+
+	x, tail, err := readMPI(rest)
+	if err != nil || len(tail) != 2 {
+		return nil, errPGPPacket
+	}
+	if pgpChecksum(rest[:len(rest)-2]) != binary.BigEndian.Uint16(tail) {
+		return nil, errPGPPacket
+	}
+
+	return &OpenPGPPrivateKey{
+		ElgamalPrivateKey: ElgamalPrivateKey{ElgamalPublicKey: pub.ElgamalPublicKey, X: x},
+		Created:           pub.Created,
+	}, nil
+}
+
+// ReadOpenPGPPublicKey lit la première clé ElGamal (clé principale ou
+// sous-clé) d'un bloc de clés OpenPGP, avec ou sans armure ASCII, tel que
+// produit par gpg --export. Les signatures de certification ne sont pas
+// vérifiées.
+func ReadOpenPGPPublicKey(b []byte) (*OpenPGPPublicKey, error) {
+	data, err := pgpDearmor(b)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := readPGPPackets(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range packets {
+		switch p.tag {
+		case pgpTagPublicKey, pgpTagPublicSubkey, pgpTagSecretKey, pgpTagSecretSubkey:
+			pub, _, err := parsePGPPublicKey(p.body)
+			if err == errPGPNoKey || err == errPGPUnsupported {
+				continue
+			}
+			return pub, err
+		}
+	}
+	return nil, errPGPNoKey
+}
+
+// ReadOpenPGPPrivateKey lit la première clé secrète ElGamal d'un bloc de
+// clés OpenPGP, tel que produit par gpg --export-secret-keys. La clé ne
+// doit pas être protégée par une phrase de passe.
+func ReadOpenPGPPrivateKey(b []byte) (*OpenPGPPrivateKey, error) {
+	data, err := pgpDearmor(b)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := readPGPPackets(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range packets {
+		if p.tag != pgpTagSecretKey && p.tag != pgpTagSecretSubkey {
+			continue
+		}
+		priv, err := parsePGPPrivateKey(p.body)
+		if err == errPGPNoKey || err == errPGPUnsupported {
+			continue
+		}
+		return priv, err
+	}
+	return nil, errPGPNoKey
+}
+
+// Export renvoie la clé sous la forme d'un paquet de clé publique OpenPGP
+// avec armure ASCII. ElGamal ne permettant pas de signer, le paquet n'est
+// accompagné d'aucun identifiant certifié : GnuPG ne l'importe pas seul.
+func (pub *OpenPGPPublicKey) Export() []byte {
+	return pgpArmor(pgpArmorPublicKey, writePGPPacket(pgpTagPublicKey, pub.packetBody()))
+}
+
+// Export renvoie la clé sous la forme d'un paquet de clé secrète OpenPGP
+// non protégé avec armure ASCII
+func (priv *OpenPGPPrivateKey) Export() []byte {
+	secret := writeMPI(priv.X)
+	checksum := pgpChecksum(secret)
+
+	body := append(priv.Public().packetBody(), 0)
+	body = append(body, secret...)
+	body = binary.BigEndian.AppendUint16(body, checksum)
+
+	return pgpArmor(pgpArmorPrivateKey, writePGPPacket(pgpTagSecretKey, body))
+}
+
+// Renvoie la taille de clé associée à un algorithme de chiffrement
+// symétrique OpenPGP
+func pgpCipherKeySize(algo byte) (int, error) {
+	switch algo {
+	case pgpCipherAES128:
+		return 16, nil
+	case pgpCipherAES192:
+		return 24, nil
+	case pgpCipherAES256:
+		return 32, nil
+	default:
+		return 0, errPGPUnsupported
+	}
+}
+
+// Chiffre (ou déchiffre) b en place en mode CFB OpenPGP avec un IV nul,
+// sans resynchronisation, comme l'exigent les paquets SEIPD (RFC 4880, 5.13)
+func pgpCFB(key, b []byte, decrypt bool) {
+	block, err := stdaes.NewCipher(key)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
+	}
+
+	iv := make([]byte, block.BlockSize())
+	if decrypt {
+		cipher.NewCFBDecrypter(block, iv).XORKeyStream(b, b)
+	} else {
+		cipher.NewCFBEncrypter(block, iv).XORKeyStream(b, b)
+	}
+}
+
+// Calcule le paquet MDC : SHA-1 des données en clair suivies de l'en-tête
+// du paquet MDC lui-même
+func pgpMDC(plaintext []byte) []byte {
+	h := sha1.New()
+	h.Write(plaintext)
+	h.Write([]byte{0xd3, 0x14})
+	return append([]byte{0xd3, 0x14}, h.Sum(nil)...)
+}
+
+// OpenPGPEncrypt chiffre plaintext pour pub au format OpenPGP : un paquet
+// PKESK contenant la clé de session AES-256 chiffrée avec ElGamal
+// (EME-PKCS1-v1_5), suivi d'un paquet SEIPD contenant les données
+// littérales. Le message produit est lisible par gpg --decrypt.
+func OpenPGPEncrypt(rand io.Reader, pub *OpenPGPPublicKey, plaintext []byte) ([]byte, error) {
+	p := new(big.Int).Add(pub.Q, big1)
+	k := (p.BitLen() + 7) / 8
+
+	// Clé de session : algorithme | clé | somme de contrôle
+	sessionKey := readRandom(rand, 32)
+	m := append([]byte{pgpCipherAES256}, sessionKey...)
+	m = binary.BigEndian.AppendUint16(m, pgpChecksum(sessionKey))
+
+	// Bourrage EME-PKCS1-v1_5 : 00 | 02 | PS | 00 | m, PS non nul
+	if len(m) > k-11 {
+		return nil, errPGPUnsupported
+	}
+	em := make([]byte, k)
+	em[1] = 2
+	ps := em[2 : k-len(m)-1]
+	for i := range ps {
+		for ps[i] == 0 {
+			ps[i] = readRandom(rand, 1)[0]
+		}
+	}
+	copy(em[k-len(m):], m)
+
+	// c1 = g^r, c2 = M * y^r  (mod p)
+	r := randRange(rand, big1, new(big.Int).Sub(pub.Q, big1))
+	c1 := new(big.Int).Exp(pub.G, r, p)
+	c2 := new(big.Int).Exp(pub.H, r, p)
+	c2.Mul(c2, new(big.Int).SetBytes(em))
+	c2.Mod(c2, p)
+
+	pkesk := []byte{3}
+	pkesk = binary.BigEndian.AppendUint64(pkesk, pub.KeyID())
+	pkesk = append(pkesk, pgpAlgoElgamal)
+	pkesk = append(pkesk, writeMPI(c1)...)
+	pkesk = append(pkesk, writeMPI(c2)...)
+
+	// Données littérales binaires, sans nom de fichier ni date
+	literal := writePGPPacket(pgpTagLiteral, append([]byte{'b', 0, 0, 0, 0, 0}, plaintext...))
+
+	// Préfixe aléatoire d'un bloc dont les deux derniers octets sont
+	// répétés, données puis MDC
+	prefix := readRandom(rand, stdaes.BlockSize)
+	prefix = append(prefix, prefix[len(prefix)-2:]...)
+	data := append(prefix, literal...)
+	data = append(data, pgpMDC(data)...)
+	pgpCFB(sessionKey, data, false)
+
+	out := writePGPPacket(pgpTagPKESK, pkesk)
+	return append(out, writePGPPacket(pgpTagSEIPD, append([]byte{1}, data...))...), nil
+}
+
+// Déchiffre la clé de session d'un paquet PKESK
+func pgpDecryptSessionKey(priv *OpenPGPPrivateKey, body []byte) (algo byte, key []byte, err error) {
+	c1, rest, err := readMPI(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	c2, _, err := readMPI(rest)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	p := new(big.Int).Add(priv.Q, big1)
+	if c1.Sign() <= 0 || c1.Cmp(p) >= 0 || c2.Sign() <= 0 || c2.Cmp(p) >= 0 {
+		return 0, nil, errInvalidCiphertext
+	}
+
+	// M = c2 * (c1^x)^-1  (mod p)
+	s := new(big.Int).Exp(c1, priv.X, p)
+	if s.ModInverse(s, p) == nil {
+		return 0, nil, errInvalidCiphertext
+	}
+	s.Mul(s, c2)
+	s.Mod(s, p)
+	em := fixedBytes(s, (p.BitLen()+7)/8)
+
+	// Retrait du bourrage : 00 | 02 | PS (au moins 8 octets) | 00 | m
+	if em[0] != 0 || em[1] != 2 {
+		return 0, nil, errDecryption
+	}
+	sep := bytes.IndexByte(em[2:], 0)
+	if sep < 8 {
+		return 0, nil, errDecryption
+	}
+	m := em[2+sep+1:]
+
+	if len(m) < 3 {
+		return 0, nil, errDecryption
+	}
+	algo, key = m[0], m[1:len(m)-2]
+	size, err := pgpCipherKeySize(algo)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(key) != size || pgpChecksum(key) != binary.BigEndian.Uint16(m[len(m)-2:]) {
+		return 0, nil, errDecryption
+	}
+	return algo, key, nil
+}
+
+// Renvoie le contenu des données littérales contenues dans packets, en
+// décompressant les paquets compressés
+func pgpLiteralData(packets []pgpPacket, depth int) ([]byte, error) {
+	for _, p := range packets {
+		switch p.tag {
+		case pgpTagLiteral:
+			// format | longueur du nom | nom | date | données
+			if len(p.body) < 2 || len(p.body) < 6+int(p.body[1]) {
+				return nil, errPGPPacket
+			}
+			return p.body[6+int(p.body[1]):], nil
+		case pgpTagCompressed:
+			if depth >= pgpMaxNesting || len(p.body) < 1 {
+				return nil, errPGPPacket
+			}
+			data, err := pgpDecompress(p.body[0], p.body[1:], pgpMaxDecompressedSize)
+			if err != nil {
+				return nil, err
+			}
+			inner, err := readPGPPackets(data)
+			if err != nil {
+				return nil, err
+			}
+			return pgpLiteralData(inner, depth+1)
+		}
+	}
+	return nil, errPGPPacket
+}
+
+// Décompresse le contenu d'un paquet compressé (RFC 4880, 5.6). Renvoie
+// errPGPTooLarge si le résultat dépasse limit octets.
+func pgpDecompress(algo byte, data []byte, limit int) ([]byte, error) {
+	var r io.Reader
+	switch algo {
+	case pgpCompressionNone:
+		return data, nil
+	case pgpCompressionZIP:
+		r = flate.NewReader(bytes.NewReader(data))
+	case pgpCompressionZLIB:
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errPGPPacket
+		}
+		r = zr
+	case pgpCompressionBZIP2:
+		r = bzip2.NewReader(bytes.NewReader(data))
+	default:
+		return nil, errPGPUnsupported
+	}
+
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, errPGPPacket
+	}
+	if len(out) > limit {
+		return nil, errPGPTooLarge
+	}
+	return out, nil
+}
+
+// OpenPGPDecrypt déchiffre un message OpenPGP (avec ou sans armure ASCII)
+// chiffré pour priv, par exemple avec gpg --encrypt. Seuls les messages
+// protégés par un MDC (paquet SEIPD) et chiffrés avec AES sont acceptés.
+// Chaque paquet PKESK pouvant être destiné à priv (même identifiant, ou
+// destinataire anonyme) est essayé jusqu'à déchiffrer le message.
+func OpenPGPDecrypt(priv *OpenPGPPrivateKey, message []byte) ([]byte, error) {
+	data, err := pgpDearmor(message)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := readPGPPackets(data)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := priv.Public().KeyID()
+
+	// Clés de session obtenues, et dernière erreur rencontrée en
+	// déchiffrant un paquet PKESK ou SEIPD
+	var (
+		sessionKeys [][]byte
+		lastErr     = errPGPNoRecipient
+	)
+	for _, p := range packets {
+		switch p.tag {
+		case pgpTagPKESK:
+			// version | identifiant de la clé | algorithme | MPI
+			if len(p.body) < 10 || p.body[0] != 3 || p.body[9] != pgpAlgoElgamal {
+				continue
+			}
+			// L'identifiant nul désigne un destinataire anonyme
+			if id := binary.BigEndian.Uint64(p.body[1:9]); id != keyID && id != 0 {
+				continue
+			}
+			// La taille de la clé correspond à l'algorithme indiqué
+			_, key, err := pgpDecryptSessionKey(priv, p.body[10:])
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			sessionKeys = append(sessionKeys, key)
+		case pgpTagSED:
+			return nil, errPGPNoMDC
+		case pgpTagSEIPD:
+			if len(sessionKeys) == 0 {
+				return nil, lastErr
+			}
+			if len(p.body) < 1 || p.body[0] != 1 {
+				return nil, errPGPUnsupported
+			}
+
+			// Une clé de session fausse est détectée par le MDC
+			for _, key := range sessionKeys {
+				plaintext, err := pgpDecryptSEIPD(key, p.body[1:])
+				if err != errDecryption {
+					return plaintext, err
+				}
+				lastErr = err
+			}
+			return nil, lastErr
+		}
+	}
+
+	if len(sessionKeys) == 0 {
+		return nil, lastErr
+	}
+	return nil, errPGPPacket
+}
+
+// Déchiffre le contenu d'un paquet SEIPD et vérifie son MDC
+func pgpDecryptSEIPD(key, ciphertext []byte) ([]byte, error) {
+	const prefixLen = stdaes.BlockSize + 2
+	const mdcLen = 2 + sha1.Size
+
+	if len(ciphertext) < prefixLen+mdcLen {
+		return nil, errInvalidCiphertext
+	}
+
+	data := append([]byte{}, ciphertext...)
+	pgpCFB(key, data, true)
+
+	body, mdc := data[:len(data)-mdcLen], data[len(data)-mdcLen:]
+	if subtle.ConstantTimeCompare(mdc, pgpMDC(body)) != 1 {
+		return nil, errDecryption
+	}
+
+	packets, err := readPGPPackets(body[prefixLen:])
+	if err != nil {
+		return nil, err
+	}
+	return pgpLiteralData(packets, 0)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Les fichiers de testdata ont été produits avec GnuPG 2.2 : une clé DSA
+// 2048 bits avec une sous-clé ElGamal 2048 bits, exportée sans phrase de
+// passe, et des messages chiffrés pour cette sous-clé avec
+// gpg --armor --encrypt (compression ZLIB par défaut, --compress-algo none,
+// et --compress-algo zip depuis l'entrée standard pour obtenir des
+// longueurs partielles).
+
+// Identifiant de la sous-clé ElGamal affiché par gpg
+const gpgKeyID = 0xCAE709C52B630403
+
+const gpgMessage = "Bonjour depuis GnuPG !\n"
+
+func readTestdata(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Renvoie le contenu produit par seq 1 5000
+func seqOutput() []byte {
+	var b bytes.Buffer
+	for i := 1; i <= 5000; i++ {
+		fmt.Fprintln(&b, i)
+	}
+	return b.Bytes()
+}
+
+func TestOpenPGPReadGnuPGKeys(t *testing.T) {
+	pub, err := ReadOpenPGPPublicKey(readTestdata(t, "gpg-public.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.KeyID() != gpgKeyID {
+		t.Errorf("Identifiant de clé %X, attendu %X", pub.KeyID(), uint64(gpgKeyID))
+	}
+
+	priv, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-secret.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if priv.Public().KeyID() != gpgKeyID || priv.H.Cmp(pub.H) != 0 {
+		t.Error("La clé secrète ne correspond pas à la clé publique")
+	}
+
+	// y = g^x  (mod p)
+	p := new(big.Int).Add(priv.Q, big1)
+	if new(big.Int).Exp(priv.G, priv.X, p).Cmp(priv.H) != 0 {
+		t.Error("La clé secrète importée est incohérente")
+	}
+
+	if _, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-public.asc")); err != errPGPNoKey {
+		t.Error("Une clé secrète a été lue dans un bloc de clés publiques")
+	}
+}
+
+func TestOpenPGPDecryptGnuPGMessages(t *testing.T) {
+	priv, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-secret.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := map[string][]byte{
+		"gpg-message-zlib.asc":         []byte(gpgMessage),
+		"gpg-message-uncompressed.asc": []byte(gpgMessage),
+		"gpg-message-zip-partial.asc":  seqOutput(),
+	}
+	for name, expected := range messages {
+		m, err := OpenPGPDecrypt(priv, readTestdata(t, name))
+		if err != nil {
+			t.Error(name, err)
+			continue
+		}
+		if !bytes.Equal(m, expected) {
+			t.Error("Message déchiffré incorrect", name)
+		}
+	}
+}
+
+func TestOpenPGPEncryption(t *testing.T) {
+	priv, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-secret.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range [][]byte{nil, []byte(gpgMessage), randomBytes(20000)} {
+		c, err := OpenPGPEncrypt(rand.Reader, priv.Public(), m)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Avec et sans armure ASCII
+		for _, msg := range [][]byte{c, pgpArmor(pgpArmorMessage, c)} {
+			d, err := OpenPGPDecrypt(priv, msg)
+			if err != nil || !bytes.Equal(d, m) {
+				t.Error("Echec du chiffrement OpenPGP", len(m), err)
+			}
+		}
+	}
+}
+
+func TestOpenPGPTampering(t *testing.T) {
+	priv, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-secret.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := OpenPGPEncrypt(rand.Reader, priv.Public(), []byte(gpgMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Modification du dernier octet (MDC) puis d'un octet des données
+	for _, i := range []int{len(c) - 1, len(c) - 30} {
+		tampered := append([]byte{}, c...)
+		tampered[i] ^= 1
+		if _, err := OpenPGPDecrypt(priv, tampered); err == nil {
+			t.Error("Un message modifié a été accepté", i)
+		}
+	}
+
+	// Une autre clé ne peut pas déchiffrer le message
+	other := &OpenPGPPrivateKey{ElgamalPrivateKey: *keys, Created: priv.Created}
+	if _, err := OpenPGPDecrypt(other, c); err != errPGPNoRecipient {
+		t.Error("Le message a été accepté par une autre clé", err)
+	}
+
+	// L'armure doit avoir une somme de contrôle correcte
+	armored := pgpArmor(pgpArmorMessage, c)
+	i := bytes.LastIndexByte(armored[:len(armored)-30], '=') + 1
+	armored[i] ^= 'A' ^ 'B'
+	if _, err := OpenPGPDecrypt(priv, armored); err == nil {
+		t.Error("Une armure avec une somme de contrôle fausse a été acceptée")
+	}
+}
+
+func TestOpenPGPExport(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	priv := &OpenPGPPrivateKey{ElgamalPrivateKey: *keys, Created: created}
+
+	pub, err := ReadOpenPGPPublicKey(priv.Public().Export())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pub.KeyID() != priv.Public().KeyID() || !pub.Created.Equal(created) || pub.H.Cmp(keys.H) != 0 {
+		t.Error("La clé publique exportée n'a pas été relue à l'identique")
+	}
+
+	tpriv, err := ReadOpenPGPPrivateKey(priv.Export())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpriv.X.Cmp(keys.X) != 0 || !tpriv.Created.Equal(created) {
+		t.Error("La clé secrète exportée n'a pas été relue à l'identique")
+	}
+
+	// La réexportation de la clé de GnuPG conserve son identifiant
+	gpg, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-secret.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reexported, err := ReadOpenPGPPrivateKey(gpg.Export())
+	if err != nil || reexported.Public().KeyID() != gpgKeyID {
+		t.Error("La clé de GnuPG réexportée a changé d'identifiant")
+	}
+}
+
+func TestPGPPacketLengths(t *testing.T) {
+	for _, n := range []int{0, 191, 192, 8383, 8384, 100000} {
+		body := randomBytes(n)
+		p, rest, err := readPGPPacket(writePGPPacket(pgpTagLiteral, body))
+		if err != nil || len(rest) != 0 || p.tag != pgpTagLiteral || !bytes.Equal(p.body, body) {
+			t.Error("Echec de l'encodage de la longueur", n)
+		}
+	}
+
+	// Fragments de longueur partielle : 512 + 512 + 3 octets
+	body := randomBytes(1027)
+	packet := append([]byte{0xc0 | pgpTagLiteral, 0xe9}, body[:512]...)
+	packet = append(packet, 0xe9)
+	packet = append(packet, body[512:1024]...)
+	packet = append(packet, 3)
+	packet = append(packet, body[1024:]...)
+	p, _, err := readPGPPacket(packet)
+	if err != nil || !bytes.Equal(p.body, body) {
+		t.Error("Echec de la lecture des longueurs partielles")
+	}
+
+	if _, _, err := readPGPPacket([]byte{0xc0 | pgpTagLiteral, 10, 1, 2}); err == nil {
+		t.Error("Un paquet tronqué a été accepté")
+	}
+}
+
+func TestOpenPGPSeveralPKESK(t *testing.T) {
+	priv, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-secret.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	other := &OpenPGPPrivateKey{ElgamalPrivateKey: *otherKey, Created: priv.Created}
+
+	c, err := OpenPGPEncrypt(rand.Reader, priv.Public(), []byte(gpgMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, _ := readPGPPackets(c)
+
+	// PKESK anonyme (identifiant nul) destiné à une autre clé
+	o, err := OpenPGPEncrypt(rand.Reader, other.Public(), []byte(gpgMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPackets, _ := readPGPPackets(o)
+	anonymous := append([]byte{}, otherPackets[0].body...)
+	copy(anonymous[1:9], make([]byte, 8))
+
+	msg := writePGPPacket(pgpTagPKESK, anonymous)
+	msg = append(msg, writePGPPacket(pgpTagPKESK, packets[0].body)...)
+	msg = append(msg, writePGPPacket(pgpTagSEIPD, packets[1].body)...)
+
+	if m, err := OpenPGPDecrypt(priv, msg); err != nil || string(m) != gpgMessage {
+		t.Errorf("Le deuxième paquet PKESK n'a pas été essayé : %v", err)
+	}
+	if m, err := OpenPGPDecrypt(other, append(writePGPPacket(pgpTagPKESK, anonymous), writePGPPacket(pgpTagSEIPD, otherPackets[1].body)...)); err != nil || string(m) != gpgMessage {
+		t.Errorf("Echec du déchiffrement pour un destinataire anonyme : %v", err)
+	}
+
+	// Aucun paquet PKESK ne se déchiffre
+	msg = writePGPPacket(pgpTagPKESK, anonymous)
+	msg = append(msg, writePGPPacket(pgpTagSEIPD, packets[1].body)...)
+	if _, err := OpenPGPDecrypt(priv, msg); err == nil {
+		t.Error("Un message sans clé de session valide a été déchiffré")
+	}
+}
+
+func TestOpenPGPDecompressionLimit(t *testing.T) {
+	var b bytes.Buffer
+	w, _ := flate.NewWriter(&b, flate.BestCompression)
+	w.Write(make([]byte, 1<<20))
+	w.Close()
+
+	if out, err := pgpDecompress(pgpCompressionZIP, b.Bytes(), 1<<20); err != nil || len(out) != 1<<20 {
+		t.Errorf("Echec de la décompression : %v", err)
+	}
+	if _, err := pgpDecompress(pgpCompressionZIP, b.Bytes(), 1<<20-1); err != errPGPTooLarge {
+		t.Errorf("Limite de décompression non respectée : %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Variables d'environnement permettant de fournir la phrase de passe sans
+// terminal : directement, pour une seule clé, ou via un descripteur de
+// fichier ouvert dont une ligne est lue pour chaque clé
+const (
+	envPassphrase   = "GOCRYPTO_PASSPHRASE"
+	envPassphraseFD = "GOCRYPTO_PASSPHRASE_FD"
+)
+
+var (
+	errNoTerminal         = errors.New("gocrypto: aucun terminal pour saisir la phrase de passe (voir " + envPassphrase + " et " + envPassphraseFD + ")")
+	errPassphraseMismatch = errors.New("gocrypto: les phrases de passe ne correspondent pas")
+	errEmptyPassphrase    = errors.New("gocrypto: phrase de passe vide")
+	errPassphraseReused   = errors.New("gocrypto: " + envPassphrase + " ne fournit qu'une phrase de passe, utiliser " + envPassphraseFD + " (une ligne par clé)")
+)
+
+// Descripteur lu pour GOCRYPTO_PASSPHRASE_FD. Il reste ouvert jusqu'à la
+// fin du programme pour que chaque clé chargée lise la ligne suivante.
+var passphraseFD struct {
+	name   string
+	reader *bufio.Reader
+}
+
+// Vrai une fois GOCRYPTO_PASSPHRASE utilisée : une commande chargeant
+// plusieurs clés ne doit pas leur donner silencieusement la même phrase
+var envPassphraseUsed bool
+
+// Lit la première ligne de r, sans le retour à la ligne
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return nil, err
+	}
+	return bytes.TrimRight(line, "\r\n"), nil
+}
+
+// Renvoie la phrase de passe : depuis la ligne suivante du descripteur de
+// fichier indiqué par GOCRYPTO_PASSPHRASE_FD, depuis GOCRYPTO_PASSPHRASE
+// (une seule fois), ou à défaut en la demandant sur le terminal sans
+// l'afficher. Si confirm est vrai, la saisie au terminal est demandée deux
+// fois.
+func readPassphrase(prompt string, confirm bool) ([]byte, error) {
+	if fd := os.Getenv(envPassphraseFD); fd != "" {
+		r, err := openPassphraseFD(fd)
+		if err != nil {
+			return nil, err
+		}
+		return nonEmpty(readLine(r))
+	}
+
+	if p, ok := os.LookupEnv(envPassphrase); ok {
+		if envPassphraseUsed {
+			return nil, errPassphraseReused
+		}
+		envPassphraseUsed = true
+		return nonEmpty([]byte(p), nil)
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, errNoTerminal
+	}
+	defer tty.Close()
+
+	passphrase, err := promptNoEcho(tty, prompt)
+	if err != nil || !confirm {
+		return nonEmpty(passphrase, err)
+	}
+
+	again, err := promptNoEcho(tty, "Confirmation : ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, again) {
+		return nil, errPassphraseMismatch
+	}
+	return nonEmpty(passphrase, nil)
+}
+
+// Renvoie le lecteur du descripteur fd, ouvert au premier appel
+func openPassphraseFD(fd string) (*bufio.Reader, error) {
+	if passphraseFD.reader != nil && passphraseFD.name == fd {
+		return passphraseFD.reader, nil
+	}
+
+	n, err := strconv.Atoi(fd)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("gocrypto: %s invalide : %q", envPassphraseFD, fd)
+	}
+
+	f := os.NewFile(uintptr(n), "passphrase")
+	if f == nil {
+		return nil, fmt.Errorf("gocrypto: %s invalide : %q", envPassphraseFD, fd)
+	}
+
+	passphraseFD.name, passphraseFD.reader = fd, bufio.NewReader(f)
+	return passphraseFD.reader, nil
+}
+
+// Refuse les phrases de passe vides
+func nonEmpty(passphrase []byte, err error) ([]byte, error) {
+	if err == nil && len(passphrase) == 0 {
+		return nil, errEmptyPassphrase
+	}
+	return passphrase, err
+}
+
+// Affiche prompt sur le terminal puis lit une ligne sans l'afficher
+func promptNoEcho(tty *os.File, prompt string) ([]byte, error) {
+	fmt.Fprint(tty, prompt)
+	defer fmt.Fprintln(tty)
+
+	restore, err := disableEcho(tty)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	return readLine(bufio.NewReader(tty))
+}
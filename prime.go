@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"io"
 	"math/big"
+	"runtime"
+	"sync"
 )
 
 var (
@@ -59,10 +64,12 @@ func probablyPrime(p *big.Int, accuracy uint) bool {
 
 	exp, remainder := decompose(T.Sub(p, big1))
 
-	pMinus1 := new(big.Int).Sub(p, big1)
+	pMinus2 := new(big.Int).Sub(p, big2)
 
+	// Le choix des témoins n'a pas besoin d'être secret, on utilise
+	// directement crypto/rand
 	for i := uint(0); i < accuracy; i++ {
-		w = randRange(big2, pMinus1)
+		w = randRange(rand.Reader, big2, pMinus2)
 		if isWitness(w, p, exp, remainder) {
 			return false
 		}
@@ -71,11 +78,147 @@ func probablyPrime(p *big.Int, accuracy uint) bool {
 	return true
 }
 
-func generateRandomPrime(size int) *big.Int {
+// Renvoie un nombre premier aléatoire de size octets tiré depuis random
+func generateRandomPrime(random io.Reader, size int) *big.Int {
 	for {
-		n := randomBigInt(size)
+		n := randomBigInt(random, size)
 		if probablyPrime(n, 25) {
 			return n
 		}
 	}
 }
+
+// Borne supérieure des petits nombres premiers utilisés par le crible
+const sieveBound = 1 << 14
+
+// Nombre maximal de candidats examinés à partir d'un même point de départ
+const sieveWindow = 1 << 16
+
+// Liste des nombres premiers impairs inférieurs à sieveBound
+var smallPrimes = sieve(sieveBound)
+
+// Crible d'Ératosthène : renvoie les nombres premiers impairs < bound
+func sieve(bound int) []uint64 {
+	composite := make([]bool, bound)
+	var primes []uint64
+
+	for i := 3; i < bound; i += 2 {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, uint64(i))
+		for j := i * i; j < bound; j += 2 * i {
+			composite[j] = true
+		}
+	}
+
+	return primes
+}
+
+// Source d'aléa protégée par un verrou pour être partagée entre
+// plusieurs goroutines
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(b []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(b)
+}
+
+// Renvoie un nombre premier sûr p = 2n+1 (n premier) d'exactement bits
+// bits. La recherche est répartie sur jobs goroutines (runtime.NumCPU()
+// si jobs <= 0). Avec jobs > 1, le résultat dépend de l'ordonnancement
+// même si random est déterministe.
+func generateSafePrime(random io.Reader, bits, jobs int) *big.Int {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	if jobs == 1 {
+		return searchSafePrime(context.Background(), random, bits)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	random = &lockedReader{r: random}
+	found := make(chan *big.Int, jobs)
+
+	for i := 0; i < jobs; i++ {
+		go func() {
+			if p := searchSafePrime(ctx, random, bits); p != nil {
+				found <- p
+			}
+		}()
+	}
+
+	// Le premier résultat arrête les autres goroutines
+	return <-found
+}
+
+// Cherche un nombre premier sûr de bits bits jusqu'à ce que ctx soit
+// annulé (renvoie alors nil)
+func searchSafePrime(ctx context.Context, random io.Reader, bits int) *big.Int {
+	var (
+		n        = new(big.Int)
+		p        = new(big.Int)
+		pMinus1  = new(big.Int)
+		T        = new(big.Int)
+		r        = new(big.Int)
+		residues = make([]uint64, len(smallPrimes))
+		nBytes   = (bits - 1 + 7) / 8
+	)
+
+	for {
+		// Point de départ : n impair d'exactement bits-1 bits
+		base := randomBigInt(random, nBytes)
+		for i := bits - 1; i < nBytes*8; i++ {
+			base.SetBit(base, i, 0)
+		}
+		base.SetBit(base, bits-2, 1)
+		base.SetBit(base, 0, 1)
+
+		for i, q := range smallPrimes {
+			residues[i] = r.Mod(base, r.SetUint64(q)).Uint64()
+		}
+
+	nextDelta:
+		for delta := uint64(0); delta < sieveWindow; delta += 2 {
+			if delta%1024 == 0 && ctx.Err() != nil {
+				return nil
+			}
+
+			// On rejette n si n ou 2n+1 a un petit facteur premier :
+			// q | n  <=> n = 0 (mod q)
+			// q | 2n+1 <=> n = (q-1)/2 (mod q)
+			for i, q := range smallPrimes {
+				m := (residues[i] + delta) % q
+				if m == 0 || m == (q-1)/2 {
+					continue nextDelta
+				}
+			}
+
+			n.SetUint64(delta)
+			n.Add(n, base)
+			if n.BitLen() != bits-1 {
+				break
+			}
+
+			// p = 2n + 1
+			pMinus1.Lsh(n, 1)
+			p.Add(pMinus1, big1)
+
+			// Test de Fermat en base 2 sur p, peu coûteux, avant les
+			// tests de Miller-Rabin
+			if T.Exp(big2, pMinus1, p).Cmp(big1) != 0 {
+				continue
+			}
+
+			if probablyPrime(n, 25) && probablyPrime(p, 25) {
+				return p
+			}
+		}
+	}
+}
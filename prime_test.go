@@ -0,0 +1,42 @@
+package main
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestSieve(t *testing.T) {
+	primes := sieve(50)
+	expected := []uint64{3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47}
+
+	if len(primes) != len(expected) {
+		t.Fatal("Le crible renvoie", primes)
+	}
+	for i := range primes {
+		if primes[i] != expected[i] {
+			t.Fatal("Le crible renvoie", primes)
+		}
+	}
+}
+
+func TestGenerateSafePrime(t *testing.T) {
+	for _, jobs := range []int{1, 4} {
+		p := generateSafePrime(rand.Reader, 256, jobs)
+
+		if p.BitLen() != 256 {
+			t.Error("Le nombre premier sûr n'a pas la bonne taille :", p.BitLen())
+		}
+
+		n := new(big.Int).Rsh(p, 1)
+		if !p.ProbablyPrime(20) || !n.ProbablyPrime(20) {
+			t.Error("Le nombre généré n'est pas un nombre premier sûr :", p)
+		}
+	}
+}
+
+func BenchmarkGenerateSafePrime512(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		generateSafePrime(rand.Reader, 512, 0)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Identifiants des preuves à divulgation nulle de connaissance, utilisés
+// aussi comme séparateurs de domaine dans le calcul des défis
+const (
+	proofKeyVersion        = "elgamal-proof-key-v1"
+	proofDecryptionVersion = "elgamal-proof-decryption-v1"
+	proofElementVersion    = "elgamal-proof-element-v1"
+)
+
+var (
+	errInvalidProof   = errors.New("gocrypto: preuve invalide")
+	errWrongProof     = errors.New("gocrypto: la preuve ne concerne pas cette opération")
+	errWrongPlaintext = errors.New("gocrypto: le message clair ne correspond pas au déchiffrement prouvé")
+)
+
+// Proof est une preuve non interactive à divulgation nulle de connaissance
+// (Fiat-Shamir, défi calculé avec SHA-256) : e est le défi et z la réponse.
+// Les preuves sont calculées dans le sous-groupe d'ordre premier q de la
+// clé (voir schnorrGroup).
+type Proof struct {
+	Version string // type de preuve
+	KeyID   uint64 // identifiant de la clé du prouveur
+
+	// S est le secret partagé c1^x révélé par une preuve de déchiffrement
+	// d'un message de ElgamalEncrypt, nil pour les autres preuves
+	S *big.Int
+
+	E *big.Int
+	Z *big.Int
+}
+
+// Calcule le défi e = SHA-256(domaine | contexte | éléments) mod q, les
+// éléments étant encodés sur la taille de p
+func proofChallenge(domain string, context []byte, p, q *big.Int, elements ...*big.Int) *big.Int {
+	pLen := (p.BitLen() + 7) / 8
+
+	d := sha256.New()
+	d.Write(serialize([]byte(domain), context))
+	for _, x := range elements {
+		d.Write(fixedBytes(x, pLen))
+	}
+	return hashToInt(d.Sum(nil), q)
+}
+
+// Renvoie a^z * b^-e (mod p), b étant d'ordre q
+func proofCommitment(a, z, b, e, p, q *big.Int) *big.Int {
+	r := new(big.Int).Exp(b, new(big.Int).Sub(q, e), p)
+	r.Mul(r, new(big.Int).Exp(a, z, p))
+	return r.Mod(r, p)
+}
+
+// Preuve de Chaum-Pedersen que log_g(h) = log_u(v) = x : avec r aléatoire,
+// A = g^r, B = u^r, e = H(g, h, u, v, A, B) et z = r + e*x (mod q)
+func proveDLEQ(rand io.Reader, domain string, context []byte, p, q, g, h, u, v, x *big.Int) (e, z *big.Int) {
+	r := randRange(rand, big1, new(big.Int).Sub(q, big1))
+	A := new(big.Int).Exp(g, r, p)
+	B := new(big.Int).Exp(u, r, p)
+
+	e = proofChallenge(domain, context, p, q, g, h, u, v, A, B)
+	z = new(big.Int).Mul(e, x)
+	z.Add(z, r)
+	return e, z.Mod(z, q)
+}
+
+// Vérifie une preuve produite par proveDLEQ : on recalcule A = g^z * h^-e
+// et B = u^z * v^-e puis le défi. h, u et v doivent appartenir au
+// sous-groupe d'ordre q.
+func verifyDLEQ(domain string, context []byte, p, q, g, h, u, v, e, z *big.Int) bool {
+	if e.Sign() < 0 || e.Cmp(q) >= 0 || z.Sign() < 0 || z.Cmp(q) >= 0 {
+		return false
+	}
+
+	A := proofCommitment(g, z, h, e, p, q)
+	B := proofCommitment(u, z, v, e, p, q)
+	return proofChallenge(domain, context, p, q, g, h, u, v, A, B).Cmp(e) == 0
+}
+
+// ProveKeyOwnership prouve la connaissance de la clé privée (preuve de
+// Schnorr) sans la révéler. Le contexte (par exemple un nombre choisi par
+// le vérificateur) empêche de rejouer la preuve dans un autre contexte.
+func ProveKeyOwnership(rand io.Reader, priv *ElgamalPrivateKey, context []byte) *Proof {
+	p, q, g, h := schnorrGroup(&priv.ElgamalPublicKey)
+	x := new(big.Int).Mod(priv.X, q)
+
+	r := randRange(rand, big1, new(big.Int).Sub(q, big1))
+	A := new(big.Int).Exp(g, r, p)
+
+	e := proofChallenge(proofKeyVersion, context, p, q, g, h, A)
+	z := new(big.Int).Mul(e, x)
+	z.Add(z, r)
+
+	return &Proof{Version: proofKeyVersion, KeyID: priv.KeyID(), E: e, Z: z.Mod(z, q)}
+}
+
+// VerifyKeyOwnership vérifie une preuve produite par ProveKeyOwnership
+// pour la clé pub dans le contexte context
+func VerifyKeyOwnership(pub *ElgamalPublicKey, context []byte, proof *Proof) error {
+	if proof.Version != proofKeyVersion {
+		return errWrongProof
+	}
+	if proof.KeyID != pub.KeyID() {
+		return &KeyMismatchError{Expected: proof.KeyID, Actual: pub.KeyID()}
+	}
+
+	p, q, g, h := schnorrGroup(pub)
+	if !isGroupElement(h, p, q) || proof.E.Cmp(q) >= 0 || proof.Z.Cmp(q) >= 0 {
+		return errInvalidProof
+	}
+
+	A := proofCommitment(g, proof.Z, h, proof.E, p, q)
+	if proofChallenge(proofKeyVersion, context, p, q, g, h, A).Cmp(proof.E) != 0 {
+		return errInvalidProof
+	}
+	return nil
+}
+
+// Ramène u dans le sous-groupe d'ordre q de la clé : u^2 pour une clé
+// ElGamal (comme g et h dans schnorrGroup), u lui-même pour une clé DSA,
+// qui doit alors appartenir au sous-groupe
+func toSubgroup(pub *ElgamalPublicKey, p, q, u *big.Int) (*big.Int, bool) {
+	if u.Cmp(big1) <= 0 || u.Cmp(p) >= 0 {
+		return nil, false
+	}
+	if pub.SubgroupOrder != nil {
+		return u, isGroupElement(u, p, q)
+	}
+	return new(big.Int).Exp(u, big2, p), true
+}
+
+// La preuve de déchiffrement porte sur s^2 = (c1^2)^x pour une clé
+// ElGamal, ce qui ne fixe s qu'au signe près. p = 2n + 1 étant sûr,
+// p ≡ 3 mod 4 et s et -s ont des symboles de Legendre opposés : celui de
+// s = c1^x vaut (c1|p)^x, la parité de x étant donnée par (h|p) lorsque g
+// n'est pas un carré.
+func decryptionSignValid(pub *ElgamalPublicKey, p, c1, s *big.Int) bool {
+	if pub.SubgroupOrder != nil {
+		return true
+	}
+
+	jc := big.Jacobi(c1, p)
+	if jc == 1 {
+		return big.Jacobi(s, p) == 1
+	}
+	return big.Jacobi(pub.G, p) == -1 && big.Jacobi(s, p) == big.Jacobi(pub.H, p)
+}
+
+// ProveDecryption déchiffre un message produit par ElgamalEncrypt (ou
+// ElgamalEncryptMulti) et prouve que le déchiffrement est correct sans
+// révéler la clé privée : la preuve révèle le secret partagé s = c1^x, qui
+// ne permet de déchiffrer que ce message, et contient une preuve de
+// Chaum-Pedersen que log_g(h) = log_c1(s).
+// La preuve porte sur le c1 ayant effectivement permis de déchiffrer le
+// message : sinon, un emplacement choisi d'un message à plusieurs
+// destinataires ferait révéler c1^x pour un c1 quelconque.
+func ProveDecryption(rand io.Reader, priv *ElgamalPrivateKey, ciphertext []byte) (plaintext []byte, proof *Proof, err error) {
+	pub := &priv.ElgamalPublicKey
+
+	// Le dernier c1 décapsulé est celui qui a déchiffré le message
+	var c1 *big.Int
+	decapsulate := func(c *big.Int) ([]byte, []byte, error) {
+		c1 = c
+		return elgamalDecapsulate(priv, c)
+	}
+	plaintext, err = elgamalDecryptKEM(pub, decapsulate, deserialize(ciphertext))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, q, g, h := schnorrGroup(pub)
+	u, ok := toSubgroup(pub, p, q, c1)
+	if !ok {
+		return nil, nil, errInvalidCiphertext
+	}
+
+	s := new(big.Int).Exp(c1, priv.X, p)
+	v, _ := toSubgroup(pub, p, q, s)
+
+	x := new(big.Int).Mod(priv.X, q)
+	e, z := proveDLEQ(rand, proofDecryptionVersion, nil, p, q, g, h, u, v, x)
+
+	return plaintext, &Proof{Version: proofDecryptionVersion, KeyID: priv.KeyID(), S: s, E: e, Z: z}, nil
+}
+
+// VerifyDecryption vérifie que plaintext est bien le déchiffrement de
+// ciphertext par la clé privée associée à pub, à partir d'une preuve
+// produite par ProveDecryption
+func VerifyDecryption(pub *ElgamalPublicKey, ciphertext, plaintext []byte, proof *Proof) error {
+	if proof.Version != proofDecryptionVersion || proof.S == nil {
+		return errWrongProof
+	}
+	if proof.KeyID != pub.KeyID() {
+		return &KeyMismatchError{Expected: proof.KeyID, Actual: pub.KeyID()}
+	}
+
+	p, q, g, h := schnorrGroup(pub)
+	if !isGroupElement(h, p, q) {
+		return errInvalidProof
+	}
+
+	// La clé du message est dérivée du secret partagé prouvé
+	decapsulate := func(c1 *big.Int) ([]byte, []byte, error) {
+		u, ok1 := toSubgroup(pub, p, q, c1)
+		v, ok2 := toSubgroup(pub, p, q, proof.S)
+		if !ok1 || !ok2 || !decryptionSignValid(pub, p, c1, proof.S) ||
+			!verifyDLEQ(proofDecryptionVersion, nil, p, q, g, h, u, v, proof.E, proof.Z) {
+			return nil, nil, errInvalidProof
+		}
+		key, nonce := deriveKEMKey(p, c1, proof.S)
+		return key, nonce, nil
+	}
+
+	d, err := elgamalDecryptKEM(pub, decapsulate, deserialize(ciphertext))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(d, plaintext) {
+		return errWrongPlaintext
+	}
+	return nil
+}
+
+// ProveElementDecryption déchiffre un chiffré homomorphe (voir
+// ElgamalCiphertext) et prouve que m est son déchiffrement : preuve de
+// Chaum-Pedersen que log_g(h) = log_c1(c2/m)
+func ProveElementDecryption(rand io.Reader, priv *ElgamalPrivateKey, c *ElgamalCiphertext) (m *big.Int, proof *Proof, err error) {
+	m, err = ElgamalDecryptElement(priv, c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, q, g, h := schnorrGroup(&priv.ElgamalPublicKey)
+	v := new(big.Int).ModInverse(m, p)
+	v.Mul(v, c.C2).Mod(v, p)
+
+	x := new(big.Int).Mod(priv.X, q)
+	e, z := proveDLEQ(rand, proofElementVersion, nil, p, q, g, h, c.C1, v, x)
+
+	return m, &Proof{Version: proofElementVersion, KeyID: priv.KeyID(), E: e, Z: z}, nil
+}
+
+// VerifyElementDecryption vérifie une preuve produite par
+// ProveElementDecryption
+func VerifyElementDecryption(pub *ElgamalPublicKey, c *ElgamalCiphertext, m *big.Int, proof *Proof) error {
+	if proof.Version != proofElementVersion {
+		return errWrongProof
+	}
+	if proof.KeyID != pub.KeyID() {
+		return &KeyMismatchError{Expected: proof.KeyID, Actual: pub.KeyID()}
+	}
+
+	p, q, g, h := schnorrGroup(pub)
+	if !c.valid(p, q) || !isGroupElement(m, p, q) || !isGroupElement(h, p, q) {
+		return errInvalidProof
+	}
+
+	v := new(big.Int).ModInverse(m, p)
+	v.Mul(v, c.C2).Mod(v, p)
+	if !verifyDLEQ(proofElementVersion, nil, p, q, g, h, c.C1, v, proof.E, proof.Z) {
+		return errInvalidProof
+	}
+	return nil
+}
+
+// GetBytes renvoie sous forme d'octets la preuve :
+// version | identifiant de la clé | e | z [ | s ]
+func (proof *Proof) GetBytes() []byte {
+	fields := [][]byte{
+		[]byte(proof.Version), binary.BigEndian.AppendUint64(nil, proof.KeyID),
+		proof.E.Bytes(), proof.Z.Bytes(),
+	}
+	if proof.S != nil {
+		fields = append(fields, proof.S.Bytes())
+	}
+	return serialize(fields...)
+}
+
+// ParseProof charge une preuve enregistrée avec GetBytes
+func ParseProof(b []byte) (*Proof, error) {
+	d := deserialize(b)
+	if len(d) < 4 || len(d[1]) != 8 {
+		return nil, errInvalidProof
+	}
+
+	proof := &Proof{
+		Version: string(d[0]),
+		KeyID:   binary.BigEndian.Uint64(d[1]),
+		E:       new(big.Int).SetBytes(d[2]),
+		Z:       new(big.Int).SetBytes(d[3]),
+	}
+
+	switch {
+	case proof.Version == proofDecryptionVersion && len(d) == 5:
+		proof.S = new(big.Int).SetBytes(d[4])
+	case (proof.Version == proofKeyVersion || proof.Version == proofElementVersion) && len(d) == 4:
+	default:
+		return nil, errInvalidProof
+	}
+	return proof, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestKeyOwnershipProof(t *testing.T) {
+	for _, priv := range []*ElgamalPrivateKey{keys, dsaVectorKey()} {
+		proof, err := ParseProof(ProveKeyOwnership(rand.Reader, priv, []byte("défi 42")).GetBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyKeyOwnership(&priv.ElgamalPublicKey, []byte("défi 42"), proof); err != nil {
+			t.Error("Preuve de possession refusée", err)
+		}
+		if err := VerifyKeyOwnership(&priv.ElgamalPublicKey, []byte("défi 43"), proof); err != errInvalidProof {
+			t.Error("Preuve acceptée dans un autre contexte", err)
+		}
+
+		proof.Z.Add(proof.Z, big1)
+		if err := VerifyKeyOwnership(&priv.ElgamalPublicKey, []byte("défi 42"), proof); err != errInvalidProof {
+			t.Error("Preuve modifiée acceptée", err)
+		}
+	}
+
+	// Preuve pour une autre clé ayant le même identifiant
+	other := GenerateElgamalKeys(rand.Reader, 160, 0)
+	proof := ProveKeyOwnership(rand.Reader, other, nil)
+	proof.KeyID = keys.KeyID()
+	if err := VerifyKeyOwnership(&keys.ElgamalPublicKey, nil, proof); err != errInvalidProof {
+		t.Error("Preuve d'une autre clé acceptée", err)
+	}
+}
+
+func TestDecryptionProof(t *testing.T) {
+	m := []byte("résultat publié")
+
+	for _, priv := range []*ElgamalPrivateKey{keys, dsaVectorKey()} {
+		pub := &priv.ElgamalPublicKey
+		c := ElgamalEncrypt(rand.Reader, pub, m)
+
+		d, proof, err := ProveDecryption(rand.Reader, priv, c)
+		if err != nil || string(d) != string(m) {
+			t.Fatal("Echec du déchiffrement prouvé", err)
+		}
+		proof, err = ParseProof(proof.GetBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyDecryption(pub, c, m, proof); err != nil {
+			t.Error("Preuve de déchiffrement refusée", err)
+		}
+		if err := VerifyDecryption(pub, c, []byte("autre résultat"), proof); err != errWrongPlaintext {
+			t.Error("Un autre message clair a été accepté", err)
+		}
+		if err := VerifyDecryption(pub, ElgamalEncrypt(rand.Reader, pub, m), m, proof); err != errInvalidProof {
+			t.Error("Preuve acceptée pour un autre message chiffré", err)
+		}
+
+		// -s vérifie la preuve sur s^2 mais doit être refusé
+		p := new(big.Int).Add(pub.Q, big1)
+		neg := *proof
+		neg.S = new(big.Int).Sub(p, proof.S)
+		if err := VerifyDecryption(pub, c, m, &neg); err != errInvalidProof {
+			t.Error("Un secret partagé de signe opposé a été accepté", err)
+		}
+
+		var mismatch *KeyMismatchError
+		other := GenerateElgamalKeys(rand.Reader, 160, 0)
+		if err := VerifyDecryption(&other.ElgamalPublicKey, c, m, proof); !errors.As(err, &mismatch) {
+			t.Error("Preuve acceptée pour une autre clé", err)
+		}
+	}
+}
+
+func TestElementDecryptionProof(t *testing.T) {
+	pub := &keys.ElgamalPublicKey
+	a := randomGroupElement(pub)
+	c, err := ElgamalEncryptElement(rand.Reader, pub, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, proof, err := ProveElementDecryption(rand.Reader, keys, c)
+	if err != nil || m.Cmp(a) != 0 {
+		t.Fatal("Echec du déchiffrement prouvé", err)
+	}
+	if err := VerifyElementDecryption(pub, c, m, proof); err != nil {
+		t.Error("Preuve de déchiffrement refusée", err)
+	}
+	if err := VerifyElementDecryption(pub, c, randomGroupElement(pub), proof); err != errInvalidProof {
+		t.Error("Un autre message clair a été accepté", err)
+	}
+	if err := VerifyDecryption(pub, nil, nil, proof); err != errWrongProof {
+		t.Error("Une preuve d'un autre type a été acceptée", err)
+	}
+}
+
+func TestDecryptionProofChosenSlot(t *testing.T) {
+	pub := &keys.ElgamalPublicKey
+	secret := []byte("message de la victime")
+	victim := ElgamalEncrypt(rand.Reader, pub, secret)
+	victimC1 := deserialize(victim)[2]
+
+	// Message à deux emplacements pour la même clé : le premier reprend le
+	// c1 de la victime, seul le second déchiffre le message
+	m := []byte("message de l'attaquant")
+	version, keyID := []byte(elgamalMultiVersion), pub.keyIDBytes()
+	contentKey := randomBytes(32)
+	c1, key, nonce := elgamalEncapsulate(rand.Reader, pub)
+	real := serialize(keyID, c1.Bytes(), newGCM(key).Seal(nil, nonce, contentKey, serialize(version, keyID)))
+	fake := serialize(keyID, victimC1, randomBytes(48))
+	header := serialize(fake, real)
+	c := serialize(version, header, newGCM(contentKey).Seal(nil, make([]byte, 12), m, serialize(version, header)))
+
+	d, proof, err := ProveDecryption(rand.Reader, keys, c)
+	if err != nil || !bytes.Equal(d, m) {
+		t.Fatal("Echec du déchiffrement prouvé", err)
+	}
+	if err := VerifyDecryption(pub, c, m, proof); err != nil {
+		t.Error("Preuve de déchiffrement refusée", err)
+	}
+
+	p := new(big.Int).Add(pub.Q, big1)
+	if proof.S.Cmp(new(big.Int).Exp(new(big.Int).SetBytes(victimC1), keys.X, p)) == 0 {
+		t.Fatal("La preuve révèle le secret partagé d'un autre message")
+	}
+	if err := VerifyDecryption(pub, victim, secret, proof); err == nil {
+		t.Error("La preuve permet de déchiffrer un autre message")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+)
+
+// Identifiant du format des clés privées protégées par une phrase de passe
+// (PBKDF2-HMAC-SHA256 + AES-256-GCM)
+const protectedKeyVersion = "gocrypto-key-pbkdf2-v1"
+
+// Type du bloc PEM des clés protégées
+const pemProtectedKey = "GOCRYPTO ENCRYPTED PRIVATE KEY"
+
+// Nombre d'itérations de PBKDF2 utilisé pour protéger les clés, et bornes
+// acceptées au déchiffrement
+const (
+	protectedKeyIterations    = 600000
+	protectedKeyMinIterations = 1000
+	protectedKeyMaxIterations = 10000000
+)
+
+// Taille du sel de PBKDF2 en octets
+const protectedKeySaltSize = 16
+
+var (
+	errProtectedKey     = errors.New("gocrypto: clé privée protégée par une phrase de passe")
+	errWrongPassphrase  = errors.New("gocrypto: phrase de passe incorrecte ou clé altérée")
+	errInvalidProtected = errors.New("gocrypto: clé protégée invalide")
+)
+
+// Dérive la clé AES-256 de la phrase de passe
+func deriveProtectionKey(passphrase, salt []byte, iterations int) []byte {
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, iterations, 32)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
+	}
+	return key
+}
+
+// Chiffre key avec la phrase de passe en utilisant iterations itérations
+// de PBKDF2
+func protectKey(rand io.Reader, key, passphrase []byte, iterations int) []byte {
+	version := []byte(protectedKeyVersion)
+	iter := binary.BigEndian.AppendUint32(nil, uint32(iterations))
+	salt := readRandom(rand, protectedKeySaltSize)
+	nonce := readRandom(rand, 12)
+
+	// La version et les paramètres de PBKDF2 sont authentifiés
+	aead := newGCM(deriveProtectionKey(passphrase, salt, iterations))
+	sealed := aead.Seal(nil, nonce, key, serialize(version, iter, salt))
+
+	return serialize(version, iter, salt, nonce, sealed)
+}
+
+// ProtectPrivateKey chiffre une clé privée encodée (au format raw, DER ou
+// PEM) avec une clé AES-256-GCM dérivée de la phrase de passe par
+// PBKDF2-HMAC-SHA256 :
+// version | itérations | sel | nonce | AES-GCM(clé)
+// Si pemEncoded est vrai, le résultat est encapsulé dans un bloc PEM.
+func ProtectPrivateKey(rand io.Reader, key, passphrase []byte, pemEncoded bool) []byte {
+	protected := protectKey(rand, key, passphrase, protectedKeyIterations)
+	if pemEncoded {
+		return pem.EncodeToMemory(&pem.Block{Type: pemProtectedKey, Bytes: protected})
+	}
+	return protected
+}
+
+// Renvoie le contenu d'une clé protégée, sans l'éventuel bloc PEM, ou nil
+// si b n'est pas une clé protégée
+func protectedKeyFields(b []byte) [][]byte {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN "+pemProtectedKey)) {
+		block, _ := pem.Decode(b)
+		if block == nil {
+			return nil
+		}
+		b = block.Bytes
+	}
+
+	d := deserialize(b)
+	if len(d) != 5 || string(d[0]) != protectedKeyVersion {
+		return nil
+	}
+	return d
+}
+
+// IsProtectedKey indique si b est une clé privée protégée par une phrase
+// de passe
+func IsProtectedKey(b []byte) bool {
+	return protectedKeyFields(b) != nil
+}
+
+// UnprotectPrivateKey déchiffre une clé protégée avec ProtectPrivateKey et
+// renvoie la clé encodée, à charger avec ParseElgamalPrivateKey (ou
+// ParseThresholdKey pour une part de clé à seuil)
+func UnprotectPrivateKey(b, passphrase []byte) ([]byte, error) {
+	d := protectedKeyFields(b)
+	if d == nil {
+		return nil, errInvalidProtected
+	}
+	version, iter, salt, nonce, sealed := d[0], d[1], d[2], d[3], d[4]
+
+	if len(iter) != 4 || len(salt) < 8 || len(nonce) != 12 {
+		return nil, errInvalidProtected
+	}
+	iterations := int(binary.BigEndian.Uint32(iter))
+	if iterations < protectedKeyMinIterations || iterations > protectedKeyMaxIterations {
+		return nil, errInvalidProtected
+	}
+
+	aead := newGCM(deriveProtectionKey(passphrase, salt, iterations))
+	key, err := aead.Open(nil, nonce, sealed, serialize(version, iter, salt))
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return key, nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestProtectedKey(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+
+	for _, format := range []string{formatRaw, formatDER, formatPEM} {
+		encoded, err := keys.Encode(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		protected := ProtectPrivateKey(rand.Reader, encoded, passphrase, format == formatPEM)
+		if !IsProtectedKey(protected) || IsProtectedKey(encoded) {
+			t.Error("Mauvaise détection des clés protégées", format)
+		}
+		if bytes.Contains(protected, keys.X.Bytes()) {
+			t.Error("La clé protégée contient X en clair", format)
+		}
+
+		// Une clé protégée ne peut pas être chargée directement
+		if _, err := ParseElgamalPrivateKey(protected); err != errProtectedKey {
+			t.Error("Une clé protégée a été chargée sans phrase de passe", format)
+		}
+		if _, err := ParseElgamalPublicKey(protected); err != errProtectedKey {
+			t.Error("Une clé protégée a été chargée comme clé publique", format)
+		}
+
+		b, err := UnprotectPrivateKey(protected, passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		priv, err := ParseElgamalPrivateKey(b)
+		if err != nil || priv.X.Cmp(keys.X) != 0 {
+			t.Error("La clé déchiffrée est différente", format, err)
+		}
+
+		if _, err := UnprotectPrivateKey(protected, []byte("mauvaise phrase")); err != errWrongPassphrase {
+			t.Error("Une phrase de passe incorrecte a été acceptée", format)
+		}
+	}
+}
+
+func TestProtectedKeyTampering(t *testing.T) {
+	passphrase := []byte("phrase")
+	protected := protectKey(rand.Reader, keys.GetBytes(), passphrase, protectedKeyMinIterations)
+
+	if _, err := UnprotectPrivateKey(protected, passphrase); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modification du chiffré, du sel puis du nombre d'itérations
+	d := deserialize(protected)
+	for i := 1; i < len(d); i++ {
+		tampered := make([][]byte, len(d))
+		copy(tampered, d)
+		tampered[i] = append([]byte{}, d[i]...)
+		tampered[i][len(tampered[i])-1] ^= 1
+
+		if _, err := UnprotectPrivateKey(serialize(tampered...), passphrase); err == nil {
+			t.Error("Une clé protégée modifiée a été acceptée", i)
+		}
+	}
+
+	// Un nombre d'itérations trop faible est refusé
+	weak := protectKey(rand.Reader, keys.GetBytes(), passphrase, protectedKeyMinIterations-1)
+	if _, err := UnprotectPrivateKey(weak, passphrase); err != errInvalidProtected {
+		t.Error("Un nombre d'itérations trop faible a été accepté")
+	}
+}
+
+func TestReadPassphraseFromEnvironment(t *testing.T) {
+	t.Setenv(envPassphraseFD, "")
+	os.Unsetenv(envPassphraseFD)
+
+	envPassphraseUsed = false
+	t.Cleanup(func() { envPassphraseUsed = false })
+
+	t.Setenv(envPassphrase, "depuis l'environnement")
+	p, err := readPassphrase("", false)
+	if err != nil || string(p) != "depuis l'environnement" {
+		t.Error("La phrase de passe n'a pas été lue dans l'environnement", err)
+	}
+
+	// La même phrase n'est pas donnée silencieusement à une deuxième clé
+	if _, err := readPassphrase("", false); err != errPassphraseReused {
+		t.Error("La phrase de passe de l'environnement a été réutilisée", err)
+	}
+
+	envPassphraseUsed = false
+	t.Setenv(envPassphrase, "")
+	if _, err := readPassphrase("", false); err != errEmptyPassphrase {
+		t.Error("Une phrase de passe vide a été acceptée")
+	}
+}
+
+func TestReadPassphraseFromFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	w.WriteString("depuis un descripteur\r\nclé suivante\n")
+	w.Close()
+
+	passphraseFD.reader = nil
+	t.Cleanup(func() { passphraseFD.reader = nil })
+
+	// Le descripteur est prioritaire sur la variable d'environnement
+	t.Setenv(envPassphrase, "ignorée")
+	t.Setenv(envPassphraseFD, strconv.Itoa(int(r.Fd())))
+
+	p, err := readPassphrase("", false)
+	if err != nil || string(p) != "depuis un descripteur" {
+		t.Errorf("La phrase de passe n'a pas été lue depuis le descripteur : %q %v", p, err)
+	}
+
+	// Chaque clé lit la ligne suivante du descripteur, resté ouvert
+	p, err = readPassphrase("", false)
+	if err != nil || string(p) != "clé suivante" {
+		t.Errorf("La deuxième ligne du descripteur n'a pas été lue : %q %v", p, err)
+	}
+	if _, err := readPassphrase("", false); err == nil {
+		t.Error("Une phrase de passe a été lue après la fin du descripteur")
+	}
+
+	t.Setenv(envPassphraseFD, "abc")
+	if _, err := readPassphrase("", false); err == nil {
+		t.Error("Un descripteur invalide a été accepté")
+	}
+}
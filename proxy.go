@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Identifiants des formats du re-chiffrement par mandataire (BBS98)
+const (
+	proxyVersion      = "elgamal-pre-bbs98-v1"
+	proxyRekeyVersion = "elgamal-pre-rekey-v1"
+)
+
+// Information HKDF de la dérivation de la clé AES des messages
+const proxyKEMInfo = "gocrypto elgamal pre-bbs98 aes-256-gcm"
+
+var (
+	errProxyGroup = errors.New("gocrypto: les deux clés doivent utiliser le même groupe")
+	errProxyRekey = errors.New("gocrypto: clé de re-chiffrement invalide")
+)
+
+// ReEncryptionKey permet à un mandataire de transformer un message chiffré
+// pour la clé From en un message chiffré pour la clé To, sans pouvoir le
+// déchiffrer (schéma de Blaze, Bleumer et Strauss, 1998) : K = x_To / x_From
+// dans le sous-groupe d'ordre premier des clés.
+//
+// Le schéma est bidirectionnel : K permet aussi de transformer les
+// messages de To en messages pour From. Un mandataire de connivence avec
+// l'un des destinataires retrouve la clé privée de l'autre.
+type ReEncryptionKey struct {
+	From uint64 // identifiant de la clé d'origine
+	To   uint64 // identifiant de la clé de destination
+
+	// Paramètres du groupe commun (voir ElgamalPublicKey)
+	Q             *big.Int
+	G             *big.Int
+	SubgroupOrder *big.Int
+
+	K *big.Int
+}
+
+// Dérive la clé AES-256 et le nonce GCM de l'élément secret s = g^k
+func deriveProxyKey(p, s *big.Int) (key, nonce []byte) {
+	pLen := (p.BitLen() + 7) / 8
+
+	okm, err := hkdf.Key(sha256.New, fixedBytes(s, pLen), nil, proxyKEMInfo, 32+12)
+	if err != nil {
+		panic("gocrypto: " + err.Error())
+	}
+
+	return okm[:32], okm[32:]
+}
+
+// Indique si les clés a et b utilisent le même groupe
+func sameGroup(a, b *ElgamalPublicKey) bool {
+	if a.Q.Cmp(b.Q) != 0 || a.G.Cmp(b.G) != 0 || (a.SubgroupOrder == nil) != (b.SubgroupOrder == nil) {
+		return false
+	}
+	return a.SubgroupOrder == nil || a.SubgroupOrder.Cmp(b.SubgroupOrder) == 0
+}
+
+// GenerateReEncryptionKey calcule la clé de re-chiffrement de from vers
+// to. Les deux clés doivent utiliser le même groupe. Le calcul demande les
+// deux clés privées : il doit être fait par leurs propriétaires, ou par un
+// tiers de confiance.
+func GenerateReEncryptionKey(from, to *ElgamalPrivateKey) (*ReEncryptionKey, error) {
+	if !sameGroup(&from.ElgamalPublicKey, &to.ElgamalPublicKey) {
+		return nil, errProxyGroup
+	}
+
+	_, q, _, _ := schnorrGroup(&from.ElgamalPublicKey)
+
+	inv := new(big.Int).ModInverse(new(big.Int).Mod(from.X, q), q)
+	if inv == nil {
+		return nil, errInvalidElgamalKey
+	}
+	k := new(big.Int).Mul(to.X, inv)
+
+	return &ReEncryptionKey{
+		From:          from.KeyID(),
+		To:            to.KeyID(),
+		Q:             from.Q,
+		G:             from.G,
+		SubgroupOrder: from.SubgroupOrder,
+		K:             k.Mod(k, q),
+	}, nil
+}
+
+// ProxyEncrypt chiffre plaintext pour pub de façon à ce que le message
+// puisse être re-chiffré par un mandataire. Pour un élément aléatoire k,
+// c = h^k est publié et le message est chiffré avec AES-GCM sous une clé
+// dérivée de g^k :
+// ciphertext = version | identifiant de la clé | c | AES-GCM(message)
+// L'identifiant de la clé n'est pas authentifié puisque le mandataire le
+// remplace.
+func ProxyEncrypt(rand io.Reader, pub *ElgamalPublicKey, plaintext []byte) []byte {
+	p, q, g, h := schnorrGroup(pub)
+
+	k := randRange(rand, big1, new(big.Int).Sub(q, big1))
+	c := new(big.Int).Exp(h, k, p)
+
+	key, nonce := deriveProxyKey(p, new(big.Int).Exp(g, k, p))
+	version := []byte(proxyVersion)
+	sealed := newGCM(key).Seal(nil, nonce, plaintext, version)
+
+	return serialize(version, pub.keyIDBytes(), c.Bytes(), sealed)
+}
+
+// Renvoie les champs d'un message produit par ProxyEncrypt après avoir
+// vérifié qu'il est destiné à la clé pub
+func proxyFields(pub *ElgamalPublicKey, ciphertext []byte) (c *big.Int, sealed []byte, err error) {
+	d := deserialize(ciphertext)
+	if len(d) != 4 || string(d[0]) != proxyVersion {
+		return nil, nil, errInvalidCiphertext
+	}
+	if err := pub.checkKeyID(d[1]); err != nil {
+		return nil, nil, err
+	}
+
+	p, q, _, _ := schnorrGroup(pub)
+	c = new(big.Int).SetBytes(d[2])
+	if !isGroupElement(c, p, q) || c.Cmp(big1) == 0 {
+		return nil, nil, errInvalidCiphertext
+	}
+	return c, d[3], nil
+}
+
+// ProxyReEncrypt transforme un message chiffré pour la clé rk.From avec
+// ProxyEncrypt (ou déjà re-chiffré) en un message pour la clé rk.To :
+// c' = c^K = g^(x_To * k). Le message lui-même n'est pas modifié.
+func ProxyReEncrypt(rk *ReEncryptionKey, ciphertext []byte) ([]byte, error) {
+	d := deserialize(ciphertext)
+	if len(d) != 4 || string(d[0]) != proxyVersion || len(d[1]) != 8 {
+		return nil, errInvalidCiphertext
+	}
+	if id := binary.BigEndian.Uint64(d[1]); id != rk.From {
+		return nil, &KeyMismatchError{Expected: id, Actual: rk.From}
+	}
+
+	// Sous-groupe d'ordre premier des clés (voir schnorrGroup)
+	p, q := new(big.Int).Add(rk.Q, big1), rk.SubgroupOrder
+	if q == nil {
+		q = new(big.Int).Rsh(rk.Q, 1)
+	}
+	c := new(big.Int).SetBytes(d[2])
+	if !isGroupElement(c, p, q) || c.Cmp(big1) == 0 {
+		return nil, errInvalidCiphertext
+	}
+
+	c.Exp(c, rk.K, p)
+	to := binary.BigEndian.AppendUint64(nil, rk.To)
+	return serialize(d[0], to, c.Bytes(), d[3]), nil
+}
+
+// ProxyDecrypt déchiffre un message produit par ProxyEncrypt ou
+// ProxyReEncrypt : g^k = c^(1/x)
+func ProxyDecrypt(priv *ElgamalPrivateKey, ciphertext []byte) ([]byte, error) {
+	c, sealed, err := proxyFields(&priv.ElgamalPublicKey, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	p, q, _, _ := schnorrGroup(&priv.ElgamalPublicKey)
+	inv := new(big.Int).ModInverse(new(big.Int).Mod(priv.X, q), q)
+	if inv == nil {
+		return nil, errInvalidElgamalKey
+	}
+
+	key, nonce := deriveProxyKey(p, new(big.Int).Exp(c, inv, p))
+	plaintext, err := newGCM(key).Open(nil, nonce, sealed, []byte(proxyVersion))
+	if err != nil {
+		return nil, errDecryption
+	}
+	return plaintext, nil
+}
+
+// GetBytes renvoie sous forme d'octets la clé de re-chiffrement :
+// version | identifiant d'origine | identifiant de destination | Q | G | K
+// [ | ordre du sous-groupe ]
+func (rk *ReEncryptionKey) GetBytes() []byte {
+	fields := [][]byte{
+		[]byte(proxyRekeyVersion),
+		binary.BigEndian.AppendUint64(nil, rk.From), binary.BigEndian.AppendUint64(nil, rk.To),
+		rk.Q.Bytes(), rk.G.Bytes(), rk.K.Bytes(),
+	}
+	if rk.SubgroupOrder != nil {
+		fields = append(fields, rk.SubgroupOrder.Bytes())
+	}
+	return serialize(fields...)
+}
+
+// ParseReEncryptionKey charge une clé de re-chiffrement enregistrée avec
+// GetBytes
+func ParseReEncryptionKey(b []byte) (*ReEncryptionKey, error) {
+	d := deserialize(b)
+	if (len(d) != 6 && len(d) != 7) || string(d[0]) != proxyRekeyVersion || len(d[1]) != 8 || len(d[2]) != 8 {
+		return nil, errProxyRekey
+	}
+
+	rk := &ReEncryptionKey{
+		From: binary.BigEndian.Uint64(d[1]),
+		To:   binary.BigEndian.Uint64(d[2]),
+		Q:    new(big.Int).SetBytes(d[3]),
+		G:    new(big.Int).SetBytes(d[4]),
+		K:    new(big.Int).SetBytes(d[5]),
+	}
+	if len(d) == 7 {
+		rk.SubgroupOrder = new(big.Int).SetBytes(d[6])
+	}
+	if rk.Q.Sign() <= 0 || rk.G.Sign() <= 0 || rk.K.Sign() <= 0 || (rk.SubgroupOrder != nil && rk.SubgroupOrder.Sign() <= 0) {
+		return nil, errProxyRekey
+	}
+	return rk, nil
+}
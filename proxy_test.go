@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestProxyReEncryption(t *testing.T) {
+	for _, group := range []string{"ffdhe2048", ""} {
+		var alice, bob, carol *ElgamalPrivateKey
+		if group == "" {
+			// Clés DSA partageant les mêmes paramètres
+			params := &DSAParameters{P: mustHex(dsaVector.P), Q: mustHex(dsaVector.Q), G: mustHex(dsaVector.G)}
+			alice, bob, carol = GenerateDSAKeys(rand.Reader, params), GenerateDSAKeys(rand.Reader, params), GenerateDSAKeys(rand.Reader, params)
+		} else {
+			alice, _ = GenerateElgamalKeysInGroup(rand.Reader, group)
+			bob, _ = GenerateElgamalKeysInGroup(rand.Reader, group)
+			carol, _ = GenerateElgamalKeysInGroup(rand.Reader, group)
+		}
+
+		m := []byte("document confié au service de stockage")
+		c := ProxyEncrypt(rand.Reader, &alice.ElgamalPublicKey, m)
+		if d, err := ProxyDecrypt(alice, c); err != nil || !bytes.Equal(d, m) {
+			t.Fatal("Echec du déchiffrement par le destinataire initial", err)
+		}
+
+		ab, err := GenerateReEncryptionKey(alice, bob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ab, err = ParseReEncryptionKey(ab.GetBytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cb, err := ProxyReEncrypt(ab, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, err := ProxyDecrypt(bob, cb); err != nil || !bytes.Equal(d, m) {
+			t.Error("Echec du déchiffrement après re-chiffrement", group, err)
+		}
+
+		// Alice ne peut plus déchiffrer le message re-chiffré, et la clé
+		// de re-chiffrement ne s'applique qu'aux messages d'Alice
+		var mismatch *KeyMismatchError
+		if _, err := ProxyDecrypt(alice, cb); !errors.As(err, &mismatch) {
+			t.Error("Le message re-chiffré est accepté par l'ancienne clé", err)
+		}
+		if _, err := ProxyReEncrypt(ab, cb); !errors.As(err, &mismatch) {
+			t.Error("Un message pour une autre clé a été re-chiffré", err)
+		}
+
+		// Les re-chiffrements peuvent s'enchaîner
+		bc, err := GenerateReEncryptionKey(bob, carol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cc, err := ProxyReEncrypt(bc, cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, err := ProxyDecrypt(carol, cc); err != nil || !bytes.Equal(d, m) {
+			t.Error("Echec du déchiffrement après deux re-chiffrements", group, err)
+		}
+	}
+}
+
+func TestProxyDifferentGroups(t *testing.T) {
+	other, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	if _, err := GenerateReEncryptionKey(keys, other); err != errProxyGroup {
+		t.Error("Des clés de groupes différents ont été acceptées", err)
+	}
+}
+
+func TestProxyTampering(t *testing.T) {
+	c := ProxyEncrypt(rand.Reader, &keys.ElgamalPublicKey, []byte("message"))
+
+	d := deserialize(c)
+	d[3][0] ^= 1
+	if _, err := ProxyDecrypt(keys, serialize(d...)); err != errDecryption {
+		t.Error("Un message modifié a été accepté", err)
+	}
+
+	// c = 1 ou hors du sous-groupe
+	for _, v := range [][]byte{{1}, keys.G.Bytes()} {
+		d = deserialize(c)
+		d[2] = v
+		if _, err := ProxyDecrypt(keys, serialize(d...)); err != errInvalidCiphertext {
+			t.Error("Un chiffré hors du sous-groupe a été accepté", err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Identifiant du format de chiffrement pour plusieurs destinataires
+const elgamalMultiVersion = "elgamal-kem-multi-v1"
+
+var errNoRecipient = errors.New("gocrypto: aucun destinataire")
+
+// NotRecipientError indique que la clé fournie ne fait pas partie des
+// destinataires d'un message
+type NotRecipientError struct {
+	Recipients []uint64 // identifiants des clés des destinataires
+	Actual     uint64   // identifiant de la clé fournie
+}
+
+func (e *NotRecipientError) Error() string {
+	ids := make([]string, len(e.Recipients))
+	for i, id := range e.Recipients {
+		ids[i] = fmt.Sprintf("%016X", id)
+	}
+	return fmt.Sprintf("gocrypto: le message est chiffré pour les clés %s, la clé fournie est %016X",
+		strings.Join(ids, ", "), e.Actual)
+}
+
+// ElgamalEncryptMulti chiffre plaintext pour plusieurs destinataires. Le
+// message est chiffré une seule fois avec AES-GCM sous une clé aléatoire,
+// qui est encapsulée pour chaque destinataire avec ElGamal comme dans
+// ElgamalEncrypt :
+// ciphertext = version | emplacements | AES-GCM(message)
+// emplacement = identifiant de la clé | c1 | AES-GCM(clé du message)
+// Les emplacements sont authentifiés par le chiffrement du message. Une
+// clé présente plusieurs fois ne reçoit qu'un emplacement.
+func ElgamalEncryptMulti(rand io.Reader, pubs []*ElgamalPublicKey, plaintext []byte) ([]byte, error) {
+	if len(pubs) == 0 {
+		return nil, errNoRecipient
+	}
+
+	version := []byte(elgamalMultiVersion)
+	contentKey := readRandom(rand, 32)
+
+	var slots [][]byte
+	seen := make(map[string]bool)
+	for _, pub := range pubs {
+		fp := string(pub.Fingerprint())
+		if seen[fp] {
+			continue
+		}
+		seen[fp] = true
+
+		c1, key, nonce := elgamalEncapsulate(rand, pub)
+		keyID := pub.keyIDBytes()
+		wrapped := newGCM(key).Seal(nil, nonce, contentKey, serialize(version, keyID))
+
+		slots = append(slots, serialize(keyID, c1.Bytes(), wrapped))
+	}
+	header := serialize(slots...)
+
+	// La clé du message est aléatoire et n'est utilisée qu'une fois : le
+	// nonce peut être nul
+	nonce := make([]byte, 12)
+	sealed := newGCM(contentKey).Seal(nil, nonce, plaintext, serialize(version, header))
+
+	return serialize(version, header, sealed), nil
+}
+
+// Renvoie les emplacements (identifiant de la clé | c1 | clé du message
+// chiffrée) destinés à pub, ou une NotRecipientError si aucun ne lui est
+// destiné. Deux clés pouvant avoir le même identifiant, plusieurs
+// emplacements peuvent correspondre.
+func multiRecipientSlots(pub *ElgamalPublicKey, header []byte) ([][][]byte, error) {
+	slots := deserialize(header)
+	if len(slots) == 0 {
+		return nil, errInvalidCiphertext
+	}
+
+	keyID := pub.keyIDBytes()
+
+	var recipients []uint64
+	var matching [][][]byte
+	for _, slot := range slots {
+		s := deserialize(slot)
+		if len(s) != 3 || len(s[0]) != 8 {
+			return nil, errInvalidCiphertext
+		}
+		recipients = append(recipients, binary.BigEndian.Uint64(s[0]))
+
+		if bytes.Equal(s[0], keyID) {
+			matching = append(matching, s)
+		}
+	}
+
+	if len(matching) == 0 {
+		return nil, &NotRecipientError{Recipients: recipients, Actual: pub.KeyID()}
+	}
+	return matching, nil
+}
+
+// Déchiffre un message produit par ElgamalEncryptMulti avec l'emplacement
+// destiné à pub, la clé symétrique étant retrouvée avec decapsulate
+func elgamalDecryptMulti(pub *ElgamalPublicKey, decapsulate kemDecapsulator, version, header, sealed []byte) ([]byte, error) {
+	slots, err := multiRecipientSlots(pub, header)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range slots {
+		key, nonce, err := decapsulate(new(big.Int).SetBytes(s[1]))
+		if err != nil {
+			continue
+		}
+		contentKey, err := newGCM(key).Open(nil, nonce, s[2], serialize(version, s[0]))
+		if err != nil || len(contentKey) != 32 {
+			continue
+		}
+
+		plaintext, err := newGCM(contentKey).Open(nil, make([]byte, 12), sealed, serialize(version, header))
+		if err != nil {
+			return nil, errDecryption
+		}
+		return plaintext, nil
+	}
+	return nil, errDecryption
+}
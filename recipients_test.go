@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestMultiRecipientEncryption(t *testing.T) {
+	alice := GenerateElgamalKeys(rand.Reader, 160, 0)
+	bob := GenerateElgamalKeys(rand.Reader, 256, 0)
+	m := []byte("message chiffré pour toute l'équipe")
+
+	c, err := ElgamalEncryptMulti(rand.Reader, []*ElgamalPublicKey{&alice.ElgamalPublicKey, &bob.ElgamalPublicKey, &alice.ElgamalPublicKey}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(deserialize(deserialize(c)[1])); n != 2 {
+		t.Error("Nombre d'emplacements incorrect :", n)
+	}
+
+	for _, priv := range []*ElgamalPrivateKey{alice, bob} {
+		d, err := ElgamalDecrypt(priv, c)
+		if err != nil || !bytes.Equal(d, m) {
+			t.Error("Echec du déchiffrement par un destinataire", err)
+		}
+	}
+
+	_, err = ElgamalDecrypt(keys, c)
+	var notRecipient *NotRecipientError
+	if !errors.As(err, &notRecipient) || len(notRecipient.Recipients) != 2 || notRecipient.Actual != keys.KeyID() {
+		t.Error("Le déchiffrement par une autre clé n'a pas été signalé", err)
+	}
+
+	if _, err := ElgamalEncryptMulti(rand.Reader, nil, m); err == nil {
+		t.Error("Un chiffrement sans destinataire a été accepté")
+	}
+}
+
+func TestMultiRecipientTampering(t *testing.T) {
+	alice := GenerateElgamalKeys(rand.Reader, 160, 0)
+	bob := GenerateElgamalKeys(rand.Reader, 160, 0)
+	c, err := ElgamalEncryptMulti(rand.Reader, []*ElgamalPublicKey{&alice.ElgamalPublicKey, &bob.ElgamalPublicKey}, []byte("message"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Supprimer l'emplacement d'un destinataire modifie l'en-tête
+	// authentifié : le message est refusé par les autres destinataires
+	d := deserialize(c)
+	slots := deserialize(d[1])
+	d[1] = serialize(slots[0])
+	if _, err := ElgamalDecrypt(alice, serialize(d...)); err == nil {
+		t.Error("Un en-tête modifié a été accepté")
+	}
+
+	d = deserialize(c)
+	d[2][0] ^= 1
+	if _, err := ElgamalDecrypt(bob, serialize(d...)); err == nil {
+		t.Error("Un message modifié a été accepté")
+	}
+}
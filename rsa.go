@@ -0,0 +1,546 @@
+package main
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"math/big"
+)
+
+// Exposant public utilisé lors de la génération des clés
+const rsaPublicExponent = 65537
+
+// Taille minimale du module en bits
+const rsaMinSize = 1024
+
+// Identifiant du format de chiffrement hybride RSA-OAEP + AES-GCM
+const rsaOAEPVersion = "rsa-oaep-v1"
+
+// Nom du schéma de signature RSA enregistré dans les signatures
+const schemeRSAPSS = "rsa-pss"
+
+// Marque enregistrée à la fin des clés réservées aux signatures aveugles
+const rsaBlindKeyTag = "rsa-blind-key-v1"
+
+var (
+	errInvalidRSAKey   = errors.New("gocrypto: clé RSA invalide")
+	errMessageTooLong  = errors.New("gocrypto: message trop long pour la clé RSA")
+	errRSADecryption   = errors.New("gocrypto: échec du déchiffrement RSA")
+	errRSAKeyTooSmall  = errors.New("gocrypto: clé RSA trop petite")
+	errRSAInvalidInput = errors.New("gocrypto: entrée de taille invalide")
+	errRSABlindKey     = errors.New("gocrypto: clé RSA réservée aux signatures aveugles")
+	errRSANotBlindKey  = errors.New("gocrypto: la clé RSA n'est pas une clé de signature aveugle (voir rsa genkey -blind)")
+)
+
+// RSAPublicKey représente une clé publique RSA. Une clé de signature
+// aveugle (Blind) ne sert qu'à RSABlindSign et aux fonctions associées :
+// la signature aveugle étant une exponentiation brute, elle permettrait
+// sinon de déchiffrer les messages ou de signer un document quelconque.
+type RSAPublicKey struct {
+	N     *big.Int // N = P*Q est le module
+	E     *big.Int // E est l'exposant public
+	Blind bool     // Clé réservée aux signatures aveugles
+}
+
+// RSAPrivateKey représente une paire de clés RSA avec les paramètres du
+// théorème des restes chinois (CRT)
+type RSAPrivateKey struct {
+	RSAPublicKey
+
+	D    *big.Int // D = E⁻¹ mod lcm(P-1, Q-1)
+	P, Q *big.Int // Facteurs premiers de N
+	Dp   *big.Int // Dp = D mod (P-1)
+	Dq   *big.Int // Dq = D mod (Q-1)
+	Qinv *big.Int // Qinv = Q⁻¹ mod P
+}
+
+// Renvoie la taille du module en octets
+func (pub *RSAPublicKey) size() int {
+	return (pub.N.BitLen() + 7) / 8
+}
+
+// Ajoute aux champs de la clé la marque des clés de signature aveugle
+func (pub *RSAPublicKey) withTag(fields ...[]byte) []byte {
+	if pub.Blind {
+		fields = append(fields, []byte(rsaBlindKeyTag))
+	}
+	return serialize(fields...)
+}
+
+// Retire des champs v la marque des clés de signature aveugle. Renvoie
+// ok = false si le dernier champ supplémentaire n'est pas la marque.
+func rsaKeyTag(v [][]byte, n int) (fields [][]byte, blind, ok bool) {
+	switch {
+	case len(v) == n:
+		return v, false, true
+	case len(v) == n+1 && string(v[n]) == rsaBlindKeyTag:
+		return v[:n], true, true
+	default:
+		return nil, false, false
+	}
+}
+
+// GetBytes renvoie sous forme d'octets la clé publique : N | E, suivis de
+// la marque rsa-blind-key-v1 pour une clé de signature aveugle
+func (pub *RSAPublicKey) GetBytes() []byte {
+	return pub.withTag(pub.N.Bytes(), pub.E.Bytes())
+}
+
+// GetBytes renvoie sous forme d'octets la clé privée :
+// N | E | D | P | Q | Dp | Dq | Qinv, suivis de la marque rsa-blind-key-v1
+// pour une clé de signature aveugle
+func (priv *RSAPrivateKey) GetBytes() []byte {
+	return priv.withTag(priv.N.Bytes(), priv.E.Bytes(), priv.D.Bytes(),
+		priv.P.Bytes(), priv.Q.Bytes(), priv.Dp.Bytes(), priv.Dq.Bytes(), priv.Qinv.Bytes())
+}
+
+// LoadRSAPublicKey charge une clé publique écrite avec GetBytes
+func LoadRSAPublicKey(b []byte) (*RSAPublicKey, error) {
+	v, blind, ok := rsaKeyTag(deserialize(b), 2)
+	if !ok {
+		return nil, errInvalidRSAKey
+	}
+
+	pub := &RSAPublicKey{
+		N:     new(big.Int).SetBytes(v[0]),
+		E:     new(big.Int).SetBytes(v[1]),
+		Blind: blind,
+	}
+	if pub.N.Sign() == 0 || pub.E.Cmp(big3) < 0 || pub.E.Bit(0) == 0 {
+		return nil, errInvalidRSAKey
+	}
+	return pub, nil
+}
+
+// LoadRSAPrivateKey charge une clé privée écrite avec GetBytes
+func LoadRSAPrivateKey(b []byte) (*RSAPrivateKey, error) {
+	v, blind, ok := rsaKeyTag(deserialize(b), 8)
+	if !ok {
+		return nil, errInvalidRSAKey
+	}
+
+	n := make([]*big.Int, len(v))
+	for i := range v {
+		n[i] = new(big.Int).SetBytes(v[i])
+	}
+
+	priv := &RSAPrivateKey{
+		RSAPublicKey: RSAPublicKey{N: n[0], E: n[1], Blind: blind},
+		D:            n[2],
+		P:            n[3],
+		Q:            n[4],
+		Dp:           n[5],
+		Dq:           n[6],
+		Qinv:         n[7],
+	}
+
+	// Les facteurs doivent correspondre au module
+	if new(big.Int).Mul(priv.P, priv.Q).Cmp(priv.N) != 0 {
+		return nil, errInvalidRSAKey
+	}
+	return priv, nil
+}
+
+// Renvoie un nombre premier de bits bits dont les deux bits de poids fort
+// sont à 1 (le produit de deux tels nombres a exactement 2*bits bits) et
+// tel que p-1 soit premier avec e
+func generateRSAPrime(random io.Reader, bits int, e *big.Int) *big.Int {
+	var (
+		r       = new(big.Int)
+		pMinus1 = new(big.Int)
+		nBytes  = (bits + 7) / 8
+	)
+
+next:
+	for {
+		p := randomBigInt(random, nBytes)
+		for i := bits; i < nBytes*8; i++ {
+			p.SetBit(p, i, 0)
+		}
+		p.SetBit(p, bits-1, 1)
+		p.SetBit(p, bits-2, 1)
+		p.SetBit(p, 0, 1)
+
+		// Élimine rapidement les candidats ayant un petit facteur
+		for _, q := range smallPrimes {
+			if r.Mod(p, r.SetUint64(q)).Sign() == 0 {
+				continue next
+			}
+		}
+
+		if new(big.Int).GCD(nil, nil, pMinus1.Sub(p, big1), e).Cmp(big1) != 0 {
+			continue
+		}
+
+		if probablyPrime(p, 25) {
+			return p
+		}
+	}
+}
+
+// GenerateRSAKeys génère une paire de clés RSA de size bits avec
+// l'exposant public 65537
+func GenerateRSAKeys(rand io.Reader, size int) (*RSAPrivateKey, error) {
+	if size < rsaMinSize {
+		return nil, errRSAKeyTooSmall
+	}
+
+	e := big.NewInt(rsaPublicExponent)
+
+	for {
+		p := generateRSAPrime(rand, size-size/2, e)
+		q := generateRSAPrime(rand, size/2, e)
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		// On impose P > Q, comme dans PKCS #1
+		if p.Cmp(q) < 0 {
+			p, q = q, p
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != size {
+			continue
+		}
+
+		pMinus1 := new(big.Int).Sub(p, big1)
+		qMinus1 := new(big.Int).Sub(q, big1)
+
+		// lambda = lcm(p-1, q-1)
+		gcd := new(big.Int).GCD(nil, nil, pMinus1, qMinus1)
+		lambda := new(big.Int).Mul(pMinus1, qMinus1)
+		lambda.Div(lambda, gcd)
+
+		d := new(big.Int).ModInverse(e, lambda)
+		if d == nil {
+			continue
+		}
+
+		return &RSAPrivateKey{
+			RSAPublicKey: RSAPublicKey{N: n, E: e},
+			D:            d,
+			P:            p,
+			Q:            q,
+			Dp:           new(big.Int).Mod(d, pMinus1),
+			Dq:           new(big.Int).Mod(d, qMinus1),
+			Qinv:         new(big.Int).ModInverse(q, p),
+		}, nil
+	}
+}
+
+// Calcule c^D mod N, avec le théorème des restes chinois si les
+// paramètres CRT sont connus
+func (priv *RSAPrivateKey) decryptInt(c *big.Int) *big.Int {
+	if priv.P == nil || priv.Dp == nil {
+		return new(big.Int).Exp(c, priv.D, priv.N)
+	}
+
+	// m1 = c^Dp mod P, m2 = c^Dq mod Q
+	m1 := new(big.Int).Exp(c, priv.Dp, priv.P)
+	m2 := new(big.Int).Exp(c, priv.Dq, priv.Q)
+
+	// h = Qinv * (m1 - m2) mod P, m = m2 + h*Q
+	h := m1.Sub(m1, m2)
+	h.Mul(h, priv.Qinv)
+	h.Mod(h, priv.P)
+	h.Mul(h, priv.Q)
+
+	return h.Add(h, m2)
+}
+
+// Fonction de génération de masque MGF1 (PKCS #1, B.2.1)
+func mgf1(h crypto.Hash, seed []byte, length int) []byte {
+	out := make([]byte, 0, length+h.Size())
+	counter := make([]byte, 4)
+
+	for i := uint32(0); len(out) < length; i++ {
+		counter[0], counter[1], counter[2], counter[3] = byte(i>>24), byte(i>>16), byte(i>>8), byte(i)
+
+		d := h.New()
+		d.Write(seed)
+		d.Write(counter)
+		out = d.Sum(out)
+	}
+
+	return out[:length]
+}
+
+// Applique un XOR de mask sur b
+func xorBytes(b, mask []byte) {
+	for i := range b {
+		b[i] ^= mask[i]
+	}
+}
+
+// RSAEncryptOAEP chiffre un message court avec RSAES-OAEP (PKCS #1 v2.2,
+// 7.1.1) en utilisant h pour le hachage du label et pour MGF1
+func RSAEncryptOAEP(rand io.Reader, pub *RSAPublicKey, h crypto.Hash, msg, label []byte) ([]byte, error) {
+	if pub.Blind {
+		return nil, errRSABlindKey
+	}
+
+	k, hLen := pub.size(), h.Size()
+	if len(msg) > k-2*hLen-2 {
+		return nil, errMessageTooLong
+	}
+
+	// EM = 0x00 | maskedSeed | maskedDB
+	em := make([]byte, k)
+	seed, db := em[1:1+hLen], em[1+hLen:]
+
+	// DB = lHash | PS | 0x01 | M
+	copy(db, hash(h, label))
+	db[len(db)-len(msg)-1] = 0x01
+	copy(db[len(db)-len(msg):], msg)
+
+	copy(seed, readRandom(rand, hLen))
+
+	xorBytes(db, mgf1(h, seed, len(db)))
+	xorBytes(seed, mgf1(h, db, hLen))
+
+	c := new(big.Int).Exp(new(big.Int).SetBytes(em), pub.E, pub.N)
+	return fixedBytes(c, k), nil
+}
+
+// RSADecryptOAEP déchiffre un message chiffré avec RSAEncryptOAEP
+// (PKCS #1 v2.2, 7.1.2)
+func RSADecryptOAEP(priv *RSAPrivateKey, h crypto.Hash, ciphertext, label []byte) ([]byte, error) {
+	if priv.Blind {
+		return nil, errRSABlindKey
+	}
+
+	k, hLen := priv.size(), h.Size()
+	if len(ciphertext) != k || k < 2*hLen+2 {
+		return nil, errRSADecryption
+	}
+
+	c := new(big.Int).SetBytes(ciphertext)
+	if c.Cmp(priv.N) >= 0 {
+		return nil, errRSADecryption
+	}
+
+	em := fixedBytes(priv.decryptInt(c), k)
+	seed, db := em[1:1+hLen], em[1+hLen:]
+
+	xorBytes(seed, mgf1(h, db, hLen))
+	xorBytes(db, mgf1(h, seed, len(db)))
+
+	// Les vérifications sont faites sans branchement pour ne pas
+	// révéler laquelle a échoué
+	good := subtle.ConstantTimeByteEq(em[0], 0)
+	good &= subtle.ConstantTimeCompare(db[:hLen], hash(h, label))
+
+	// Recherche du 0x01 qui suit le bourrage PS
+	lookingForIndex, index, invalid := 1, 0, 0
+	rest := db[hLen:]
+	for i := range rest {
+		equals0 := subtle.ConstantTimeByteEq(rest[i], 0)
+		equals1 := subtle.ConstantTimeByteEq(rest[i], 1)
+		index = subtle.ConstantTimeSelect(lookingForIndex&equals1, i, index)
+		lookingForIndex = subtle.ConstantTimeSelect(equals1, 0, lookingForIndex)
+		invalid = subtle.ConstantTimeSelect(lookingForIndex&^equals0, 1, invalid)
+	}
+
+	if good&^invalid&^lookingForIndex != 1 {
+		return nil, errRSADecryption
+	}
+
+	return rest[index+1:], nil
+}
+
+// Encode l'empreinte mHash avec EMSA-PSS (PKCS #1 v2.2, 9.1.1) sur emBits
+// bits avec un sel de la taille de l'empreinte
+func emsaPSSEncode(h crypto.Hash, mHash, salt []byte, emBits int) ([]byte, error) {
+	hLen, sLen := h.Size(), len(salt)
+	emLen := (emBits + 7) / 8
+	if emLen < hLen+sLen+2 {
+		return nil, errRSAKeyTooSmall
+	}
+
+	// H = Hash(0x00 * 8 | mHash | salt)
+	d := h.New()
+	d.Write(make([]byte, 8))
+	d.Write(mHash)
+	d.Write(salt)
+	H := d.Sum(nil)
+
+	// EM = maskedDB | H | 0xbc avec DB = PS | 0x01 | salt
+	em := make([]byte, emLen)
+	db := em[:emLen-hLen-1]
+	db[len(db)-sLen-1] = 0x01
+	copy(db[len(db)-sLen:], salt)
+	copy(em[emLen-hLen-1:], H)
+	em[emLen-1] = 0xbc
+
+	xorBytes(db, mgf1(h, H, len(db)))
+
+	// Met à zéro les bits de poids fort en trop
+	db[0] &= 0xff >> uint(8*emLen-emBits)
+
+	return em, nil
+}
+
+// Vérifie l'encodage EMSA-PSS em de l'empreinte mHash (PKCS #1 v2.2, 9.1.2)
+func emsaPSSVerify(h crypto.Hash, mHash, em []byte, emBits, sLen int) bool {
+	hLen := h.Size()
+	emLen := (emBits + 7) / 8
+	if len(em) != emLen || emLen < hLen+sLen+2 || em[emLen-1] != 0xbc {
+		return false
+	}
+
+	db := append([]byte{}, em[:emLen-hLen-1]...)
+	H := em[emLen-hLen-1 : emLen-1]
+
+	// Les bits de poids fort en trop doivent être nuls
+	topMask := byte(0xff >> uint(8*emLen-emBits))
+	if db[0]&^topMask != 0 {
+		return false
+	}
+
+	xorBytes(db, mgf1(h, H, len(db)))
+	db[0] &= topMask
+
+	// DB = PS (nuls) | 0x01 | salt
+	psLen := emLen - hLen - sLen - 2
+	for _, b := range db[:psLen] {
+		if b != 0 {
+			return false
+		}
+	}
+	if db[psLen] != 0x01 {
+		return false
+	}
+	salt := db[len(db)-sLen:]
+
+	d := h.New()
+	d.Write(make([]byte, 8))
+	d.Write(mHash)
+	d.Write(salt)
+
+	return subtle.ConstantTimeCompare(d.Sum(nil), H) == 1
+}
+
+// RSASignPSS signe l'empreinte digest (calculée avec h) avec RSASSA-PSS,
+// avec un sel de la taille de l'empreinte
+func RSASignPSS(rand io.Reader, priv *RSAPrivateKey, h crypto.Hash, digest []byte) ([]byte, error) {
+	if priv.Blind {
+		return nil, errRSABlindKey
+	}
+	if len(digest) != h.Size() {
+		return nil, errRSAInvalidInput
+	}
+
+	salt := readRandom(rand, h.Size())
+
+	em, err := emsaPSSEncode(h, digest, salt, priv.N.BitLen()-1)
+	if err != nil {
+		return nil, err
+	}
+
+	s := priv.decryptInt(new(big.Int).SetBytes(em))
+
+	// Vérifie le résultat pour ne pas divulguer une signature fausse
+	// (qui permettrait de factoriser N en cas d'erreur dans le CRT)
+	if new(big.Int).Exp(s, priv.E, priv.N).Cmp(new(big.Int).SetBytes(em)) != 0 {
+		return nil, errInvalidRSAKey
+	}
+
+	return fixedBytes(s, priv.size()), nil
+}
+
+// RSAVerifyPSS vérifie une signature produite par RSASignPSS
+func RSAVerifyPSS(pub *RSAPublicKey, h crypto.Hash, digest, signature []byte) bool {
+	if len(signature) != pub.size() || len(digest) != h.Size() {
+		return false
+	}
+
+	s := new(big.Int).SetBytes(signature)
+	if s.Cmp(pub.N) >= 0 {
+		return false
+	}
+
+	emBits := pub.N.BitLen() - 1
+	m := new(big.Int).Exp(s, pub.E, pub.N)
+	if m.BitLen() > emBits {
+		return false
+	}
+
+	return emsaPSSVerify(h, digest, fixedBytes(m, (emBits+7)/8), emBits, h.Size())
+}
+
+// RSAEncrypt chiffre les messages d'une taille quelconque. Une clé AES
+// aléatoire est chiffrée avec RSA-OAEP (SHA-256) puis le message est
+// chiffré avec AES-GCM :
+// ciphertext = version | OAEP(clé) | AES-GCM(message)
+func RSAEncrypt(rand io.Reader, pub *RSAPublicKey, plaintext []byte) ([]byte, error) {
+	version := []byte(rsaOAEPVersion)
+	key := readRandom(rand, 32)
+
+	wrapped, err := RSAEncryptOAEP(rand, pub, crypto.SHA256, key, version)
+	if err != nil {
+		return nil, err
+	}
+
+	// La clé n'est utilisée qu'une fois, le nonce peut être nul
+	sealed := newGCM(key).Seal(nil, make([]byte, 12), plaintext, version)
+
+	return serialize(version, wrapped, sealed), nil
+}
+
+// RSADecrypt déchiffre les messages chiffrés avec RSAEncrypt
+func RSADecrypt(priv *RSAPrivateKey, ciphertext []byte) ([]byte, error) {
+	d := deserialize(ciphertext)
+	if len(d) != 3 || string(d[0]) != rsaOAEPVersion {
+		return nil, errInvalidCiphertext
+	}
+
+	key, err := RSADecryptOAEP(priv, crypto.SHA256, d[1], d[0])
+	if err == errRSABlindKey {
+		return nil, err
+	}
+	if err != nil || len(key) != 32 {
+		return nil, errDecryption
+	}
+
+	plaintext, err := newGCM(key).Open(nil, make([]byte, 12), d[2], d[0])
+	if err != nil {
+		return nil, errDecryption
+	}
+	return plaintext, nil
+}
+
+// RSASign signe le document "data" avec RSASSA-PSS et concataine la
+// signature au document.
+func RSASign(rand io.Reader, priv *RSAPrivateKey, data []byte, h crypto.Hash) (signedData []byte, err error) {
+	s, err := RSASignPSS(rand, priv, h, hash(h, data))
+	if err != nil {
+		return nil, err
+	}
+
+	signature := serialize([]byte(schemeRSAPSS), []byte(hashName(h)), s)
+	return serialize(data, signature), nil
+}
+
+// RSACheck vérifie que la signature du document est bien valide. Les
+// clés de signature aveugle sont refusées : n'importe qui peut obtenir une
+// signature aveugle d'un document de son choix.
+func RSACheck(pub *RSAPublicKey, signedData []byte) bool {
+	d := deserialize(signedData)
+	if len(d) != 2 || pub.Blind {
+		return false
+	}
+	data := d[0]
+
+	s := deserialize(d[1])
+	if len(s) != 3 || string(s[0]) != schemeRSAPSS {
+		return false
+	}
+	h, ok := signatureHashes[string(s[1])]
+	if !ok {
+		return false
+	}
+
+	return RSAVerifyPSS(pub, h, hash(h, data), s[2])
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	stdrsa "crypto/rsa"
+	_ "crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// Vecteur RSAES-OAEP (SHA-1), exemple 1.1 de "Test vectors for RSA-OAEP"
+// de RSA Laboratories
+var rsaOAEPVector = struct {
+	N, D            string
+	Msg, Seed, Ciph string
+}{
+	N: "a8b3b284af8eb50b387034a860f146c4919f318763cd6c5598c8ae4811a1e0ab" +
+		"c4c7e0b082d693a5e7fced675cf4668512772c0cbc64a742c6c630f533c8cc72" +
+		"f62ae833c40bf25842e984bb78bdbf97c0107d55bdb662f5c4e0fab9845cb514" +
+		"8ef7392dd3aaff93ae1e6b667bb3d4247616d4f5ba10d4cfd226de88d39f16fb",
+	D: "53339cfdb79fc8466a655c7316aca85c55fd8f6dd898fdaf119517ef4f52e8fd" +
+		"8e258df93fee180fa0e4ab29693cd83b152a553d4ac4d1812b8b9fa5af0e7f55" +
+		"fe7304df41570926f3311f15c4d65a732c483116ee3d3d2d0af3549ad9bf7cbf" +
+		"b78ad884f84d5beb04724dc7369b31def37d0cf539e9cfcdd3de653729ead5d1",
+	Msg:  "6628194e12073db03ba94cda9ef9532397d50dba79b987004afefe34",
+	Seed: "18b776ea21069d69776a33e96bad48e1dda0a5ef",
+	Ciph: "354fe67b4a126d5d35fe36c777791a3f7ba13def484e2d3908aff722fad468fb" +
+		"21696de95d0be911c2d3174f8afcc201035f7b6d8e69402de5451618c21a535f" +
+		"a9d7bfc5b8dd9fc243f8cf927db31322d6e881eaa91a996170e657a05a266426" +
+		"d98c88003f8477c1227094a0d9fa1e8c4024309ce1ecccb5210035d47ac72e8a",
+}
+
+// Vecteurs RSASSA-PSS (SHA-1, sel de 20 octets), exemples 1.1 et 2.1 de
+// "Test vectors for RSASSA-PSS" de RSA Laboratories. La clé de l'exemple 2
+// fait 1025 bits, ce qui teste le cas où emLen < k.
+var rsaPSSVectors = []struct {
+	N, D           string
+	Msg, Salt, Sig string
+}{
+	{
+		N: "a56e4a0e701017589a5187dc7ea841d156f2ec0e36ad52a44dfeb1e61f7ad991" +
+			"d8c51056ffedb162b4c0f283a12a88a394dff526ab7291cbb307ceabfce0b1df" +
+			"d5cd9508096d5b2b8b6df5d671ef6377c0921cb23c270a70e2598e6ff89d19f1" +
+			"05acc2d3f0cb35f29280e1386b6f64c4ef22e1e1f20d0ce8cffb2249bd9a2137",
+		D: "33a5042a90b27d4f5451ca9bbbd0b44771a101af884340aef9885f2a4bbe92e8" +
+			"94a724ac3c568c8f97853ad07c0266c8c6a3ca0929f1e8f11231884429fc4d9a" +
+			"e55fee896a10ce707c3ed7e734e44727a39574501a532683109c2abacaba283c" +
+			"31b4bd2f53c3ee37e352cee34f9e503bd80c0622ad79c6dcee883547c6a3b325",
+		Msg: "cdc87da223d786df3b45e0bbbc721326d1ee2af806cc315475cc6f0d9c66e1b6" +
+			"2371d45ce2392e1ac92844c310102f156a0d8d52c1f4c40ba3aa65095786cb76" +
+			"9757a6563ba958fed0bcc984e8b517a3d5f515b23b8a41e74aa867693f90dfb0" +
+			"61a6e86dfaaee64472c00e5f20945729cbebe77f06ce78e08f4098fba41f9d61" +
+			"93c0317e8b60d4b6084acb42d29e3808a3bc372d85e331170fcbf7cc72d0b71c" +
+			"296648b3a4d10f416295d0807aa625cab2744fd9ea8fd223c42537029828bd16" +
+			"be02546f130fd2e33b936d2676e08aed1b73318b750a0167d0",
+		Salt: "dee959c7e06411361420ff80185ed57f3e6776af",
+		Sig: "9074308fb598e9701b2294388e52f971faac2b60a5145af185df5287b5ed2887" +
+			"e57ce7fd44dc8634e407c8e0e4360bc226f3ec227f9d9e54638e8d31f5051215" +
+			"df6ebb9c2f9579aa77598a38f914b5b9c1bd83c4e2f9f382a0d0aa3542ffee65" +
+			"984a601bc69eb28deb27dca12c82c2d4c3f66cd500f1ff2b994d8a4e30cbb33c",
+	},
+	{
+		N: "01d40c1bcf97a68ae7cdbd8a7bf3e34fa19dcca4ef75a47454375f94514d88fe" +
+			"d006fb829f8419ff87d6315da68a1ff3a0938e9abb3464011c303ad99199cf0c" +
+			"7c7a8b477dce829e8844f625b115e5e9c4a59cf8f8113b6834336a2fd2689b47" +
+			"2cbb5e5cabe674350c59b6c17e176874fb42f8fc3d176a017edc61fd326c4b33" +
+			"c9",
+		D: "027d147e4673057377fd1ea201565772176a7dc38358d376045685a2e787c23c" +
+			"15576bc16b9f444402d6bfc5d98a3e88ea13ef67c353eca0c0ddba9255bd7b8b" +
+			"b50a644afdfd1dd51695b252d22e7318d1b6687a1c10ff75545f3db0fe602d5f" +
+			"2b7f294e3601eab7b9d1cecd767f64692e3e536ca2846cb0c2dd486a39fa75b1",
+		Msg: "daba032066263faedb659848115278a52c44faa3a76f37515ed336321072c40a" +
+			"9d9b53bc05014078adf520875146aae70ff060226dcb7b1f1fc27e9360",
+		Salt: "57bf160bcb02bb1dc7280cf0458530b7d2832ff7",
+		Sig: "014c5ba5338328ccc6e7a90bf1c0ab3fd606ff4796d3c12e4b639ed9136a5fec" +
+			"6c16d8884bdd99cfdc521456b0742b736868cf90de099adb8d5ffd1deff39ba4" +
+			"007ab746cefdb22d7df0e225f54627dc65466131721b90af445363a8358b9f60" +
+			"7642f78fab0ab0f43b7168d64bae70d8827848d8ef1e421c5754ddf42c2589b5" +
+			"b3",
+	},
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// Construit une clé sans paramètres CRT à partir de N et D
+func rsaVectorKey(n, d string) *RSAPrivateKey {
+	return &RSAPrivateKey{
+		RSAPublicKey: RSAPublicKey{N: mustHex(n), E: big.NewInt(rsaPublicExponent)},
+		D:            mustHex(d),
+	}
+}
+
+var rsaKey, _ = GenerateRSAKeys(rand.Reader, 2048)
+
+// Convertit la clé au format de crypto/rsa pour les vérifications croisées
+func stdRSAKey(priv *RSAPrivateKey) *stdrsa.PrivateKey {
+	k := &stdrsa.PrivateKey{
+		PublicKey: stdrsa.PublicKey{N: priv.N, E: int(priv.E.Int64())},
+		D:         priv.D,
+		Primes:    []*big.Int{priv.P, priv.Q},
+	}
+	k.Precompute()
+	return k
+}
+
+func TestRSAOAEPVector(t *testing.T) {
+	v := rsaOAEPVector
+	priv := rsaVectorKey(v.N, v.D)
+
+	c, err := RSAEncryptOAEP(bytes.NewReader(mustDecodeHex(v.Seed)), &priv.RSAPublicKey, crypto.SHA1, mustDecodeHex(v.Msg), nil)
+	if err != nil || !bytes.Equal(c, mustDecodeHex(v.Ciph)) {
+		t.Error("Le chiffrement OAEP ne correspond pas au vecteur de test")
+	}
+
+	m, err := RSADecryptOAEP(priv, crypto.SHA1, mustDecodeHex(v.Ciph), nil)
+	if err != nil || !bytes.Equal(m, mustDecodeHex(v.Msg)) {
+		t.Error("Le déchiffrement OAEP ne correspond pas au vecteur de test")
+	}
+}
+
+func TestRSAPSSVectors(t *testing.T) {
+	for i, v := range rsaPSSVectors {
+		priv := rsaVectorKey(v.N, v.D)
+		digest := hash(crypto.SHA1, mustDecodeHex(v.Msg))
+
+		s, err := RSASignPSS(bytes.NewReader(mustDecodeHex(v.Salt)), priv, crypto.SHA1, digest)
+		if err != nil || !bytes.Equal(s, mustDecodeHex(v.Sig)) {
+			t.Error("La signature PSS ne correspond pas au vecteur de test", i)
+		}
+
+		if !RSAVerifyPSS(&priv.RSAPublicKey, crypto.SHA1, digest, mustDecodeHex(v.Sig)) {
+			t.Error("La signature PSS du vecteur de test est refusée", i)
+		}
+	}
+}
+
+func TestRSAKeyGeneration(t *testing.T) {
+	if rsaKey.N.BitLen() != 2048 || rsaKey.E.Int64() != rsaPublicExponent {
+		t.Fatal("La clé RSA n'a pas la bonne taille")
+	}
+
+	if err := stdRSAKey(rsaKey).Validate(); err != nil {
+		t.Error("crypto/rsa refuse la clé générée :", err)
+	}
+
+	std := stdRSAKey(rsaKey)
+	if std.Precomputed.Dp.Cmp(rsaKey.Dp) != 0 || std.Precomputed.Dq.Cmp(rsaKey.Dq) != 0 || std.Precomputed.Qinv.Cmp(rsaKey.Qinv) != 0 {
+		t.Error("Les paramètres CRT diffèrent de ceux de crypto/rsa")
+	}
+
+	tpriv, err := LoadRSAPrivateKey(rsaKey.GetBytes())
+	if err != nil || tpriv.D.Cmp(rsaKey.D) != 0 || tpriv.Qinv.Cmp(rsaKey.Qinv) != 0 {
+		t.Error("Les deux clés privées ne sont pas égales.")
+	}
+
+	tpub, err := LoadRSAPublicKey(rsaKey.RSAPublicKey.GetBytes())
+	if err != nil || tpub.N.Cmp(rsaKey.N) != 0 || tpub.E.Cmp(rsaKey.E) != 0 {
+		t.Error("Les deux clés publiques ne sont pas égales.")
+	}
+}
+
+func TestRSAOAEPCrossCheck(t *testing.T) {
+	std := stdRSAKey(rsaKey)
+	msg := []byte("message pour crypto/rsa")
+	label := []byte("label")
+
+	c, _ := RSAEncryptOAEP(rand.Reader, &rsaKey.RSAPublicKey, crypto.SHA256, msg, label)
+	m, err := stdrsa.DecryptOAEP(sha256.New(), nil, std, c, label)
+	if err != nil || !bytes.Equal(m, msg) {
+		t.Error("crypto/rsa ne déchiffre pas le message OAEP")
+	}
+
+	c, _ = stdrsa.EncryptOAEP(sha256.New(), rand.Reader, &std.PublicKey, msg, label)
+	m, err = RSADecryptOAEP(rsaKey, crypto.SHA256, c, label)
+	if err != nil || !bytes.Equal(m, msg) {
+		t.Error("Le message OAEP de crypto/rsa n'est pas déchiffré")
+	}
+
+	if _, err := RSADecryptOAEP(rsaKey, crypto.SHA256, c, []byte("autre")); err == nil {
+		t.Error("Un message OAEP a été déchiffré avec un mauvais label")
+	}
+}
+
+func TestRSAPSSCrossCheck(t *testing.T) {
+	std := stdRSAKey(rsaKey)
+	digest := hash(crypto.SHA256, []byte("document"))
+	opts := &stdrsa.PSSOptions{SaltLength: stdrsa.PSSSaltLengthEqualsHash}
+
+	s, _ := RSASignPSS(rand.Reader, rsaKey, crypto.SHA256, digest)
+	if err := stdrsa.VerifyPSS(&std.PublicKey, crypto.SHA256, digest, s, opts); err != nil {
+		t.Error("crypto/rsa refuse la signature PSS :", err)
+	}
+
+	s, _ = stdrsa.SignPSS(rand.Reader, std, crypto.SHA256, digest, opts)
+	if !RSAVerifyPSS(&rsaKey.RSAPublicKey, crypto.SHA256, digest, s) {
+		t.Error("La signature PSS de crypto/rsa est refusée")
+	}
+
+	s[len(s)-1] ^= 1
+	if RSAVerifyPSS(&rsaKey.RSAPublicKey, crypto.SHA256, digest, s) {
+		t.Error("Une signature PSS modifiée a été acceptée")
+	}
+}
+
+func TestRSAHybridEncryption(t *testing.T) {
+	m1 := randomBytes(10000)
+
+	c, err := RSAEncrypt(rand.Reader, &rsaKey.RSAPublicKey, m1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m2, err := RSADecrypt(rsaKey, c)
+	if err != nil || !bytes.Equal(m1, m2) {
+		t.Error("Le chiffrement/déchiffrement RSA a échoué")
+	}
+}
+
+func TestRSASignature(t *testing.T) {
+	data := randomBytes(1000)
+
+	for name, h := range signatureHashes {
+		signedData, err := RSASign(rand.Reader, rsaKey, data, h)
+		if err != nil || !RSACheck(&rsaKey.RSAPublicKey, signedData) {
+			t.Error("Echec de la vérification de la signature RSA avec", name)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+	"math/big"
+)
+
+// Nom du schéma de Schnorr. Les signatures produites enregistrent
+// schnorr-v2, dont le défi porte sur l'empreinte du message ; les
+// signatures schnorr, dont le défi porte sur le message lui-même, sont
+// toujours vérifiées.
+const (
+	schemeSchnorr   = "schnorr"
+	schemeSchnorrV2 = "schnorr-v2"
+)
+
+// Renvoie les paramètres du sous-groupe d'ordre premier utilisé par les
+// signatures de Schnorr : son ordre q, son générateur g et y = g^x.
+// Pour les clés DSA, il s'agit du sous-groupe de la clé. Pour les clés
+// ElGamal (p = 2q + 1 sûr), on se place dans le sous-groupe des carrés,
+// engendré par G^2, où la clé publique devient H^2.
+func schnorrGroup(pub *ElgamalPublicKey) (p, q, g, y *big.Int) {
+	p = new(big.Int).Add(pub.Q, big1)
+
+	if pub.SubgroupOrder != nil {
+		return p, pub.SubgroupOrder, pub.G, pub.H
+	}
+
+	q = new(big.Int).Rsh(pub.Q, 1)
+	g = new(big.Int).Exp(pub.G, big2, p)
+	y = new(big.Int).Exp(pub.H, big2, p)
+	return p, q, g, y
+}
+
+// Calcule le défi e = H(R | y | message) mod q en lisant le message
+// depuis msg. R et y sont encodés sur la taille de p pour que l'encodage
+// soit non ambigu.
+func schnorrChallenge(h crypto.Hash, p, q, R, y *big.Int, msg io.Reader) (*big.Int, error) {
+	pLen := (p.BitLen() + 7) / 8
+
+	d := h.New()
+	d.Write(fixedBytes(R, pLen))
+	d.Write(fixedBytes(y, pLen))
+	if _, err := io.Copy(d, msg); err != nil {
+		return nil, err
+	}
+
+	return hashToInt(d.Sum(nil), q), nil
+}
+
+// Renvoie le message sur lequel porte le défi d'une signature du schéma
+// scheme : le message lu depuis msg pour schnorr, son empreinte pour
+// schnorr-v2
+func schnorrChallengeInput(scheme string, h crypto.Hash, msg io.Reader) (io.Reader, error) {
+	if scheme != schemeSchnorrV2 {
+		return msg, nil
+	}
+	digest, err := hashReader(h, msg)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(digest), nil
+}
+
+// Signe le message lu depuis msg avec le schéma de Schnorr (schnorr-v2).
+// Le message n'est lu qu'une fois : le nonce est dérivé de x et de son
+// empreinte (RFC 6979) et le défi e = H(R | y | empreinte) porte sur la
+// même empreinte. Relire le message permettrait, s'il change entre les
+// deux lectures, d'obtenir deux défis pour le même nonce et d'en déduire x.
+func schnorrSign(rand io.Reader, priv *ElgamalPrivateKey, msg io.Reader, h crypto.Hash) (signature []byte, err error) {
+	p, q, g, y := schnorrGroup(&priv.ElgamalPublicKey)
+
+	digest, err := hashReader(h, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// k entre 1 et (q-1)
+	k := newNonceGenerator(rand, h, q, priv.X, digest).next()
+
+	// R = g^k  (mod p)
+	R := new(big.Int).Exp(g, k, p)
+
+	// e = H(R | y | H(message))  (mod q)
+	e, err := schnorrChallenge(h, p, q, R, y, bytes.NewReader(digest))
+	if err != nil {
+		return nil, err
+	}
+
+	// s = k + e*x  (mod q)
+	s := new(big.Int).Mul(e, priv.X)
+	s.Add(s, k)
+	s.Mod(s, q)
+
+	return encodeSignature(schemeSchnorrV2, h, &priv.ElgamalPublicKey, R, s), nil
+}
+
+// Vérifie la signature de Schnorr (R, s) du schéma scheme (schnorr ou
+// schnorr-v2) du message lu depuis msg
+func schnorrCheck(pub *ElgamalPublicKey, msg io.Reader, h crypto.Hash, scheme string, R, s *big.Int) (bool, error) {
+	p, q, g, y := schnorrGroup(pub)
+
+	// On doit avoir 0 < R < p et 0 <= s < q
+	if R.Sign() <= 0 || R.Cmp(p) >= 0 || s.Cmp(q) >= 0 {
+		return false, nil
+	}
+
+	// R doit appartenir au sous-groupe d'ordre q
+	if new(big.Int).Exp(R, q, p).Cmp(big1) != 0 {
+		return false, nil
+	}
+
+	input, err := schnorrChallengeInput(scheme, h, msg)
+	if err != nil {
+		return false, err
+	}
+	e, err := schnorrChallenge(h, p, q, R, y, input)
+	if err != nil {
+		return false, err
+	}
+
+	// On vérifie que g^s = R * y^e  (mod p)
+	a := new(big.Int).Exp(g, s, p)
+
+	b := new(big.Int).Exp(y, e, p)
+	b.Mul(b, R)
+	b.Mod(b, p)
+
+	return a.Cmp(b) == 0, nil
+}
+
+// SchnorrSign signe le document "data" avec le schéma de Schnorr et
+// concataine la signature au document. La signature (R, s) est vérifiable
+// avec ElgamalCheck.
+func SchnorrSign(rand io.Reader, priv *ElgamalPrivateKey, data []byte, h crypto.Hash) (signedData []byte) {
+	// La lecture depuis un bytes.Reader ne peut pas échouer
+	signature, _ := schnorrSign(rand, priv, bytes.NewReader(data), h)
+	return serialize(data, signature)
+}
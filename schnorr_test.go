@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// Renvoie R et s depuis une signature de Schnorr
+func schnorrFields(signature []byte) (R, s *big.Int) {
+	d := deserialize(signature)
+	return new(big.Int).SetBytes(d[3]), new(big.Int).SetBytes(d[4])
+}
+
+func TestSchnorrSignature(t *testing.T) {
+	data := randomBytes(1000)
+
+	for _, priv := range []*ElgamalPrivateKey{keys, dsaVectorKey()} {
+		signedData := SchnorrSign(rand.Reader, priv, data, crypto.SHA256)
+
+		if !ElgamalCheck(&priv.ElgamalPublicKey, signedData) {
+			t.Error("Echec de la vérification de la signature de Schnorr")
+		}
+	}
+}
+
+func TestSchnorrStandardGroup(t *testing.T) {
+	priv, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	signedData := SchnorrSign(rand.Reader, priv, []byte("abc"), crypto.SHA3_256)
+
+	if !ElgamalCheck(&priv.ElgamalPublicKey, signedData) {
+		t.Error("Echec de la vérification de la signature de Schnorr dans ffdhe2048")
+	}
+}
+
+// Vérifie une signature de Schnorr SHA-256 du document data
+func schnorrValid(pub *ElgamalPublicKey, data []byte, R, s *big.Int) bool {
+	ok, err := schnorrCheck(pub, bytes.NewReader(data), crypto.SHA256, schemeSchnorrV2, R, s)
+	return ok && err == nil
+}
+
+func TestSchnorrForgery(t *testing.T) {
+	pub := &keys.ElgamalPublicKey
+	p, q, g, _ := schnorrGroup(pub)
+	data := []byte("document signé")
+
+	signature, err := schnorrSign(rand.Reader, keys, bytes.NewReader(data), crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	R, s := schnorrFields(signature)
+
+	// Message modifié
+	if schnorrValid(pub, []byte("document modifié"), R, s) {
+		t.Error("La signature est valide pour un autre message")
+	}
+
+	// Autre clé publique
+	other := GenerateElgamalKeys(rand.Reader, 160, 0)
+	if schnorrValid(&other.ElgamalPublicKey, data, R, s) {
+		t.Error("La signature est valide pour une autre clé")
+	}
+
+	// s modifié, ou remplacé par s + q
+	if schnorrValid(pub, data, R, new(big.Int).Add(s, big1)) {
+		t.Error("Une signature avec s modifié a été acceptée")
+	}
+	if schnorrValid(pub, data, R, new(big.Int).Add(s, q)) {
+		t.Error("Une signature avec s >= q a été acceptée")
+	}
+
+	// R hors du sous-groupe d'ordre q : -R a le même carré que R
+	negR := new(big.Int).Sub(p, R)
+	if schnorrValid(pub, data, negR, s) {
+		t.Error("Une signature avec R hors du sous-groupe a été acceptée")
+	}
+
+	// Signature triviale R = 1, s = 0 et tentative de falsification sans
+	// la clé : R = g^s * y^-e avec e calculé a posteriori
+	if schnorrValid(pub, data, big.NewInt(1), big.NewInt(0)) {
+		t.Error("La signature triviale a été acceptée")
+	}
+	forgedR := new(big.Int).Exp(g, s, p)
+	if schnorrValid(pub, data, forgedR, s) {
+		t.Error("Une signature falsifiée a été acceptée")
+	}
+}
+
+// Lecteur relisible dont le contenu change après un Seek
+type changingReader struct {
+	t *testing.T
+	*bytes.Reader
+}
+
+func (r changingReader) Seek(offset int64, whence int) (int64, error) {
+	r.t.Error("Le message a été relu pendant la signature")
+	r.Reader = bytes.NewReader([]byte("autre contenu"))
+	return 0, nil
+}
+
+func TestSchnorrSingleRead(t *testing.T) {
+	data := []byte("document lu une seule fois")
+
+	signature, err := ElgamalSignReader(nil, keys, changingReader{t, bytes.NewReader(data)}, crypto.SHA256, schemeSchnorr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(deserialize(signature)[0]) != schemeSchnorrV2 {
+		t.Error("La signature n'est pas au format schnorr-v2")
+	}
+	if !ElgamalCheckDetached(&keys.ElgamalPublicKey, data, signature) {
+		t.Error("Echec de la vérification de la signature")
+	}
+}
+
+func TestSchnorrLegacySignature(t *testing.T) {
+	pub := &keys.ElgamalPublicKey
+	p, q, g, y := schnorrGroup(pub)
+	data := []byte("signature de la version précédente")
+
+	// Défi calculé sur le message lui-même : e = H(R | y | message)
+	k := randRange(rand.Reader, big1, new(big.Int).Sub(q, big1))
+	R := new(big.Int).Exp(g, k, p)
+	e, _ := schnorrChallenge(crypto.SHA256, p, q, R, y, bytes.NewReader(data))
+	s := new(big.Int).Mul(e, keys.X)
+	s.Add(s, k).Mod(s, q)
+
+	signature := encodeSignature(schemeSchnorr, crypto.SHA256, pub, R, s)
+	if !ElgamalCheckDetached(pub, data, signature) {
+		t.Error("Une signature schnorr a été refusée")
+	}
+	if invalid := ElgamalCheckBatch(rand.Reader, []SignedMessage{{pub, data, signature}}); invalid != nil {
+		t.Error("Une signature schnorr a été refusée par la vérification par lots")
+	}
+
+	// Le défi d'une version ne vaut pas pour l'autre
+	v2 := encodeSignature(schemeSchnorrV2, crypto.SHA256, pub, R, s)
+	if ElgamalCheckDetached(pub, data, v2) {
+		t.Error("Une signature schnorr a été acceptée comme schnorr-v2")
+	}
+}
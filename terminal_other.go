@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoEcho = errors.New("gocrypto: impossible de masquer la saisie sur ce système, utiliser " + envPassphrase + " ou " + envPassphraseFD)
+
+// Sur les autres systèmes, l'écho ne peut pas être désactivé : la saisie
+// au terminal est refusée plutôt que d'afficher la phrase de passe
+func disableEcho(tty *os.File) (restore func(), err error) {
+	return nil, errNoEcho
+}
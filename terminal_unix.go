@@ -0,0 +1,54 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// Appelle ioctl(2) sur le terminal f
+func ioctlTermios(f *os.File, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Désactive l'écho du terminal tty et renvoie la fonction qui le rétablit.
+// L'écho est également rétabli si le programme est interrompu pendant la
+// saisie.
+func disableEcho(tty *os.File) (restore func(), err error) {
+	var old syscall.Termios
+	if err := ioctlTermios(tty, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+
+	t := old
+	t.Lflag &^= syscall.ECHO
+	t.Lflag |= syscall.ICANON | syscall.ISIG
+	if err := ioctlTermios(tty, ioctlSetTermios, &t); err != nil {
+		return nil, err
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-interrupted:
+			ioctlTermios(tty, ioctlSetTermios, &old)
+			os.Exit(130)
+		case <-done:
+		}
+	}()
+
+	return func() {
+		signal.Stop(interrupted)
+		close(done)
+		ioctlTermios(tty, ioctlSetTermios, &old)
+	}, nil
+}
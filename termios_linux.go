@@ -0,0 +1,9 @@
+package main
+
+import "syscall"
+
+// Requêtes ioctl(2) lisant et modifiant les attributs du terminal
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Identifiants des formats de fichiers du déchiffrement à seuil
+const (
+	dkgCommitmentsVersion    = "elgamal-dkg-commitments-v1"
+	dkgShareVersion          = "elgamal-dkg-share-v1"
+	thresholdKeyVersion      = "elgamal-threshold-key-v1"
+	partialDecryptionVersion = "elgamal-partial-decryption-v1"
+)
+
+// Nombre maximal de participants à un partage
+const thresholdMaxParticipants = 255
+
+var (
+	errThresholdParams   = fmt.Errorf("gocrypto: paramètres de seuil invalides (1 <= t <= n <= %d)", thresholdMaxParticipants)
+	errThresholdGroup    = errors.New("gocrypto: g doit engendrer un sous-groupe d'ordre premier")
+	errDKGCommitments    = errors.New("gocrypto: engagements de la génération distribuée invalides ou incohérents")
+	errDKGShare          = errors.New("gocrypto: part de la génération distribuée invalide")
+	errThresholdKey      = errors.New("gocrypto: clé de déchiffrement à seuil invalide")
+	errInvalidPartial    = errors.New("gocrypto: déchiffrement partiel invalide")
+	errNotEnoughPartials = errors.New("gocrypto: nombre de déchiffrements partiels insuffisant")
+)
+
+// InvalidShareError indique que la part envoyée par un participant est
+// absente ou ne correspond pas à ses engagements : ce participant doit
+// être exclu et la génération recommencée
+type InvalidShareError struct {
+	Dealer int // numéro du participant fautif
+}
+
+func (e *InvalidShareError) Error() string {
+	return fmt.Sprintf("gocrypto: la part du participant %d est absente ou invalide", e.Dealer)
+}
+
+// DKGCommitments contient les engagements publiés par un participant de
+// la génération distribuée : A_k = g^a_k pour chaque coefficient a_k de
+// son polynôme secret de degré Threshold-1
+type DKGCommitments struct {
+	Q *big.Int // Q = p-1
+	G *big.Int // G engendre un sous-groupe d'ordre premier n = Q/2
+
+	Threshold    int // nombre de parts nécessaires au déchiffrement
+	Participants int // nombre total de participants
+	Dealer       int // numéro du participant (de 1 à Participants)
+
+	Coefficients []*big.Int
+}
+
+// DKGShare est la valeur f(Recipient) du polynôme du participant Dealer,
+// à transmettre confidentiellement au participant Recipient
+type DKGShare struct {
+	Dealer    int
+	Recipient int
+	Value     *big.Int
+}
+
+// ThresholdKey est la part de la clé privée détenue par un participant :
+// X est la somme des parts reçues de tous les participants, la clé privée
+// complète n'étant jamais calculée. La clé publique est une clé ElGamal
+// ordinaire, utilisable avec ElgamalEncrypt.
+type ThresholdKey struct {
+	ElgamalPublicKey
+
+	Threshold    int
+	Participants int
+	Index        int // numéro du participant
+
+	X *big.Int // part de la clé privée
+}
+
+// PartialDecryption est le déchiffrement partiel c1^X d'un message par le
+// participant Index
+type PartialDecryption struct {
+	KeyID     uint64 // identifiant de la clé publique commune
+	Threshold int
+	Index     int
+	C1        *big.Int
+	D         *big.Int // D = c1^X
+}
+
+func validThreshold(threshold, participants int) bool {
+	return threshold >= 1 && threshold <= participants && participants <= thresholdMaxParticipants
+}
+
+// Renvoie l'ordre du sous-groupe engendré par g, qui doit être premier :
+// le partage de la clé se fait modulo cet ordre
+func thresholdGroupOrder(q, g *big.Int) (*big.Int, error) {
+	pub := &ElgamalPublicKey{Q: q, G: g, H: g}
+	order, err := pub.generatorOrder()
+	if err != nil {
+		return nil, err
+	}
+	if order.Cmp(q) == 0 {
+		return nil, errThresholdGroup
+	}
+	return order, nil
+}
+
+// Indique si x appartient au sous-groupe d'ordre order de Zp, sans valoir 1
+func inSubgroup(x, p, order *big.Int) bool {
+	return x.Cmp(big1) > 0 && x.Cmp(p) < 0 && new(big.Int).Exp(x, order, p).Cmp(big1) == 0
+}
+
+// DKGDeal produit la contribution du participant dealer à une génération
+// distribuée de clé à seuil (Pedersen) dans le groupe (p, g) : il tire un
+// polynôme secret f de degré threshold-1, publie les engagements de ses
+// coefficients et envoie f(j) au participant j. Les parts sont renvoyées
+// dans l'ordre des participants (shares[j-1] pour le participant j).
+func DKGDeal(rand io.Reader, p, g *big.Int, threshold, participants, dealer int) (*DKGCommitments, []*DKGShare, error) {
+	if !validThreshold(threshold, participants) || dealer < 1 || dealer > participants {
+		return nil, nil, errThresholdParams
+	}
+
+	q := new(big.Int).Sub(p, big1)
+	order, err := thresholdGroupOrder(q, g)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Le terme constant est la contribution du participant à la clé privée
+	coefficients := make([]*big.Int, threshold)
+	coefficients[0] = randRange(rand, big1, new(big.Int).Sub(order, big1))
+	for k := 1; k < threshold; k++ {
+		coefficients[k] = randRange(rand, big0, new(big.Int).Sub(order, big1))
+	}
+
+	commitments := &DKGCommitments{
+		Q:            q,
+		G:            g,
+		Threshold:    threshold,
+		Participants: participants,
+		Dealer:       dealer,
+		Coefficients: make([]*big.Int, threshold),
+	}
+	for k, a := range coefficients {
+		commitments.Coefficients[k] = new(big.Int).Exp(g, a, p)
+	}
+
+	shares := make([]*DKGShare, participants)
+	for j := 1; j <= participants; j++ {
+		// f(j) par la méthode de Horner
+		x := big.NewInt(int64(j))
+		v := new(big.Int)
+		for k := threshold - 1; k >= 0; k-- {
+			v.Mul(v, x)
+			v.Add(v, coefficients[k])
+			v.Mod(v, order)
+		}
+		shares[j-1] = &DKGShare{Dealer: dealer, Recipient: j, Value: v}
+	}
+
+	return commitments, shares, nil
+}
+
+// VerifyShare vérifie que la part s correspond aux engagements :
+// g^f(j) = A_0 * A_1^j * ... * A_(t-1)^(j^(t-1))
+func (c *DKGCommitments) VerifyShare(s *DKGShare) bool {
+	if s.Dealer != c.Dealer || s.Recipient < 1 || s.Recipient > c.Participants || s.Value.Sign() < 0 {
+		return false
+	}
+
+	p := new(big.Int).Add(c.Q, big1)
+	x := big.NewInt(int64(s.Recipient))
+
+	// Méthode de Horner dans l'exposant
+	expected := big.NewInt(1)
+	for k := len(c.Coefficients) - 1; k >= 0; k-- {
+		expected.Exp(expected, x, p)
+		expected.Mul(expected, c.Coefficients[k])
+		expected.Mod(expected, p)
+	}
+
+	return new(big.Int).Exp(c.G, s.Value, p).Cmp(expected) == 0
+}
+
+// Vérifie les engagements de tous les participants : mêmes paramètres,
+// un jeu d'engagements par participant, éléments du sous-groupe d'ordre
+// order. Renvoie les engagements indexés par numéro de participant.
+func checkDKGCommitments(commitments []*DKGCommitments, order *big.Int) ([]*DKGCommitments, error) {
+	first := commitments[0]
+	p := new(big.Int).Add(first.Q, big1)
+
+	byDealer := make([]*DKGCommitments, first.Participants+1)
+	for _, c := range commitments {
+		if c.Q.Cmp(first.Q) != 0 || c.G.Cmp(first.G) != 0 ||
+			c.Threshold != first.Threshold || c.Participants != first.Participants ||
+			c.Dealer < 1 || c.Dealer > first.Participants || byDealer[c.Dealer] != nil ||
+			len(c.Coefficients) != first.Threshold {
+			return nil, errDKGCommitments
+		}
+		for _, a := range c.Coefficients {
+			if !inSubgroup(a, p, order) {
+				return nil, errDKGCommitments
+			}
+		}
+		byDealer[c.Dealer] = c
+	}
+
+	if len(commitments) != first.Participants {
+		return nil, errDKGCommitments
+	}
+	return byDealer, nil
+}
+
+// DKGFinish termine la génération distribuée pour un participant à partir
+// des engagements publiés par tous les participants et des parts qu'ils
+// lui ont envoyées. Une InvalidShareError désigne le participant dont la
+// part est absente ou ne correspond pas à ses engagements.
+func DKGFinish(commitments []*DKGCommitments, shares []*DKGShare) (*ThresholdKey, error) {
+	if len(commitments) == 0 || len(shares) == 0 {
+		return nil, errDKGCommitments
+	}
+
+	first := commitments[0]
+	if !validThreshold(first.Threshold, first.Participants) {
+		return nil, errThresholdParams
+	}
+	order, err := thresholdGroupOrder(first.Q, first.G)
+	if err != nil {
+		return nil, err
+	}
+	byDealer, err := checkDKGCommitments(commitments, order)
+	if err != nil {
+		return nil, err
+	}
+
+	// Toutes les parts doivent être destinées au même participant
+	index := shares[0].Recipient
+	received := make([]*DKGShare, first.Participants+1)
+	for _, s := range shares {
+		if s.Recipient != index || s.Dealer < 1 || s.Dealer > first.Participants || received[s.Dealer] != nil {
+			return nil, errDKGShare
+		}
+		received[s.Dealer] = s
+	}
+
+	p := new(big.Int).Add(first.Q, big1)
+	x, h := new(big.Int), big.NewInt(1)
+	for dealer := 1; dealer <= first.Participants; dealer++ {
+		s := received[dealer]
+		if s == nil || s.Value.Cmp(order) >= 0 || !byDealer[dealer].VerifyShare(s) {
+			return nil, &InvalidShareError{Dealer: dealer}
+		}
+
+		x.Add(x, s.Value)
+		h.Mul(h, byDealer[dealer].Coefficients[0])
+		h.Mod(h, p)
+	}
+	x.Mod(x, order)
+
+	if h.Cmp(big1) == 0 {
+		return nil, errDKGCommitments
+	}
+
+	return &ThresholdKey{
+		ElgamalPublicKey: ElgamalPublicKey{Q: first.Q, G: first.G, H: h, Group: lookupGroup(p, first.G)},
+		Threshold:        first.Threshold,
+		Participants:     first.Participants,
+		Index:            index,
+		X:                x,
+	}, nil
+}
+
+// PartialDecrypt calcule le déchiffrement partiel d'un message chiffré
+// pour la clé publique commune avec ElgamalEncrypt ou ElgamalEncryptMulti.
+// Threshold déchiffrements partiels permettent de déchiffrer le message
+// avec ThresholdDecrypt.
+func (k *ThresholdKey) PartialDecrypt(ciphertext []byte) (*PartialDecryption, error) {
+	c1s, err := elgamalRecipientC1(&k.ElgamalPublicKey, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	// c1 doit appartenir au sous-groupe : sinon c1^X révélerait des
+	// informations sur la part X
+	c1 := c1s[0]
+	p := new(big.Int).Add(k.Q, big1)
+	if !inSubgroup(c1, p, new(big.Int).Rsh(k.Q, 1)) {
+		return nil, errInvalidCiphertext
+	}
+
+	return &PartialDecryption{
+		KeyID:     k.KeyID(),
+		Threshold: k.Threshold,
+		Index:     k.Index,
+		C1:        c1,
+		D:         new(big.Int).Exp(c1, k.X, p),
+	}, nil
+}
+
+// Coefficient de Lagrange en 0 du participant index pour l'ensemble de
+// participants indices, modulo order
+func lagrangeCoefficient(index int, indices []int, order *big.Int) *big.Int {
+	num, den := big.NewInt(1), big.NewInt(1)
+	for _, m := range indices {
+		if m == index {
+			continue
+		}
+		num.Mul(num, big.NewInt(int64(m)))
+		den.Mul(den, big.NewInt(int64(m-index)))
+	}
+	den.Mod(den, order)
+	den.ModInverse(den, order)
+
+	num.Mul(num, den)
+	return num.Mod(num, order)
+}
+
+// ThresholdDecrypt déchiffre un message chiffré pour la clé publique
+// commune pub à partir d'au moins Threshold déchiffrements partiels de
+// participants distincts : le secret partagé h^y = c1^x est reconstruit
+// par interpolation de Lagrange dans l'exposant.
+func ThresholdDecrypt(pub *ElgamalPublicKey, ciphertext []byte, partials []*PartialDecryption) ([]byte, error) {
+	if len(partials) == 0 || len(partials) < partials[0].Threshold {
+		return nil, errNotEnoughPartials
+	}
+
+	order, err := thresholdGroupOrder(pub.Q, pub.G)
+	if err != nil {
+		return nil, err
+	}
+	p := new(big.Int).Add(pub.Q, big1)
+
+	c1 := partials[0].C1
+	seen := make(map[int]bool)
+	indices := make([]int, len(partials))
+	for i, partial := range partials {
+		if partial.KeyID != pub.KeyID() {
+			return nil, &KeyMismatchError{Expected: partial.KeyID, Actual: pub.KeyID()}
+		}
+		if partial.C1.Cmp(c1) != 0 || seen[partial.Index] || partial.Index < 1 ||
+			partial.Index > thresholdMaxParticipants || !inSubgroup(partial.D, p, order) {
+			return nil, errInvalidPartial
+		}
+		seen[partial.Index] = true
+		indices[i] = partial.Index
+	}
+
+	s := big.NewInt(1)
+	for _, partial := range partials {
+		lambda := lagrangeCoefficient(partial.Index, indices, order)
+		s.Mul(s, new(big.Int).Exp(partial.D, lambda, p))
+		s.Mod(s, p)
+	}
+
+	decapsulate := func(c *big.Int) ([]byte, []byte, error) {
+		if c.Cmp(c1) != 0 {
+			return nil, nil, errInvalidPartial
+		}
+		key, nonce := deriveKEMKey(p, c1, s)
+		return key, nonce, nil
+	}
+	return elgamalDecryptKEM(pub, decapsulate, deserialize(ciphertext))
+}
+
+// Lit un entier de 4 octets enregistré avec intToBytes
+func readThresholdInt(b []byte) (int, bool) {
+	if len(b) != 4 {
+		return 0, false
+	}
+	return bytesToInt(b), true
+}
+
+// GetBytes renvoie sous forme d'octets les engagements :
+// version | Q | G | t | n | participant | A_0 | ... | A_(t-1)
+func (c *DKGCommitments) GetBytes() []byte {
+	fields := [][]byte{
+		[]byte(dkgCommitmentsVersion), c.Q.Bytes(), c.G.Bytes(),
+		intToBytes(c.Threshold), intToBytes(c.Participants), intToBytes(c.Dealer),
+	}
+	for _, a := range c.Coefficients {
+		fields = append(fields, a.Bytes())
+	}
+	return serialize(fields...)
+}
+
+// ParseDKGCommitments charge des engagements enregistrés avec GetBytes
+func ParseDKGCommitments(b []byte) (*DKGCommitments, error) {
+	d := deserialize(b)
+	if len(d) < 7 || string(d[0]) != dkgCommitmentsVersion {
+		return nil, errDKGCommitments
+	}
+
+	t, ok1 := readThresholdInt(d[3])
+	n, ok2 := readThresholdInt(d[4])
+	dealer, ok3 := readThresholdInt(d[5])
+	if !ok1 || !ok2 || !ok3 || !validThreshold(t, n) || len(d) != 6+t {
+		return nil, errDKGCommitments
+	}
+
+	c := &DKGCommitments{
+		Q:            new(big.Int).SetBytes(d[1]),
+		G:            new(big.Int).SetBytes(d[2]),
+		Threshold:    t,
+		Participants: n,
+		Dealer:       dealer,
+	}
+	for _, a := range d[6:] {
+		c.Coefficients = append(c.Coefficients, new(big.Int).SetBytes(a))
+	}
+	return c, nil
+}
+
+// GetBytes renvoie sous forme d'octets la part :
+// version | participant émetteur | participant destinataire | f(j)
+func (s *DKGShare) GetBytes() []byte {
+	return serialize([]byte(dkgShareVersion), intToBytes(s.Dealer), intToBytes(s.Recipient), s.Value.Bytes())
+}
+
+// ParseDKGShare charge une part enregistrée avec GetBytes
+func ParseDKGShare(b []byte) (*DKGShare, error) {
+	d := deserialize(b)
+	if len(d) != 4 || string(d[0]) != dkgShareVersion {
+		return nil, errDKGShare
+	}
+
+	dealer, ok1 := readThresholdInt(d[1])
+	recipient, ok2 := readThresholdInt(d[2])
+	if !ok1 || !ok2 {
+		return nil, errDKGShare
+	}
+	return &DKGShare{Dealer: dealer, Recipient: recipient, Value: new(big.Int).SetBytes(d[3])}, nil
+}
+
+// GetBytes renvoie sous forme d'octets la part de clé :
+// version | Q | G | H | t | n | participant | X
+func (k *ThresholdKey) GetBytes() []byte {
+	return serialize([]byte(thresholdKeyVersion), k.Q.Bytes(), k.G.Bytes(), k.H.Bytes(),
+		intToBytes(k.Threshold), intToBytes(k.Participants), intToBytes(k.Index), k.X.Bytes())
+}
+
+// ParseThresholdKey charge une part de clé enregistrée avec GetBytes
+func ParseThresholdKey(b []byte) (*ThresholdKey, error) {
+	d := deserialize(b)
+	if len(d) != 8 || string(d[0]) != thresholdKeyVersion {
+		return nil, errThresholdKey
+	}
+
+	t, ok1 := readThresholdInt(d[4])
+	n, ok2 := readThresholdInt(d[5])
+	index, ok3 := readThresholdInt(d[6])
+	if !ok1 || !ok2 || !ok3 || !validThreshold(t, n) || index < 1 || index > n {
+		return nil, errThresholdKey
+	}
+
+	k := &ThresholdKey{
+		ElgamalPublicKey: ElgamalPublicKey{
+			Q: new(big.Int).SetBytes(d[1]),
+			G: new(big.Int).SetBytes(d[2]),
+			H: new(big.Int).SetBytes(d[3]),
+		},
+		Threshold:    t,
+		Participants: n,
+		Index:        index,
+		X:            new(big.Int).SetBytes(d[7]),
+	}
+	if _, err := thresholdGroupOrder(k.Q, k.G); err != nil {
+		return nil, err
+	}
+	k.Group = lookupGroup(new(big.Int).Add(k.Q, big1), k.G)
+	return k, nil
+}
+
+// GetBytes renvoie sous forme d'octets le déchiffrement partiel :
+// version | identifiant de la clé | t | participant | c1 | c1^X
+func (pd *PartialDecryption) GetBytes() []byte {
+	return serialize([]byte(partialDecryptionVersion), binary.BigEndian.AppendUint64(nil, pd.KeyID),
+		intToBytes(pd.Threshold), intToBytes(pd.Index), pd.C1.Bytes(), pd.D.Bytes())
+}
+
+// ParsePartialDecryption charge un déchiffrement partiel enregistré avec
+// GetBytes
+func ParsePartialDecryption(b []byte) (*PartialDecryption, error) {
+	d := deserialize(b)
+	if len(d) != 6 || string(d[0]) != partialDecryptionVersion || len(d[1]) != 8 {
+		return nil, errInvalidPartial
+	}
+
+	t, ok1 := readThresholdInt(d[2])
+	index, ok2 := readThresholdInt(d[3])
+	if !ok1 || !ok2 || !validThreshold(t, thresholdMaxParticipants) {
+		return nil, errInvalidPartial
+	}
+
+	return &PartialDecryption{
+		KeyID:     binary.BigEndian.Uint64(d[1]),
+		Threshold: t,
+		Index:     index,
+		C1:        new(big.Int).SetBytes(d[4]),
+		D:         new(big.Int).SetBytes(d[5]),
+	}, nil
+}
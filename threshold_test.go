@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// Génération distribuée d'une clé t parmi n dans le groupe ffdhe2048
+func thresholdKeys(t *testing.T, threshold, participants int) []*ThresholdKey {
+	group := standardGroups["ffdhe2048"]
+
+	commitments := make([]*DKGCommitments, participants)
+	shares := make([][]*DKGShare, participants+1)
+	for i := 1; i <= participants; i++ {
+		c, s, err := DKGDeal(rand.Reader, group.P, group.G, threshold, participants, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commitments[i-1] = c
+		for _, share := range s {
+			shares[share.Recipient] = append(shares[share.Recipient], share)
+		}
+	}
+
+	keys := make([]*ThresholdKey, participants)
+	for j := 1; j <= participants; j++ {
+		k, err := DKGFinish(commitments, shares[j])
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[j-1] = k
+	}
+	return keys
+}
+
+func TestThresholdDecryption(t *testing.T) {
+	keys := thresholdKeys(t, 2, 3)
+	pub := &keys[0].ElgamalPublicKey
+	for _, k := range keys[1:] {
+		if k.H.Cmp(pub.H) != 0 {
+			t.Fatal("Les participants n'obtiennent pas la même clé publique")
+		}
+	}
+	if err := pub.Validate(); err != nil {
+		t.Error("Clé publique commune invalide", err)
+	}
+
+	m := []byte("archive séquestrée")
+	c := ElgamalEncrypt(rand.Reader, pub, m)
+
+	partials := make([]*PartialDecryption, len(keys))
+	for i, k := range keys {
+		var err error
+		if partials[i], err = k.PartialDecrypt(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Tout sous-ensemble d'au moins deux participants peut déchiffrer
+	for _, subset := range [][]int{{0, 1}, {0, 2}, {2, 1}, {0, 1, 2}} {
+		var selected []*PartialDecryption
+		for _, i := range subset {
+			selected = append(selected, partials[i])
+		}
+		d, err := ThresholdDecrypt(pub, c, selected)
+		if err != nil || !bytes.Equal(d, m) {
+			t.Error("Echec du déchiffrement à seuil", subset, err)
+		}
+	}
+
+	if _, err := ThresholdDecrypt(pub, c, partials[:1]); err != errNotEnoughPartials {
+		t.Error("Un seul déchiffrement partiel a été accepté", err)
+	}
+	if _, err := ThresholdDecrypt(pub, c, []*PartialDecryption{partials[0], partials[0]}); err != errInvalidPartial {
+		t.Error("Deux déchiffrements partiels identiques ont été acceptés", err)
+	}
+
+	// Un déchiffrement partiel faux ne permet pas de déchiffrer
+	bad := *partials[1]
+	bad.D = new(big.Int).Exp(bad.D, big2, new(big.Int).Add(pub.Q, big1))
+	if _, err := ThresholdDecrypt(pub, c, []*PartialDecryption{partials[0], &bad}); err == nil {
+		t.Error("Un déchiffrement partiel faux a été accepté")
+	}
+}
+
+func TestThresholdMultiRecipient(t *testing.T) {
+	keys := thresholdKeys(t, 1, 2)
+	m := []byte("message")
+
+	c, err := ElgamalEncryptMulti(rand.Reader, []*ElgamalPublicKey{&keys[0].ElgamalPublicKey, &keys[0].ElgamalPublicKey}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	partial, err := keys[1].PartialDecrypt(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ThresholdDecrypt(&keys[0].ElgamalPublicKey, c, []*PartialDecryption{partial})
+	if err != nil || !bytes.Equal(d, m) {
+		t.Error("Echec du déchiffrement à seuil d'un message à plusieurs destinataires", err)
+	}
+}
+
+func TestDKGInvalidShare(t *testing.T) {
+	group := standardGroups["ffdhe2048"]
+
+	var commitments []*DKGCommitments
+	var shares []*DKGShare
+	for i := 1; i <= 3; i++ {
+		c, s, err := DKGDeal(rand.Reader, group.P, group.G, 2, 3, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		commitments = append(commitments, c)
+		shares = append(shares, s[0])
+	}
+
+	shares[1].Value.Add(shares[1].Value, big1)
+	_, err := DKGFinish(commitments, shares)
+	var invalid *InvalidShareError
+	if !errors.As(err, &invalid) || invalid.Dealer != 2 {
+		t.Error("La part invalide n'a pas été signalée", err)
+	}
+
+	shares[1].Value.Sub(shares[1].Value, big1)
+	if _, err := DKGFinish(commitments, shares[:2]); !errors.As(err, &invalid) || invalid.Dealer != 3 {
+		t.Error("La part manquante n'a pas été signalée", err)
+	}
+	if _, err := DKGFinish(commitments[:2], shares); err != errDKGCommitments {
+		t.Error("Des engagements manquants ont été acceptés", err)
+	}
+}
+
+func TestDKGParameters(t *testing.T) {
+	group := standardGroups["ffdhe2048"]
+	for _, tn := range [][2]int{{0, 3}, {4, 3}, {2, 256}} {
+		if _, _, err := DKGDeal(rand.Reader, group.P, group.G, tn[0], tn[1], 1); err != errThresholdParams {
+			t.Error("Paramètres invalides acceptés", tn, err)
+		}
+	}
+
+	// g doit engendrer un sous-groupe d'ordre premier : un générateur de
+	// Zp* (ordre 2n) ne convient pas
+	p := new(big.Int).Add(keys.Q, big1)
+	if _, _, err := DKGDeal(rand.Reader, p, keys.G, 2, 3, 1); err != errThresholdGroup {
+		t.Error("Un générateur d'ordre non premier a été accepté", err)
+	}
+}
+
+func TestThresholdSerialization(t *testing.T) {
+	group := standardGroups["ffdhe2048"]
+	c, s, err := DKGDeal(rand.Reader, group.P, group.G, 2, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c2, err := ParseDKGCommitments(c.GetBytes())
+	if err != nil || !bytes.Equal(c2.GetBytes(), c.GetBytes()) {
+		t.Error("Echec de la relecture des engagements", err)
+	}
+	s2, err := ParseDKGShare(s[1].GetBytes())
+	if err != nil || !c2.VerifyShare(s2) {
+		t.Error("Echec de la relecture d'une part", err)
+	}
+
+	k := thresholdKeys(t, 2, 2)[1]
+	k2, err := ParseThresholdKey(k.GetBytes())
+	if err != nil || !bytes.Equal(k2.GetBytes(), k.GetBytes()) || k2.Group != "ffdhe2048" {
+		t.Error("Echec de la relecture de la part de clé", err)
+	}
+
+	partial, err := k.PartialDecrypt(ElgamalEncrypt(rand.Reader, &k.ElgamalPublicKey, []byte("m")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2, err := ParsePartialDecryption(partial.GetBytes())
+	if err != nil || !bytes.Equal(p2.GetBytes(), partial.GetBytes()) {
+		t.Error("Echec de la relecture du déchiffrement partiel", err)
+	}
+}
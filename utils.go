@@ -3,34 +3,43 @@ package main
 import (
 	"crypto/rand"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/big"
-	mrand "math/rand"
 	"os"
-	"time"
 )
 
-var seed = mrand.New(mrand.NewSource(time.Now().UnixNano()))
+// Renvoie un nombre de type "big.Int" uniformément distribué dans [min,max]
+// en utilisant la source d'aléa random
+func randRange(random io.Reader, min, max *big.Int) *big.Int {
+	width := new(big.Int).Sub(max, min)
+	width.Add(width, big1)
 
-// Renvoie un nombre de type "big.Int" compris entre [min,max]
-func randRange(min, max *big.Int) *big.Int {
-	n := new(big.Int).Rand(seed, new(big.Int).Sub(max, min))
-	n.Add(n, min)
-	return n
+	// rand.Int procède par rejet, le tirage n'est donc pas biaisé
+	n, err := rand.Int(random, width)
+	if err != nil {
+		panic("gocrypto: lecture de la source d'aléa impossible : " + err.Error())
+	}
+
+	return n.Add(n, min)
 }
 
-// Returns n bytes randomly
-func randomBytes(n int) []byte {
+// Renvoie n octets lus depuis la source d'aléa random
+func readRandom(random io.Reader, n int) []byte {
 	b := make([]byte, n)
-	if _, err := rand.Read(b); err != nil {
-		fmt.Println("Erreur :", err)
-		return nil
+	if _, err := io.ReadFull(random, b); err != nil {
+		panic("gocrypto: lecture de la source d'aléa impossible : " + err.Error())
 	}
 	return b
 }
 
-func randomBigInt(size int) *big.Int {
-	b := new(big.Int).SetBytes(randomBytes(size))
+// Returns n bytes randomly
+func randomBytes(n int) []byte {
+	return readRandom(rand.Reader, n)
+}
+
+func randomBigInt(random io.Reader, size int) *big.Int {
+	b := new(big.Int).SetBytes(readRandom(random, size))
 	return b
 }
 
@@ -68,27 +77,34 @@ func serialize(fields ...[]byte) []byte {
 }
 
 // Renvoie les différents tableaux sérialisé avec la fonction
-// serialize.
+// serialize. Renvoie nil si les données sont tronquées.
 func deserialize(bytes []byte) [][]byte {
 	var res [][]byte
 	k, l := 0, 0
 
-	for {
+	for k < len(bytes) {
 		s := k + 4
-		l = bytesToInt(bytes[k : k+4])
+		if s > len(bytes) {
+			return nil
+		}
+
+		l = bytesToInt(bytes[k:s])
+		if l < 0 || l > len(bytes)-s {
+			return nil
+		}
 		res = append(res, bytes[s:s+l])
 
 		k = s + l
-		if k >= len(bytes) {
-			break
-		}
 	}
 
 	return res
 }
 
+// Écrit b dans le fichier path, créé avec les droits 0600 s'il n'existe
+// pas. Un fichier existant est tronqué : sinon, en écrivant un contenu plus
+// court, la fin de l'ancien contenu resterait dans le fichier.
 func writeBytes(b []byte, path string) {
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 
 	if err == nil {
 		defer f.Close()
@@ -111,9 +127,22 @@ func readBytes(path string) []byte {
 	return b
 }
 
+// Ouvre le fichier path en lecture
+func openFile(path string) *os.File {
+	f, err := os.Open(path)
+
+	if err != nil {
+		fmt.Println("Erreur lors de l'ouverture du fichier:", err)
+		os.Exit(1)
+	}
+
+	return f
+}
+
 // Ajoute un padding sur le texte clair pour que sa
-// longueur soit un multiple de bsize bits
-func addPadding(b []byte, bsize int) []byte {
+// longueur soit un multiple de bsize bits. Les octets de bourrage
+// sont lus depuis la source d'aléa random
+func addPadding(random io.Reader, b []byte, bsize int) []byte {
 	bsize = (bsize + 7) / 8
 
 	newSize := ((len(b) / bsize) + 1) * bsize
@@ -124,19 +153,25 @@ func addPadding(b []byte, bsize int) []byte {
 		r[i] = b[i]
 	}
 
-	copy(r[len(b):], randomBytes(newSize-len(b)))
+	copy(r[len(b):], readRandom(random, newSize-len(b)))
 
 	r[len(r)-1] = byte(len(r) - len(b) - 1)
 
 	return r
 }
 
-// Retire le padding ajouté par la fonction addPadding
-func removePadding(b []byte) []byte {
+// Retire le padding ajouté par la fonction addPadding. Renvoie
+// errInvalidCiphertext si le dernier octet ne désigne pas un padding
+// contenu dans b.
+func removePadding(b []byte) ([]byte, error) {
+	if len(b) == 0 || int(b[len(b)-1]) >= len(b) {
+		return nil, errInvalidCiphertext
+	}
+
 	r := make([]byte, len(b)-int(b[len(b)-1])-1)
 
 	for i := range r {
 		r[i] = b[i]
 	}
-	return r
+	return r, nil
 }
@@ -2,6 +2,9 @@ package main
 
 import (
 	"bytes"
+	"crypto/rand"
+	"math/big"
+	"path/filepath"
 	"testing"
 )
 
@@ -18,3 +21,40 @@ func TestSerialization(t *testing.T) {
 		t.Error("Erreur dans la deserialization")
 	}
 }
+
+func TestDeserializeTruncated(t *testing.T) {
+	k := serialize(randomBytes(10), randomBytes(20))
+
+	if d := deserialize(k[:len(k)-1]); d != nil {
+		t.Error("Des données tronquées ont été désérialisées")
+	}
+}
+
+func TestWriteBytesTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fichier")
+
+	writeBytes(randomBytes(1000), path)
+	short := randomBytes(10)
+	writeBytes(short, path)
+
+	if b := readBytes(path); !bytes.Equal(b, short) {
+		t.Errorf("Le fichier n'a pas été tronqué : %d octets au lieu de %d", len(b), len(short))
+	}
+}
+
+func TestRandRange(t *testing.T) {
+	min, max := big.NewInt(3), big.NewInt(6)
+	seen := make(map[int64]bool)
+
+	for i := 0; i < 1000; i++ {
+		n := randRange(rand.Reader, min, max)
+		if n.Cmp(min) < 0 || n.Cmp(max) > 0 {
+			t.Fatal("randRange renvoie un nombre hors de l'intervalle :", n)
+		}
+		seen[n.Int64()] = true
+	}
+
+	if len(seen) != 4 {
+		t.Error("randRange ne couvre pas tout l'intervalle [min,max]")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Nombre de tours de Miller-Rabin utilisés pour valider les clés
+const validationRounds = 25
+
+var (
+	errKeyTooSmall     = fmt.Errorf("gocrypto: clé invalide : p doit faire au moins %d bits", elgamalMinSize)
+	errKeyNotPrime     = errors.New("gocrypto: clé invalide : p n'est pas premier")
+	errKeyNotSafePrime = errors.New("gocrypto: clé invalide : p n'est pas un nombre premier sûr (p = 2n + 1, n premier)")
+	errKeySubgroup     = errors.New("gocrypto: clé invalide : l'ordre du sous-groupe n'est pas un diviseur premier de p-1")
+	errKeySmoothOrder  = errors.New("gocrypto: clé invalide : p-1 n'a pas assez de grands facteurs premiers")
+	errKeyGenerator    = errors.New("gocrypto: clé invalide : g n'engendre pas un sous-groupe d'ordre premier")
+	errKeyPublicValue  = errors.New("gocrypto: clé invalide : h n'appartient pas au groupe engendré par g")
+	errKeyPrivateValue = errors.New("gocrypto: clé invalide : x ne correspond pas à h = g^x")
+)
+
+// Renvoie l'ordre du groupe engendré par G, ou une erreur si p ou G ne
+// conviennent pas. Pour un nombre premier sûr p = 2n + 1, G peut engendrer
+// Zp* (ordre 2n) ou le sous-groupe des carrés (ordre n) ; pour une clé DSA,
+// G doit engendrer le sous-groupe d'ordre SubgroupOrder.
+func (pub *ElgamalPublicKey) generatorOrder() (*big.Int, error) {
+	if pub.Q == nil || pub.G == nil || pub.H == nil {
+		return nil, errInvalidElgamalKey
+	}
+
+	p := new(big.Int).Add(pub.Q, big1)
+	if p.BitLen() < elgamalMinSize {
+		return nil, errKeyTooSmall
+	}
+
+	// g doit être dans [2, p-2]
+	if pub.G.Cmp(big2) < 0 || pub.G.Cmp(new(big.Int).Sub(pub.Q, big1)) > 0 {
+		return nil, errKeyGenerator
+	}
+
+	// Les paramètres des groupes standards sont connus et n'ont pas
+	// besoin d'être testés
+	standard := lookupGroup(p, pub.G) != ""
+
+	if !standard && (p.Bit(0) == 0 || !probablyPrime(p, validationRounds)) {
+		return nil, errKeyNotPrime
+	}
+
+	if q := pub.SubgroupOrder; q != nil {
+		// q premier, q | p-1 et g^q = 1
+		if q.Cmp(big2) < 0 || new(big.Int).Mod(pub.Q, q).Sign() != 0 || !probablyPrime(q, validationRounds) {
+			return nil, errKeySubgroup
+		}
+		if new(big.Int).Exp(pub.G, q, p).Cmp(big1) != 0 {
+			return nil, errKeyGenerator
+		}
+		return q, nil
+	}
+
+	n := new(big.Int).Rsh(pub.Q, 1)
+	if pub.Q.Bit(0) != 0 || (!standard && !probablyPrime(n, validationRounds)) {
+		return nil, errKeyNotSafePrime
+	}
+
+	// p étant sûr, g différent de 1 et p-1 est d'ordre n ou 2n
+	if new(big.Int).Exp(pub.G, n, p).Cmp(big1) == 0 {
+		return n, nil
+	}
+	return pub.Q, nil
+}
+
+// Validate vérifie la clé publique : p est premier et de taille suffisante,
+// c'est un nombre premier sûr (ou, pour une clé DSA, SubgroupOrder est un
+// diviseur premier de p-1), g engendre un sous-groupe d'ordre n, 2n ou
+// SubgroupOrder, et h appartient à ce sous-groupe sans valoir 1 ou p-1.
+// Les clés d'origine inconnue doivent être validées avant de chiffrer un
+// message ou de vérifier une signature.
+// Les clés ElGamal de GnuPG, dont p n'est pas un nombre premier sûr, sont
+// vérifiées par validateNonSafePrime.
+func (pub *ElgamalPublicKey) Validate() error {
+	order, err := pub.generatorOrder()
+	if err == errKeyNotSafePrime {
+		return pub.validateNonSafePrime()
+	}
+	if err != nil {
+		return err
+	}
+
+	p := new(big.Int).Add(pub.Q, big1)
+
+	// h dans [2, p-2] et h^ordre = 1
+	if pub.H.Cmp(big2) < 0 || pub.H.Cmp(new(big.Int).Sub(pub.Q, big1)) > 0 {
+		return errKeyPublicValue
+	}
+	if new(big.Int).Exp(pub.H, order, p).Cmp(big1) != 0 {
+		return errKeyPublicValue
+	}
+	return nil
+}
+
+// Vérifie une clé dont p est premier mais pas sûr, comme celles de GnuPG
+// (nombres premiers de Lim-Lee : p = 2 q1 ... qn + 1, les qi étant de
+// grands nombres premiers). La factorisation de p-1 n'étant pas connue, on
+// en retire les facteurs premiers inférieurs à sieveBound : il reste m,
+// qui doit représenter au moins la moitié des bits de p-1. g et h ne
+// doivent pas appartenir au petit sous-groupe d'ordre s = (p-1)/m, leur
+// ordre a donc un grand facteur premier, et pour chaque petit facteur l,
+// la l-partie de l'ordre de h doit diviser celle de g : h appartient alors
+// au groupe engendré par g, dans la mesure où cela peut être vérifié sans
+// factoriser m.
+func (pub *ElgamalPublicKey) validateNonSafePrime() error {
+	p := new(big.Int).Add(pub.Q, big1)
+
+	// p - 1 = s * m
+	m := new(big.Int).Set(pub.Q)
+	var factors []*big.Int
+	r := new(big.Int)
+	for _, q := range append([]uint64{2}, smallPrimes...) {
+		l := new(big.Int).SetUint64(q)
+		if r.Mod(m, l).Sign() != 0 {
+			continue
+		}
+		factors = append(factors, l)
+		for r.Mod(m, l).Sign() == 0 {
+			m.Quo(m, l)
+		}
+	}
+	if 2*m.BitLen() < pub.Q.BitLen() {
+		return errKeySmoothOrder
+	}
+	s := new(big.Int).Quo(pub.Q, m)
+
+	if new(big.Int).Exp(pub.G, s, p).Cmp(big1) == 0 {
+		return errKeyGenerator
+	}
+
+	// h dans [2, p-2] et h^s différent de 1
+	if pub.H.Cmp(big2) < 0 || pub.H.Cmp(new(big.Int).Sub(pub.Q, big1)) > 0 {
+		return errKeyPublicValue
+	}
+	if new(big.Int).Exp(pub.H, s, p).Cmp(big1) == 0 {
+		return errKeyPublicValue
+	}
+	for _, l := range factors {
+		if orderExponent(pub.H, p, l) > orderExponent(pub.G, p, l) {
+			return errKeyPublicValue
+		}
+	}
+	return nil
+}
+
+// Renvoie l'exposant de l dans l'ordre de a modulo p (l premier)
+func orderExponent(a, p, l *big.Int) int {
+	// a^c est d'ordre l^e, c étant p-1 privé de ses facteurs l
+	c := new(big.Int).Sub(p, big1)
+	r := new(big.Int)
+	for r.Mod(c, l).Sign() == 0 {
+		c.Quo(c, l)
+	}
+
+	e := 0
+	for b := new(big.Int).Exp(a, c, p); b.Cmp(big1) != 0; b.Exp(b, l, p) {
+		e++
+	}
+	return e
+}
+
+// Validate vérifie la clé publique associée puis que h = g^x
+func (priv *ElgamalPrivateKey) Validate() error {
+	if err := priv.ElgamalPublicKey.Validate(); err != nil {
+		return err
+	}
+
+	p := new(big.Int).Add(priv.Q, big1)
+	if priv.X == nil || priv.X.Sign() <= 0 || priv.X.Cmp(priv.Q) >= 0 {
+		return errKeyPrivateValue
+	}
+	if new(big.Int).Exp(priv.G, priv.X, p).Cmp(priv.H) != 0 {
+		return errKeyPrivateValue
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// Renvoie une copie de la clé publique de keys modifiée par f
+func modifiedKey(f func(pub *ElgamalPublicKey)) *ElgamalPublicKey {
+	pub := &ElgamalPublicKey{
+		Q: new(big.Int).Set(keys.Q),
+		G: new(big.Int).Set(keys.G),
+		H: new(big.Int).Set(keys.H),
+	}
+	f(pub)
+	return pub
+}
+
+func TestValidKeys(t *testing.T) {
+	groupKey, err := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, priv := range []*ElgamalPrivateKey{keys, dsaVectorKey(), groupKey} {
+		if err := priv.ElgamalPublicKey.Validate(); err != nil {
+			t.Error("Une clé publique valide a été refusée :", err)
+		}
+		if err := priv.Validate(); err != nil {
+			t.Error("Une clé privée valide a été refusée :", err)
+		}
+	}
+}
+
+func TestInvalidPublicKeys(t *testing.T) {
+	p := new(big.Int).Add(keys.Q, big1)
+
+	invalid := map[string]*ElgamalPublicKey{
+		"h = 0":   modifiedKey(func(pub *ElgamalPublicKey) { pub.H.SetInt64(0) }),
+		"h = 1":   modifiedKey(func(pub *ElgamalPublicKey) { pub.H.SetInt64(1) }),
+		"h = p-1": modifiedKey(func(pub *ElgamalPublicKey) { pub.H.Set(keys.Q) }),
+		"h = p":   modifiedKey(func(pub *ElgamalPublicKey) { pub.H.Set(p) }),
+		"g = 1":   modifiedKey(func(pub *ElgamalPublicKey) { pub.G.SetInt64(1) }),
+		"g = p-1": modifiedKey(func(pub *ElgamalPublicKey) { pub.G.Set(keys.Q) }),
+		"p pair":  modifiedKey(func(pub *ElgamalPublicKey) { pub.Q.Add(pub.Q, big1) }),
+		"p composé": modifiedKey(func(pub *ElgamalPublicKey) {
+			pub.Q.Mul(p, big.NewInt(3))
+			pub.Q.Sub(pub.Q, big1)
+		}),
+		"p trop petit": modifiedKey(func(pub *ElgamalPublicKey) {
+			pub.Q.SetInt64(22)
+			pub.G.SetInt64(5)
+			pub.H.SetInt64(7)
+		}),
+		"sous-groupe non premier": modifiedKey(func(pub *ElgamalPublicKey) { pub.SubgroupOrder = new(big.Int).Set(keys.Q) }),
+	}
+
+	// p premier mais pas sûr : p - 1 = 6 * k * 2^200, n = (p-1)/2 est
+	// divisible par 3
+	for k := int64(1); ; k++ {
+		q := new(big.Int).Lsh(big.NewInt(6*k), 200)
+		if probablyPrime(new(big.Int).Add(q, big1), 25) {
+			invalid["p non sûr"] = &ElgamalPublicKey{Q: q, G: big.NewInt(3), H: big.NewInt(9)}
+			break
+		}
+	}
+
+	// Dans un groupe standard, g = 2 engendre les carrés : -h n'en est pas
+	group, _ := GenerateElgamalKeysInGroup(rand.Reader, "ffdhe2048")
+	gp := new(big.Int).Add(group.Q, big1)
+	invalid["h hors du sous-groupe"] = &ElgamalPublicKey{Q: group.Q, G: group.G, H: new(big.Int).Sub(gp, group.H)}
+
+	// Clé DSA dont g n'est pas d'ordre q
+	dsaKey := dsaVectorKey().ElgamalPublicKey
+	dsaKey.G = big.NewInt(3)
+	invalid["g d'ordre différent de q"] = &dsaKey
+
+	for name, pub := range invalid {
+		if err := pub.Validate(); err == nil {
+			t.Error("Une clé invalide a été acceptée :", name)
+		}
+	}
+}
+
+func TestInvalidPrivateKey(t *testing.T) {
+	priv := *keys
+	priv.X = new(big.Int).Add(keys.X, big1)
+
+	if err := priv.Validate(); err != errKeyPrivateValue {
+		t.Error("Une clé privée incohérente a été acceptée", err)
+	}
+}
+
+func TestGnuPGKeyValidation(t *testing.T) {
+	pub, err := ReadOpenPGPPublicKey(readTestdata(t, "gpg-public.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.ElgamalPublicKey.Validate(); err != nil {
+		t.Error("La clé de GnuPG a été refusée :", err)
+	}
+
+	priv, err := ReadOpenPGPPrivateKey(readTestdata(t, "gpg-secret.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := priv.ElgamalPrivateKey.Validate(); err != nil {
+		t.Error("La clé privée de GnuPG a été refusée :", err)
+	}
+
+	gpg := pub.ElgamalPublicKey
+	p := new(big.Int).Add(gpg.Q, big1)
+	invalid := map[string]ElgamalPublicKey{
+		"h = 1":   {Q: gpg.Q, G: gpg.G, H: big.NewInt(1)},
+		"h = p-1": {Q: gpg.Q, G: gpg.G, H: gpg.Q},
+		"g = p-1": {Q: gpg.Q, G: gpg.Q, H: gpg.H},
+		// g = 5^2 est un carré, -h n'en est pas un
+		"h hors du groupe de g": {Q: gpg.Q, G: big.NewInt(25), H: new(big.Int).Sub(p, gpg.H)},
+	}
+	for name, pub := range invalid {
+		if err := pub.Validate(); err == nil {
+			t.Error("Une clé GnuPG invalide a été acceptée :", name)
+		}
+	}
+}